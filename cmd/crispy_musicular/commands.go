@@ -0,0 +1,611 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hoffs/crispy-musicular/pkg/artifact"
+	"github.com/hoffs/crispy-musicular/pkg/backup"
+	"github.com/hoffs/crispy-musicular/pkg/backup/actions"
+	"github.com/hoffs/crispy-musicular/pkg/http"
+)
+
+type command struct {
+	name        string
+	args        string
+	description string
+	// long running commands log to stdout, like they did before there were commands
+	logToStdout bool
+	run         func(a *app, args []string) error
+}
+
+var commands []*command
+
+// commands refer to usage, which refers to commands, so they can't be initialized statically
+func init() {
+	commands = []*command{
+		{"serve", "", "run periodic backups and the web interface (default)", true, runServe},
+		{"backup", "[--once]", "run periodic backups without the web interface, or a single backup with --once", true, runBackup},
+		{"list-backups", "[--user id]", "list backups of the user, newest first", false, runListBackups},
+		{"show", "<backup id> [--playlist id]", "show playlists of a backup, or tracks of a single playlist", false, runShow},
+		{"export", "<backup id> [--format f] [--out dir] [--plain]", "write backup files like post backup actions do", false, runExport},
+		{"import", "<file>", "import a backup document written by the json action", false, runImport},
+		{"diff", "<from backup id> <to backup id>", "show changes between two backups", false, runDiff},
+		{"prune", "[--user id] [--dry-run]", "delete backups not kept by the retention policy", false, runPrune},
+		{"create-api-token", "<name>", "create a token for the JSON API, it is shown only once", false, runCreateApiToken},
+		{"list-api-tokens", "", "list JSON API tokens", false, runListApiTokens},
+		{"revoke-api-token", "<token id>", "delete a JSON API token", false, runRevokeApiToken},
+		{"help", "", "show this help", false, nil},
+	}
+}
+
+func findCommand(name string) (*command, bool) {
+	for _, c := range commands {
+		if c.name == name {
+			return c, true
+		}
+	}
+
+	return nil, false
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [arguments]\n\nCommands:\n", os.Args[0])
+	w := tabwriter.NewWriter(os.Stderr, 0, 4, 2, ' ', 0)
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %s %s\t%s\n", c.name, c.args, c.description)
+	}
+	w.Flush()
+}
+
+var errNotAuthenticated = errors.New("spotify is not authenticated, log in through the web interface first")
+
+// Parses flags which can be placed before, between or after positional arguments,
+// exactly n positional arguments are expected.
+func parseArgs(fs *flag.FlagSet, args []string, n int) (positional []string, err error) {
+	for {
+		err = fs.Parse(args)
+		if err != nil {
+			return
+		}
+
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+
+	if len(positional) != n {
+		fs.Usage()
+		return nil, fmt.Errorf("expected %d arguments, got %d", n, len(positional))
+	}
+
+	return
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		c, _ := findCommand(name)
+		fmt.Fprintf(fs.Output(), "Usage: %s %s %s\n", os.Args[0], c.name, c.args)
+		fs.PrintDefaults()
+	}
+
+	return fs
+}
+
+func parseBackupId(s string) (int64, error) {
+	id, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid backup id %q", s)
+	}
+
+	return id, nil
+}
+
+// Uses the authenticated user if user isn't provided
+func (a *app) userId(user string) (string, error) {
+	if user != "" {
+		return user, nil
+	}
+
+	st, err := a.auth.GetState()
+	if err != nil {
+		return "", err
+	}
+
+	if st.User == "" {
+		return "", errors.New("no authenticated user, provide --user")
+	}
+
+	return st.User, nil
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+
+	return t.Format(time.RFC3339)
+}
+
+func runServe(a *app, args []string) (err error) {
+	_, err = parseArgs(newFlagSet("serve"), args, 0)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go a.backuper.RunPeriodically(ctx)
+
+	// this is blocking
+	return http.RegisterHandlers(a.conf, a.auth, a.backuper)
+}
+
+func runBackup(a *app, args []string) (err error) {
+	fs := newFlagSet("backup")
+	once := fs.Bool("once", false, "run a single backup and exit, e.g. when started by cron")
+	_, err = parseArgs(fs, args, 0)
+	if err != nil {
+		return
+	}
+
+	st, err := a.auth.GetState()
+	if err != nil {
+		return
+	}
+
+	if !st.IsSet() {
+		return errNotAuthenticated
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if *once {
+		// interrupted backup is marked as failed instead of being left unfinished
+		go func() {
+			<-ctx.Done()
+			a.backuper.CancelBackup(0)
+		}()
+
+		return a.backuper.Backup()
+	}
+
+	a.backuper.RunPeriodically(ctx)
+	return nil
+}
+
+func runListBackups(a *app, args []string) (err error) {
+	fs := newFlagSet("list-backups")
+	user := fs.String("user", "", "spotify user id, default is the authenticated user")
+	_, err = parseArgs(fs, args, 0)
+	if err != nil {
+		return
+	}
+
+	userId, err := a.userId(*user)
+	if err != nil {
+		return
+	}
+
+	backups, err := a.repo.GetBackups(userId)
+	if err != nil {
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTARTED\tFINISHED\tSCOPE\tSUCCESS\tPLAYLISTS\tTRACKS")
+	for i := range *backups {
+		b := &(*backups)[i]
+
+		playlists, err := a.repo.GetBackupPlaylistCount(b, backup.ScopeAll)
+		if err != nil {
+			return err
+		}
+
+		tracks, err := a.repo.GetBackupTrackCount(b, backup.ScopeAll)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%t\t%d\t%d\n", b.Id, formatTime(b.Started), formatTime(b.Finished), b.Scope, b.Success, playlists, tracks)
+	}
+
+	return w.Flush()
+}
+
+func runShow(a *app, args []string) (err error) {
+	fs := newFlagSet("show")
+	playlist := fs.String("playlist", "", "spotify or youtube playlist id to list tracks of")
+	pos, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return
+	}
+
+	id, err := parseBackupId(pos[0])
+	if err != nil {
+		return
+	}
+
+	d, err := a.document(id)
+	if err != nil {
+		return
+	}
+
+	if *playlist != "" {
+		return showPlaylist(d, *playlist)
+	}
+
+	fmt.Printf("Backup:   %d\nUser:     %s\nStarted:  %s\nFinished: %s\nSuccess:  %t\n\n",
+		id, d.Backup.UserId, formatTime(d.Backup.Started), formatTime(d.Backup.Finished), d.Backup.Success)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PLATFORM\tID\tNAME\tTRACKS")
+	for _, p := range d.Spotify.Playlists {
+		fmt.Fprintf(w, "spotify\t%s\t%s\t%d\n", p.Id, p.Name, len(p.Tracks))
+	}
+
+	for _, p := range d.Youtube.Playlists {
+		fmt.Fprintf(w, "youtube\t%s\t%s\t%d\n", p.Id, p.Name, len(p.Tracks))
+	}
+
+	err = w.Flush()
+	if err != nil {
+		return
+	}
+
+	fmt.Printf("\nLibrary: %d albums, %d artists, %d shows\n", len(d.Spotify.Albums), len(d.Spotify.Artists), len(d.Spotify.Shows))
+	return
+}
+
+func showPlaylist(d *backup.Document, id string) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, p := range d.Spotify.Playlists {
+		if p.Id != id {
+			continue
+		}
+
+		fmt.Fprintln(w, "#\tID\tARTIST\tNAME\tALBUM\tADDED AT\tAVAILABILITY")
+		for i, t := range p.Tracks {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", i+1, t.Id, t.Artist, t.Name, t.Album, t.AddedAt, t.Availability)
+		}
+
+		return w.Flush()
+	}
+
+	for _, p := range d.Youtube.Playlists {
+		if p.Id != id {
+			continue
+		}
+
+		fmt.Fprintln(w, "#\tID\tCHANNEL\tNAME\tADDED AT\tAVAILABILITY")
+		for i, t := range p.Tracks {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", i+1, t.Id, t.ChannelTitle, t.Name, t.AddedAt, t.Availability)
+		}
+
+		return w.Flush()
+	}
+
+	return backup.ErrPlaylistNotFound
+}
+
+func (a *app) document(id int64) (d *backup.Document, err error) {
+	bp, err := a.repo.GetBackup(id)
+	if err != nil {
+		return
+	}
+
+	p, t, yp, yt, l, err := a.repo.GetBackupData(bp)
+	if err != nil {
+		return
+	}
+
+	return backup.NewDocument(bp, p, t, yp, yt, l), nil
+}
+
+// Export runs the post backup action of the format with output directory
+// replaced, so files are exactly the same as written after a backup.
+func runExport(a *app, args []string) (err error) {
+	fs := newFlagSet("export")
+	format := fs.String("format", "json", "json, json-legacy, csv or playlists (xspf and m3u8)")
+	out := fs.String("out", ".", "directory to write files to")
+	plain := fs.Bool("plain", false, "don't compress or encrypt files even if artifacts are configured")
+	pos, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return
+	}
+
+	id, err := parseBackupId(pos[0])
+	if err != nil {
+		return
+	}
+
+	conf := *a.conf
+	if *plain {
+		conf.ArtifactCompression = artifact.CompressionNone
+		conf.ArtifactAgeRecipients = nil
+		conf.ArtifactPassphrase = ""
+	}
+
+	var act backup.PostBackupAction
+	switch *format {
+	case "json", "json-legacy":
+		conf.JsonActionEnabled = true
+		conf.JsonDir = *out
+		conf.JsonLegacyFormat = *format == "json-legacy"
+		act, err = actions.NewJsonBackupAction(&conf)
+	case "csv":
+		conf.CsvActionEnabled = true
+		conf.CsvDir = *out
+		act, err = actions.NewCsvBackupAction(&conf)
+	case "playlists":
+		conf.PlaylistFileActionEnabled = true
+		conf.PlaylistFileM3uEnabled = true
+		conf.PlaylistFileDir = *out
+		act, err = actions.NewPlaylistFileBackupAction(&conf)
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+
+	if err != nil {
+		return
+	}
+
+	bp, err := a.repo.GetBackup(id)
+	if err != nil {
+		return
+	}
+
+	err = a.exportBackup(act, bp)
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) && errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("%s already exists, backups are never overwritten, remove it or export to another --out directory", pathErr.Path)
+	}
+
+	if err != nil {
+		return
+	}
+
+	fmt.Printf("Exported backup %d to %s\n", id, *out)
+	return
+}
+
+// Files are written by the same code as post backup actions
+func (a *app) exportBackup(act backup.PostBackupAction, bp *backup.Backup) (err error) {
+	p, t, yp, yt, l, err := a.repo.GetBackupData(bp)
+	if err != nil {
+		return
+	}
+
+	if bp.Covers(backup.ScopeSpotify) {
+		err = act.Do(bp, p, t)
+		if err != nil {
+			return
+		}
+
+		err = act.DoLibrary(bp, l)
+		if err != nil {
+			return
+		}
+	}
+
+	if bp.Covers(backup.ScopeYoutube) {
+		err = act.DoYoutube(bp, yp, yt)
+		if err != nil {
+			return
+		}
+	}
+
+	if da, ok := act.(backup.DocumentBackupAction); ok {
+		err = da.DoDocument(backup.NewDocument(bp, p, t, yp, yt, l))
+	}
+
+	return
+}
+
+func runImport(a *app, args []string) (err error) {
+	pos, err := parseArgs(newFlagSet("import"), args, 1)
+	if err != nil {
+		return
+	}
+
+	opts, err := artifact.NewDecodeOptionsFromConfig(a.conf)
+	if err != nil {
+		return
+	}
+
+	data, err := artifact.DecodeFile(pos[0], opts)
+	if err != nil {
+		return
+	}
+
+	d, err := backup.ParseDocument(data)
+	if err != nil {
+		return
+	}
+
+	bp, err := a.backuper.Import(d)
+	if err != nil {
+		return
+	}
+
+	fmt.Printf("Imported backup %d of %s started at %s\n", bp.Id, bp.UserId, formatTime(bp.Started))
+	return
+}
+
+func runDiff(a *app, args []string) (err error) {
+	pos, err := parseArgs(newFlagSet("diff"), args, 2)
+	if err != nil {
+		return
+	}
+
+	from, err := parseBackupId(pos[0])
+	if err != nil {
+		return
+	}
+
+	to, err := parseBackupId(pos[1])
+	if err != nil {
+		return
+	}
+
+	d, err := a.backuper.DiffBackups(from, to)
+	if err != nil {
+		return
+	}
+
+	fmt.Printf("Backup %d (%s) -> backup %d (%s)\n", d.From.Id, formatTime(d.From.Started), d.To.Id, formatTime(d.To.Started))
+	if d.Scope != backup.ScopeAll {
+		fmt.Printf("Only %s is compared, the other platform is not part of both backups\n", d.Scope)
+	}
+
+	changed := printPlaylistDiffs("Spotify", d.Spotify)
+	changed = printPlaylistDiffs("Youtube", d.Youtube) || changed
+	if !changed {
+		fmt.Println("\nNo changes")
+	}
+
+	return
+}
+
+func printPlaylistDiffs(platform string, diffs []backup.PlaylistDiff) (changed bool) {
+	for _, p := range diffs {
+		if !p.HasChanges() {
+			continue
+		}
+
+		if !changed {
+			fmt.Printf("\n%s\n", platform)
+			changed = true
+		}
+
+		status := ""
+		switch {
+		case p.Created:
+			status = " (created)"
+		case p.Deleted:
+			status = " (deleted)"
+		case p.IsRenamed():
+			status = fmt.Sprintf(" (renamed from %s)", p.OldName)
+		}
+
+		fmt.Printf("  %s [%s]%s\n", p.Name, p.Id, status)
+		for _, t := range p.Added {
+			fmt.Printf("    + %s - %s\n", t.Artist, t.Name)
+		}
+
+		for _, t := range p.Removed {
+			fmt.Printf("    - %s - %s\n", t.Artist, t.Name)
+		}
+
+		for _, t := range p.Renamed {
+			fmt.Printf("    ~ %s - %s (was %s)\n", t.Artist, t.Name, t.OldName)
+		}
+
+		for _, t := range p.Reordered {
+			fmt.Printf("    > %s - %s (moved %d -> %d)\n", t.Artist, t.Name, t.OldPosition+1, t.Position+1)
+		}
+	}
+
+	return
+}
+
+func runPrune(a *app, args []string) (err error) {
+	fs := newFlagSet("prune")
+	user := fs.String("user", "", "spotify user id, default is the authenticated user")
+	dryRun := fs.Bool("dry-run", false, "only list backups that would be deleted")
+	_, err = parseArgs(fs, args, 0)
+	if err != nil {
+		return
+	}
+
+	userId, err := a.userId(*user)
+	if err != nil {
+		return
+	}
+
+	expired, err := a.backuper.Prune(userId, *dryRun)
+	if err != nil {
+		return
+	}
+
+	verb := "Deleted"
+	if *dryRun {
+		verb = "Would delete"
+	}
+
+	fmt.Printf("%s %d backups\n", verb, len(*expired))
+	for _, b := range *expired {
+		fmt.Printf("  %d started at %s (success: %t)\n", b.Id, formatTime(b.Started), b.Success)
+	}
+
+	return
+}
+
+func runCreateApiToken(a *app, args []string) (err error) {
+	pos, err := parseArgs(newFlagSet("create-api-token"), args, 1)
+	if err != nil {
+		return
+	}
+
+	token, t, err := a.auth.CreateApiToken(pos[0])
+	if err != nil {
+		return
+	}
+
+	fmt.Printf("Created api token %d (%s), it won't be shown again:\n%s\n", t.Id, t.Name, token)
+	return
+}
+
+func runListApiTokens(a *app, args []string) (err error) {
+	_, err = parseArgs(newFlagSet("list-api-tokens"), args, 0)
+	if err != nil {
+		return
+	}
+
+	tokens, err := a.auth.GetApiTokens()
+	if err != nil {
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tCREATED\tLAST USED")
+	for _, t := range *tokens {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", t.Id, t.Name, formatTime(t.Created), formatTime(t.LastUsed))
+	}
+
+	return w.Flush()
+}
+
+func runRevokeApiToken(a *app, args []string) (err error) {
+	pos, err := parseArgs(newFlagSet("revoke-api-token"), args, 1)
+	if err != nil {
+		return
+	}
+
+	id, err := strconv.ParseInt(pos[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid token id %q", pos[0])
+	}
+
+	err = a.auth.DeleteApiToken(id)
+	if err != nil {
+		return
+	}
+
+	fmt.Printf("Revoked api token %d\n", id)
+	return
+}
@@ -1,16 +1,18 @@
 package main
 
 import (
-	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"io"
 	"os"
 	"path"
+	"strings"
 
 	"github.com/hoffs/crispy-musicular/pkg/auth"
 	"github.com/hoffs/crispy-musicular/pkg/backup"
 	"github.com/hoffs/crispy-musicular/pkg/backup/actions"
 	"github.com/hoffs/crispy-musicular/pkg/config"
-	"github.com/hoffs/crispy-musicular/pkg/http"
 	"github.com/hoffs/crispy-musicular/pkg/storage"
 	"github.com/joho/godotenv"
 	"github.com/rs/zerolog/log"
@@ -23,70 +25,148 @@ func getEnv(key, fallback string) string {
 	return fallback
 }
 
+// Everything commands need, created the same way for every command
+type app struct {
+	conf     *config.AppConfig
+	repo     storage.Repository
+	auth     auth.Service
+	backuper backup.Service
+}
+
 func main() {
+	// serve is the default, so that existing deployments keep working without arguments
+	name, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+
+	cmd, ok := findCommand(name)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+		usage()
+		os.Exit(2)
+	}
+
+	// help doesn't need config or database
+	if cmd.name == "help" {
+		usage()
+		return
+	}
+
 	logDir := getEnv("LOG_DIR", "logs")
 
 	err := os.MkdirAll(logDir, 0755)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to create log directory")
-		return
+		os.Exit(1)
 	}
 
 	logFile, err := os.OpenFile(path.Join(logDir, "crispy.log"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to open log file")
-		return
+		os.Exit(1)
+	}
+
+	// output of commands goes to stdout, so logs shouldn't be mixed into it
+	var out io.Writer = os.Stderr
+	if cmd.logToStdout {
+		out = os.Stdout
 	}
 
-	log.Logger = log.Output(io.MultiWriter(os.Stdout, logFile))
+	log.Logger = log.Output(io.MultiWriter(out, logFile))
 	// load .env before anything else
 	_ = godotenv.Load()
 	_ = godotenv.Load(".env.local")
 
-	conf, err := config.Load(getEnv("CONFIG_PATH", "conf.yaml"))
+	a, err := newApp()
 	if err != nil {
-		log.Error().Err(err).Msg("failed to load config")
+		log.Error().Err(err).Msg("failed to start")
+		os.Exit(1)
+	}
+
+	err = cmd.run(a, args)
+	a.repo.Close()
+
+	if errors.Is(err, flag.ErrHelp) {
 		return
 	}
 
-	r, err := storage.NewRepository(conf.DbPath)
 	if err != nil {
-		log.Error().Err(err).Msg("failed to load database")
-		return
+		log.Error().Err(err).Msgf("command %s failed", name)
+		os.Exit(1)
 	}
+}
 
-	auth, err := auth.NewService(r)
+func newApp() (a *app, err error) {
+	a = &app{}
+	a.conf, err = config.Load(getEnv("CONFIG_PATH", "conf.yaml"))
 	if err != nil {
-		log.Error().Err(err).Msg("failed to create auth service")
-		return
+		return nil, fmt.Errorf("failed to load config: %w", err)
 	}
 
+	a.repo, err = storage.NewRepository(a.conf.DbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load database: %w", err)
+	}
+
+	a.auth, err = auth.NewService(a.repo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create auth service: %w", err)
+	}
+
+	acts, err := newActions(a.conf, a.auth)
+	if err != nil {
+		return nil, err
+	}
+
+	a.backuper, err = backup.NewBackuper(a.conf, a.auth, a.repo, acts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create backuper: %w", err)
+	}
+
+	return
+}
+
+func newActions(conf *config.AppConfig, auth auth.Service) (acts []backup.PostBackupAction, err error) {
 	jsonBackup, err := actions.NewJsonBackupAction(conf)
 	if err != nil {
-		log.Error().Err(err).Msg("failed to create backuper json action")
-		return
+		return nil, fmt.Errorf("failed to create backuper json action: %w", err)
 	}
 
-	driveBackup, err := actions.NewGoogleDriveBackupAction(conf, auth)
+	csvBackup, err := actions.NewCsvBackupAction(conf)
 	if err != nil {
-		log.Error().Err(err).Msg("failed to create backuper google drive action")
-		return
+		return nil, fmt.Errorf("failed to create backuper csv action: %w", err)
 	}
 
-	backuper, err := backup.NewBackuper(conf, auth, r, jsonBackup, driveBackup)
+	playlistFileBackup, err := actions.NewPlaylistFileBackupAction(conf)
 	if err != nil {
-		log.Error().Err(err).Msg("failed to create backuper")
-		return
+		return nil, fmt.Errorf("failed to create backuper playlist file action: %w", err)
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	go backuper.RunPeriodically(ctx)
+	s3Backup, err := actions.NewS3BackupAction(conf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create backuper s3 action: %w", err)
+	}
 
-	// this is blocking
-	err = http.RegisterHandlers(conf, auth, backuper)
+	webdavBackup, err := actions.NewWebdavBackupAction(conf)
 	if err != nil {
-		log.Error().Err(err).Msg("failed to register handlers")
-		return
+		return nil, fmt.Errorf("failed to create backuper webdav action: %w", err)
+	}
+
+	sftpBackup, err := actions.NewSftpBackupAction(conf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create backuper sftp action: %w", err)
+	}
+
+	gitBackup, err := actions.NewGitBackupAction(conf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create backuper git action: %w", err)
+	}
+
+	driveBackup, err := actions.NewGoogleDriveBackupAction(conf, auth)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create backuper google drive action: %w", err)
 	}
+
+	return []backup.PostBackupAction{jsonBackup, csvBackup, playlistFileBackup, s3Backup, webdavBackup, sftpBackup, gitBackup, driveBackup}, nil
 }
@@ -4,17 +4,20 @@ go 1.16
 
 require (
 	cloud.google.com/go v0.84.0 // indirect
+	filippo.io/age v1.0.0
+	github.com/go-git/go-git/v5 v5.4.2
 	github.com/golang/groupcache v0.0.0-20210331224755-41bb18bfe9da // indirect
 	github.com/joho/godotenv v1.3.0
-	github.com/mattn/go-sqlite3 v1.14.7 // indirect
-	github.com/rs/zerolog v1.21.0 // indirect
-	github.com/stretchr/testify v1.7.0 // indirect
+	github.com/klauspost/compress v1.13.6
+	github.com/mattn/go-sqlite3 v1.14.7
+	github.com/pkg/sftp v1.13.4
+	github.com/rs/zerolog v1.21.0
+	github.com/stretchr/testify v1.7.0
 	github.com/zmb3/spotify v1.1.2
+	golang.org/x/crypto v0.0.0-20210817164053-32db794688a5
 	golang.org/x/net v0.0.0-20210610132358-84b48f89b13b // indirect
-	golang.org/x/oauth2 v0.0.0-20210514164344-f6687ab2804c // indirect
-	golang.org/x/sys v0.0.0-20210611083646-a4fc73990273 // indirect
-	google.golang.org/api v0.48.0 // indirect
+	golang.org/x/oauth2 v0.0.0-20210514164344-f6687ab2804c
+	google.golang.org/api v0.48.0
 	google.golang.org/genproto v0.0.0-20210611144927-798beca9d670 // indirect
-	gopkg.in/yaml.v2 v2.4.0 // indirect
-	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b // indirect
+	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b
 )
@@ -0,0 +1,306 @@
+package artifact
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/hoffs/crispy-musicular/pkg/config"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Artifacts are backup files written or uploaded by post backup actions.
+// They are optionally compressed and then encrypted, every step adds
+// an extension to the file name, e.g. spotify-user+time.json.zst.age
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+
+	extensionGzip = ".gz"
+	extensionZstd = ".zst"
+	extensionAge  = ".age"
+)
+
+var (
+	ErrNoIdentity          = errors.New("artifact: data is encrypted, but no identity or passphrase was provided")
+	ErrUnknownCompression  = errors.New("artifact: unknown compression")
+	ErrPassphraseRecipient = errors.New("artifact: passphrase can't be used together with age recipients")
+	ErrTooLarge            = errors.New("artifact: decoded data is too large")
+)
+
+type Options struct {
+	Compression string
+	// age public keys (age1...)
+	Recipients []string
+	Passphrase string
+}
+
+type Encoder interface {
+	Encode(data []byte) ([]byte, error)
+	// Extension which has to be appended to file name of encoded data
+	Extension() string
+	// Content type of encoded data, contentType is returned if data is stored as is
+	ContentType(contentType string) string
+}
+
+type encoder struct {
+	compression string
+	recipients  []age.Recipient
+}
+
+func NewEncoder(opts Options) (e Encoder, err error) {
+	enc := &encoder{compression: opts.Compression}
+	switch opts.Compression {
+	case "", CompressionNone:
+		enc.compression = CompressionNone
+	case CompressionGzip, CompressionZstd:
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownCompression, opts.Compression)
+	}
+
+	if opts.Passphrase != "" && len(opts.Recipients) > 0 {
+		return nil, ErrPassphraseRecipient
+	}
+
+	if opts.Passphrase != "" {
+		r, err := age.NewScryptRecipient(opts.Passphrase)
+		if err != nil {
+			return nil, err
+		}
+
+		enc.recipients = append(enc.recipients, r)
+	}
+
+	for _, v := range opts.Recipients {
+		r, err := age.ParseX25519Recipient(v)
+		if err != nil {
+			return nil, fmt.Errorf("artifact: failed to parse recipient %s: %w", v, err)
+		}
+
+		enc.recipients = append(enc.recipients, r)
+	}
+
+	return enc, nil
+}
+
+func NewEncoderFromConfig(conf *config.AppConfig) (Encoder, error) {
+	return NewEncoder(Options{
+		Compression: conf.ArtifactCompression,
+		Recipients:  conf.ArtifactAgeRecipients,
+		Passphrase:  conf.ArtifactPassphrase,
+	})
+}
+
+func (e *encoder) Encode(data []byte) (out []byte, err error) {
+	switch e.compression {
+	case CompressionGzip:
+		var b bytes.Buffer
+		w := gzip.NewWriter(&b)
+		_, err = w.Write(data)
+		if err == nil {
+			err = w.Close()
+		}
+		data = b.Bytes()
+	case CompressionZstd:
+		var w *zstd.Encoder
+		w, err = zstd.NewWriter(nil)
+		if err == nil {
+			data = w.EncodeAll(data, nil)
+			err = w.Close()
+		}
+	}
+
+	if err != nil || len(e.recipients) == 0 {
+		return data, err
+	}
+
+	var b bytes.Buffer
+	w, err := age.Encrypt(&b, e.recipients...)
+	if err != nil {
+		return
+	}
+
+	_, err = w.Write(data)
+	if err != nil {
+		return
+	}
+
+	err = w.Close()
+	return b.Bytes(), err
+}
+
+func (e *encoder) Extension() (ext string) {
+	switch e.compression {
+	case CompressionGzip:
+		ext += extensionGzip
+	case CompressionZstd:
+		ext += extensionZstd
+	}
+
+	if len(e.recipients) > 0 {
+		ext += extensionAge
+	}
+
+	return
+}
+
+func (e *encoder) ContentType(contentType string) string {
+	if e.Extension() == "" {
+		return contentType
+	}
+
+	return "application/octet-stream"
+}
+
+// Removes extensions added by encoding from the file name
+func TrimExtension(name string) string {
+	for {
+		trimmed := name
+		for _, ext := range []string{extensionAge, extensionGzip, extensionZstd} {
+			trimmed = strings.TrimSuffix(trimmed, ext)
+		}
+
+		if trimmed == name {
+			return name
+		}
+
+		name = trimmed
+	}
+}
+
+type DecodeOptions struct {
+	// Contents of age identity files (AGE-SECRET-KEY-...)
+	Identities []string
+	Passphrase string
+	// Limit of decoded data in bytes, so that small compressed
+	// data can't expand to fill the memory, 0 means no limit
+	MaxSize int64
+}
+
+// Reads identity file and passphrase used for decoding from config
+func NewDecodeOptionsFromConfig(conf *config.AppConfig) (opts DecodeOptions, err error) {
+	opts.Passphrase = conf.ArtifactPassphrase
+	if conf.ArtifactIdentityFile == "" {
+		return
+	}
+
+	data, err := ioutil.ReadFile(conf.ArtifactIdentityFile)
+	if err != nil {
+		return
+	}
+
+	opts.Identities = append(opts.Identities, string(data))
+	return
+}
+
+var (
+	magicAge  = []byte("age-encryption.org/")
+	magicGzip = []byte{0x1f, 0x8b}
+	magicZstd = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// Decode detects how data was encoded from its content, so that plain
+// artifacts and artifacts written with different settings can all be read.
+func Decode(data []byte, opts DecodeOptions) (out []byte, err error) {
+	if bytes.HasPrefix(data, magicAge) {
+		identities, err := parseIdentities(opts)
+		if err != nil {
+			return nil, err
+		}
+
+		r, err := age.Decrypt(bytes.NewReader(data), identities...)
+		if err != nil {
+			return nil, err
+		}
+
+		data, err = readLimited(r, opts.MaxSize)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case bytes.HasPrefix(data, magicGzip):
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+
+		return readLimited(r, opts.MaxSize)
+	case bytes.HasPrefix(data, magicZstd):
+		r, err := zstd.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+
+		return readLimited(r, opts.MaxSize)
+	}
+
+	if opts.MaxSize > 0 && int64(len(data)) > opts.MaxSize {
+		return nil, ErrTooLarge
+	}
+
+	return data, nil
+}
+
+func readLimited(r io.Reader, max int64) (data []byte, err error) {
+	if max <= 0 {
+		return ioutil.ReadAll(r)
+	}
+
+	// one more byte is read to know that the limit was exceeded
+	data, err = ioutil.ReadAll(io.LimitReader(r, max+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(data)) > max {
+		return nil, ErrTooLarge
+	}
+
+	return
+}
+
+// Reads and decodes a file
+func DecodeFile(path string, opts DecodeOptions) (data []byte, err error) {
+	data, err = ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	return Decode(data, opts)
+}
+
+func parseIdentities(opts DecodeOptions) (identities []age.Identity, err error) {
+	for _, v := range opts.Identities {
+		ids, err := age.ParseIdentities(strings.NewReader(v))
+		if err != nil {
+			return nil, err
+		}
+
+		identities = append(identities, ids...)
+	}
+
+	if opts.Passphrase != "" {
+		id, err := age.NewScryptIdentity(opts.Passphrase)
+		if err != nil {
+			return nil, err
+		}
+
+		identities = append(identities, id)
+	}
+
+	if len(identities) == 0 {
+		return nil, ErrNoIdentity
+	}
+
+	return
+}
@@ -0,0 +1,112 @@
+package artifact
+
+import (
+	"bytes"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	id, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	data := []byte(`{"Backup":{"Id":1},"Playlists":[]}`)
+	for _, c := range []string{CompressionNone, CompressionGzip, CompressionZstd} {
+		e, err := NewEncoder(Options{Compression: c, Recipients: []string{id.Recipient().String()}})
+		require.NoError(t, err)
+
+		encoded, err := e.Encode(data)
+		require.NoError(t, err)
+		require.NotEqual(t, data, encoded)
+
+		_, err = Decode(encoded, DecodeOptions{})
+		require.ErrorIs(t, err, ErrNoIdentity)
+
+		decoded, err := Decode(encoded, DecodeOptions{Identities: []string{"# comment\n" + id.String() + "\n"}})
+		require.NoError(t, err)
+		require.Equal(t, data, decoded)
+
+		e, err = NewEncoder(Options{Compression: c})
+		require.NoError(t, err)
+
+		encoded, err = e.Encode(data)
+		require.NoError(t, err)
+
+		decoded, err = Decode(encoded, DecodeOptions{})
+		require.NoError(t, err)
+		require.Equal(t, data, decoded)
+	}
+}
+
+func TestEncodeDecodePassphrase(t *testing.T) {
+	data := []byte("data")
+	e, err := NewEncoder(Options{Compression: CompressionGzip, Passphrase: "secret"})
+	require.NoError(t, err)
+	require.Equal(t, ".gz.age", e.Extension())
+	require.Equal(t, "application/octet-stream", e.ContentType("application/json"))
+
+	encoded, err := e.Encode(data)
+	require.NoError(t, err)
+
+	_, err = Decode(encoded, DecodeOptions{Passphrase: "wrong"})
+	require.Error(t, err)
+
+	decoded, err := Decode(encoded, DecodeOptions{Passphrase: "secret"})
+	require.NoError(t, err)
+	require.Equal(t, data, decoded)
+}
+
+func TestDecodeMaxSize(t *testing.T) {
+	id, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+	identities := []string{id.String()}
+
+	data := bytes.Repeat([]byte("a"), 1000)
+	for _, c := range []string{CompressionNone, CompressionGzip, CompressionZstd} {
+		e, err := NewEncoder(Options{Compression: c, Recipients: []string{id.Recipient().String()}})
+		require.NoError(t, err)
+
+		encoded, err := e.Encode(data)
+		require.NoError(t, err)
+
+		decoded, err := Decode(encoded, DecodeOptions{Identities: identities, MaxSize: 1000})
+		require.NoError(t, err)
+		require.Equal(t, data, decoded)
+
+		_, err = Decode(encoded, DecodeOptions{Identities: identities, MaxSize: 999})
+		require.ErrorIs(t, err, ErrTooLarge, c)
+	}
+
+	_, err = Decode(data, DecodeOptions{MaxSize: 999})
+	require.ErrorIs(t, err, ErrTooLarge)
+}
+
+func TestNewEncoderErrors(t *testing.T) {
+	_, err := NewEncoder(Options{Compression: "lz4"})
+	require.ErrorIs(t, err, ErrUnknownCompression)
+
+	_, err = NewEncoder(Options{Passphrase: "secret", Recipients: []string{"age1"}})
+	require.ErrorIs(t, err, ErrPassphraseRecipient)
+
+	_, err = NewEncoder(Options{Recipients: []string{"age1"}})
+	require.Error(t, err)
+}
+
+func TestPlainEncoder(t *testing.T) {
+	e, err := NewEncoder(Options{})
+	require.NoError(t, err)
+	require.Equal(t, "", e.Extension())
+	require.Equal(t, "application/json", e.ContentType("application/json"))
+
+	encoded, err := e.Encode([]byte("data"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("data"), encoded)
+}
+
+func TestTrimExtension(t *testing.T) {
+	require.Equal(t, "a.json", TrimExtension("a.json.zst.age"))
+	require.Equal(t, "a.json", TrimExtension("a.json.gz"))
+	require.Equal(t, "a.json", TrimExtension("a.json"))
+}
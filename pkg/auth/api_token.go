@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/hoffs/crispy-musicular/pkg/rand"
+	"github.com/rs/zerolog/log"
+)
+
+// Tokens are only shown when created, stored is just their hash
+const apiTokenPrefix = "crispy_"
+
+var (
+	ErrInvalidApiToken  = errors.New("auth: invalid api token")
+	ErrApiTokenNotFound = errors.New("auth: api token not found")
+)
+
+type ApiToken struct {
+	Id       int64
+	Name     string
+	Hash     string
+	Created  time.Time
+	LastUsed time.Time
+}
+
+func hashApiToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+func (s *service) CreateApiToken(name string) (token string, t *ApiToken, err error) {
+	if name == "" {
+		return "", nil, errors.New("auth: api token name must be not empty")
+	}
+
+	secret, err := rand.String(40)
+	if err != nil {
+		return
+	}
+
+	token = apiTokenPrefix + secret
+	t = &ApiToken{Name: name, Hash: hashApiToken(token), Created: time.Now()}
+	err = s.r.AddApiToken(t)
+	return
+}
+
+func (s *service) GetApiTokens() (*[]ApiToken, error) {
+	return s.r.GetApiTokens()
+}
+
+func (s *service) DeleteApiToken(id int64) error {
+	return s.r.DeleteApiToken(id)
+}
+
+func (s *service) VerifyApiToken(token string) (t *ApiToken, err error) {
+	if !strings.HasPrefix(token, apiTokenPrefix) {
+		return nil, ErrInvalidApiToken
+	}
+
+	t, err = s.r.GetApiTokenByHash(hashApiToken(token))
+	if errors.Is(err, ErrApiTokenNotFound) {
+		return nil, ErrInvalidApiToken
+	}
+
+	if err != nil {
+		return
+	}
+
+	t.LastUsed = time.Now()
+	err = s.r.SetApiTokenUsed(t.Id, t.LastUsed)
+	if err != nil {
+		// token is still valid, failing to track usage shouldn't deny access
+		log.Error().Err(err).Msg("auth: failed to update api token usage")
+	}
+
+	return t, nil
+}
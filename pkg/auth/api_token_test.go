@@ -0,0 +1,45 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestApiToken(t *testing.T) {
+	r := mockRepo{}
+	s, err := NewService(&r)
+	require.NoError(t, err)
+
+	token, created, err := s.CreateApiToken("dashboard")
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(token, apiTokenPrefix))
+	require.NotContains(t, created.Hash, token)
+
+	v, err := s.VerifyApiToken(token)
+	require.NoError(t, err)
+	require.Equal(t, created.Id, v.Id)
+	require.False(t, r.tokens[0].LastUsed.IsZero())
+
+	_, err = s.VerifyApiToken(token + "x")
+	require.ErrorIs(t, err, ErrInvalidApiToken)
+
+	_, err = s.VerifyApiToken("")
+	require.ErrorIs(t, err, ErrInvalidApiToken)
+
+	err = s.DeleteApiToken(created.Id)
+	require.NoError(t, err)
+
+	_, err = s.VerifyApiToken(token)
+	require.ErrorIs(t, err, ErrInvalidApiToken)
+}
+
+func TestCreateApiTokenNoName(t *testing.T) {
+	r := mockRepo{}
+	s, err := NewService(&r)
+	require.NoError(t, err)
+
+	_, _, err = s.CreateApiToken("")
+	require.Error(t, err)
+}
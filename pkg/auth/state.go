@@ -1,6 +1,9 @@
 package auth
 
-import "errors"
+import (
+	"errors"
+	"time"
+)
 
 type State struct {
 	RefreshToken        string
@@ -17,12 +20,23 @@ type Service interface {
 	GetState() (State, error)
 	SetState(s State) error
 	ClearState() error
+
+	CreateApiToken(name string) (token string, t *ApiToken, err error)
+	GetApiTokens() (*[]ApiToken, error)
+	DeleteApiToken(id int64) error
+	VerifyApiToken(token string) (*ApiToken, error)
 }
 
 type Repository interface {
 	GetState() (State, error)
 	SetState(s State) error
 	ClearState() error
+
+	AddApiToken(t *ApiToken) error
+	GetApiTokens() (*[]ApiToken, error)
+	GetApiTokenByHash(hash string) (*ApiToken, error)
+	DeleteApiToken(id int64) error
+	SetApiTokenUsed(id int64, used time.Time) error
 }
 
 type service struct {
@@ -2,12 +2,14 @@ package auth
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
 
 type mockRepo struct {
-	st State
+	st     State
+	tokens []ApiToken
 }
 
 func (r *mockRepo) SetState(s State) error {
@@ -24,6 +26,47 @@ func (r *mockRepo) ClearState() error {
 	return nil
 }
 
+func (r *mockRepo) AddApiToken(t *ApiToken) error {
+	t.Id = int64(len(r.tokens) + 1)
+	r.tokens = append(r.tokens, *t)
+	return nil
+}
+
+func (r *mockRepo) GetApiTokens() (*[]ApiToken, error) {
+	return &r.tokens, nil
+}
+
+func (r *mockRepo) GetApiTokenByHash(hash string) (*ApiToken, error) {
+	for _, t := range r.tokens {
+		if t.Hash == hash {
+			return &t, nil
+		}
+	}
+
+	return nil, ErrApiTokenNotFound
+}
+
+func (r *mockRepo) DeleteApiToken(id int64) error {
+	for i, t := range r.tokens {
+		if t.Id == id {
+			r.tokens = append(r.tokens[:i], r.tokens[i+1:]...)
+			return nil
+		}
+	}
+
+	return ErrApiTokenNotFound
+}
+
+func (r *mockRepo) SetApiTokenUsed(id int64, used time.Time) error {
+	for i := range r.tokens {
+		if r.tokens[i].Id == id {
+			r.tokens[i].LastUsed = used
+		}
+	}
+
+	return nil
+}
+
 func TestGetStateEmpty(t *testing.T) {
 	r := mockRepo{}
 	s, err := NewService(&r)
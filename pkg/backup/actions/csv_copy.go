@@ -0,0 +1,188 @@
+package actions
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/hoffs/crispy-musicular/pkg/artifact"
+	"github.com/hoffs/crispy-musicular/pkg/backup"
+	"github.com/hoffs/crispy-musicular/pkg/config"
+	"github.com/rs/zerolog/log"
+)
+
+// Excel reads files without byte order mark in the system codepage,
+// which mangles non-ASCII names
+const utf8Bom = "\ufeff"
+
+type CsvBackupAction interface {
+	Do(bp *backup.Backup, p *[]backup.Playlist, t *[]backup.Track) error
+	DoYoutube(bp *backup.Backup, p *[]backup.YoutubePlaylist, t *[]backup.YoutubeTrack) error
+	DoLibrary(bp *backup.Backup, l *backup.Library) error
+}
+
+type csvBackupService struct {
+	enabled  bool
+	dir      string
+	artifact artifact.Encoder
+}
+
+func NewCsvBackupAction(conf *config.AppConfig) (CsvBackupAction, error) {
+	enc, err := artifact.NewEncoderFromConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	act := &csvBackupService{conf.CsvActionEnabled, conf.CsvDir, enc}
+	if act.enabled {
+		err := os.MkdirAll(act.dir, os.ModePerm)
+		return act, err
+	} else {
+		return act, nil
+	}
+}
+
+// Writes a single flat file with a row for every track, so that
+// it can be opened and filtered in a spreadsheet.
+func (s *csvBackupService) Do(bp *backup.Backup, p *[]backup.Playlist, t *[]backup.Track) (err error) {
+	if !s.enabled {
+		log.Debug().Msg("csv_backup_action: action is not enabled")
+		return nil
+	}
+
+	playlists := make(map[int64]*backup.Playlist)
+	for id := range *p {
+		playlists[(*p)[id].Id] = &(*p)[id]
+	}
+
+	rows := [][]string{{"Playlist", "Playlist ID", "Position", "Track", "Artists", "Album", "Spotify ID", "ISRC", "Added At"}}
+	positions := make(map[int64]int)
+	for _, v := range *t {
+		pl, ok := playlists[v.PlaylistId]
+		if !ok {
+			continue
+		}
+
+		positions[v.PlaylistId]++
+		rows = append(rows, []string{
+			pl.Name,
+			pl.SpotifyId,
+			strconv.Itoa(positions[v.PlaylistId]),
+			v.Name,
+			v.Artist,
+			v.Album,
+			v.SpotifyId,
+			v.Isrc,
+			v.AddedAtToPlaylist,
+		})
+	}
+
+	fname := fmt.Sprintf("spotify-%s+%s.csv", bp.UserId, bp.Started.Format(time.RFC3339))
+	return s.writeFile(fname, rows)
+}
+
+func (s *csvBackupService) DoYoutube(bp *backup.Backup, p *[]backup.YoutubePlaylist, t *[]backup.YoutubeTrack) (err error) {
+	if !s.enabled {
+		log.Debug().Msg("csv_backup_action: action is not enabled")
+		return nil
+	}
+
+	playlists := make(map[int64]*backup.YoutubePlaylist)
+	for id := range *p {
+		playlists[(*p)[id].Id] = &(*p)[id]
+	}
+
+	rows := [][]string{{"Playlist", "Playlist ID", "Position", "Title", "Channel", "Youtube ID", "Added At"}}
+	positions := make(map[int64]int)
+	for _, v := range *t {
+		pl, ok := playlists[v.PlaylistId]
+		if !ok {
+			continue
+		}
+
+		positions[v.PlaylistId]++
+		rows = append(rows, []string{
+			pl.Name,
+			pl.YoutubeId,
+			strconv.Itoa(positions[v.PlaylistId]),
+			v.Name,
+			v.ChannelTitle,
+			v.YoutubeId,
+			v.AddedAtToPlaylist,
+		})
+	}
+
+	fname := fmt.Sprintf("youtube-%s+%s.csv", bp.UserId, bp.Started.Format(time.RFC3339))
+	return s.writeFile(fname, rows)
+}
+
+// Albums, artists and shows have different fields, so the shared
+// columns are used and type tells them apart.
+func (s *csvBackupService) DoLibrary(bp *backup.Backup, l *backup.Library) (err error) {
+	if !s.enabled {
+		log.Debug().Msg("csv_backup_action: action is not enabled")
+		return nil
+	}
+
+	if l.IsEmpty() {
+		log.Debug().Msg("csv_backup_action: library is empty")
+		return nil
+	}
+
+	rows := [][]string{{"Type", "Name", "Artists", "Spotify ID", "Added At"}}
+	for _, v := range l.Albums {
+		rows = append(rows, []string{"album", v.Name, v.Artist, v.SpotifyId, v.AddedAt})
+	}
+
+	for _, v := range l.Artists {
+		rows = append(rows, []string{"artist", v.Name, "", v.SpotifyId, ""})
+	}
+
+	for _, v := range l.Shows {
+		rows = append(rows, []string{"show", v.Name, v.Publisher, v.SpotifyId, v.AddedAt})
+	}
+
+	fname := fmt.Sprintf("spotify-library-%s+%s.csv", bp.UserId, bp.Started.Format(time.RFC3339))
+	return s.writeFile(fname, rows)
+}
+
+func (s *csvBackupService) writeFile(fname string, rows [][]string) (err error) {
+	var buf bytes.Buffer
+	buf.WriteString(utf8Bom)
+
+	w := csv.NewWriter(&buf)
+	err = w.WriteAll(rows)
+	if err != nil {
+		log.Error().Err(err).Msg("csv_backup_action: failed to write")
+		return
+	}
+
+	data, err := s.artifact.Encode(buf.Bytes())
+	if err != nil {
+		log.Error().Err(err).Msg("csv_backup_action: failed to encode artifact")
+		return
+	}
+
+	fpath := path.Join(s.dir, fname+s.artifact.Extension())
+
+	f, err := os.OpenFile(fpath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+	if err != nil {
+		log.Error().Err(err).Msgf("csv_backup_action: failed to open file at %s", fpath)
+		return
+	}
+	defer f.Close()
+
+	_, err = f.Write(data)
+	if err != nil {
+		log.Error().Err(err).Msg("csv_backup_action: failed to write")
+		return
+	}
+
+	log.Debug().Msgf("csv_backup_action: wrote %d rows to %s", len(rows), fpath)
+
+	return
+}
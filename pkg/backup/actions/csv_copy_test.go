@@ -0,0 +1,125 @@
+package actions
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hoffs/crispy-musicular/pkg/artifact"
+	"github.com/hoffs/crispy-musicular/pkg/backup"
+	"github.com/stretchr/testify/require"
+)
+
+func newPlainEncoder(t *testing.T) artifact.Encoder {
+	enc, err := artifact.NewEncoder(artifact.Options{})
+	require.NoError(t, err)
+	return enc
+}
+
+func readCsv(t *testing.T, fpath string) (raw string, rows [][]string) {
+	data, err := os.ReadFile(fpath)
+	require.NoError(t, err)
+
+	raw = string(data)
+	require.True(t, strings.HasPrefix(raw, utf8Bom))
+	raw = strings.TrimPrefix(raw, utf8Bom)
+
+	rows, err = csv.NewReader(strings.NewReader(raw)).ReadAll()
+	require.NoError(t, err)
+	return
+}
+
+func TestCsvDo(t *testing.T) {
+	dir := t.TempDir()
+	s := &csvBackupService{enabled: true, dir: dir, artifact: newPlainEncoder(t)}
+
+	bp := &backup.Backup{UserId: "user", Started: time.Unix(0, 0).UTC()}
+	p := []backup.Playlist{{Id: 1, SpotifyId: "P1", Name: `Rock, "Live"`}, {Id: 2, SpotifyId: "P2", Name: "Žąsys"}}
+	tr := []backup.Track{
+		{PlaylistId: 1, SpotifyId: "T1", Isrc: "I1", Name: "First", Artist: "A, B", Album: "Album", AddedAtToPlaylist: "2021-01-01T00:00:00Z"},
+		{PlaylistId: 2, SpotifyId: "T2", Name: "Other", Artist: "C", Album: "Album"},
+		{PlaylistId: 1, SpotifyId: "T3", Name: "Second", Artist: "D", Album: "Album"},
+		// tracks of playlists that are not part of the backup are skipped
+		{PlaylistId: 3, SpotifyId: "T4", Name: "Skipped"},
+	}
+
+	err := s.Do(bp, &p, &tr)
+	require.NoError(t, err)
+
+	raw, rows := readCsv(t, filepath.Join(dir, "spotify-user+1970-01-01T00:00:00Z.csv"))
+	require.Contains(t, raw, `"Rock, ""Live""",P1,1,First,"A, B"`)
+	require.Equal(t, [][]string{
+		{"Playlist", "Playlist ID", "Position", "Track", "Artists", "Album", "Spotify ID", "ISRC", "Added At"},
+		{`Rock, "Live"`, "P1", "1", "First", "A, B", "Album", "T1", "I1", "2021-01-01T00:00:00Z"},
+		{"Žąsys", "P2", "1", "Other", "C", "Album", "T2", "", ""},
+		{`Rock, "Live"`, "P1", "2", "Second", "D", "Album", "T3", "", ""},
+	}, rows)
+
+	// files are never overwritten
+	err = s.Do(bp, &p, &tr)
+	require.Error(t, err)
+}
+
+func TestCsvDoYoutube(t *testing.T) {
+	dir := t.TempDir()
+	s := &csvBackupService{enabled: true, dir: dir, artifact: newPlainEncoder(t)}
+
+	bp := &backup.Backup{UserId: "user", Started: time.Unix(0, 0).UTC()}
+	p := []backup.YoutubePlaylist{{Id: 1, YoutubeId: "Y1", Name: "Liked"}}
+	tr := []backup.YoutubeTrack{{PlaylistId: 1, YoutubeId: "V1", Name: "Video, \"live\"", ChannelTitle: "Channel", AddedAtToPlaylist: "2021-01-01T00:00:00Z"}}
+
+	err := s.DoYoutube(bp, &p, &tr)
+	require.NoError(t, err)
+
+	_, rows := readCsv(t, filepath.Join(dir, "youtube-user+1970-01-01T00:00:00Z.csv"))
+	require.Equal(t, [][]string{
+		{"Playlist", "Playlist ID", "Position", "Title", "Channel", "Youtube ID", "Added At"},
+		{"Liked", "Y1", "1", "Video, \"live\"", "Channel", "V1", "2021-01-01T00:00:00Z"},
+	}, rows)
+}
+
+func TestCsvDoLibrary(t *testing.T) {
+	dir := t.TempDir()
+	s := &csvBackupService{enabled: true, dir: dir, artifact: newPlainEncoder(t)}
+	bp := &backup.Backup{UserId: "user", Started: time.Unix(0, 0).UTC()}
+
+	// empty library doesn't create a file
+	err := s.DoLibrary(bp, &backup.Library{})
+	require.NoError(t, err)
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, files)
+
+	l := &backup.Library{
+		Albums:  []backup.Album{{SpotifyId: "A1", Name: "Album", Artist: "Artist", AddedAt: "2021"}},
+		Artists: []backup.Artist{{SpotifyId: "R1", Name: "Artist"}},
+	}
+	err = s.DoLibrary(bp, l)
+	require.NoError(t, err)
+
+	_, rows := readCsv(t, filepath.Join(dir, "spotify-library-user+1970-01-01T00:00:00Z.csv"))
+	require.Equal(t, [][]string{
+		{"Type", "Name", "Artists", "Spotify ID", "Added At"},
+		{"album", "Album", "Artist", "A1", "2021"},
+		{"artist", "Artist", "", "R1", ""},
+	}, rows)
+}
+
+func TestCsvDoEncoded(t *testing.T) {
+	dir := t.TempDir()
+	enc, err := artifact.NewEncoder(artifact.Options{Compression: artifact.CompressionGzip})
+	require.NoError(t, err)
+	s := &csvBackupService{enabled: true, dir: dir, artifact: enc}
+
+	bp := &backup.Backup{UserId: "user", Started: time.Unix(0, 0).UTC()}
+	err = s.Do(bp, &[]backup.Playlist{{Id: 1, SpotifyId: "P1", Name: "Name"}}, &[]backup.Track{{PlaylistId: 1, SpotifyId: "T1", Name: "Track"}})
+	require.NoError(t, err)
+
+	data, err := artifact.DecodeFile(filepath.Join(dir, "spotify-user+1970-01-01T00:00:00Z.csv.gz"), artifact.DecodeOptions{})
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(string(data), utf8Bom))
+	require.Contains(t, string(data), "Name,P1,1,Track")
+}
@@ -0,0 +1,484 @@
+package actions
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/hoffs/crispy-musicular/pkg/backup"
+	"github.com/hoffs/crispy-musicular/pkg/config"
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+)
+
+type GitBackupAction interface {
+	Do(bp *backup.Backup, p *[]backup.Playlist, t *[]backup.Track) error
+	DoYoutube(bp *backup.Backup, p *[]backup.YoutubePlaylist, t *[]backup.YoutubeTrack) error
+	DoLibrary(bp *backup.Backup, l *backup.Library) error
+}
+
+type gitBackupService struct {
+	enabled   bool
+	dir       string
+	remoteUrl string
+	user      string
+	password  string
+	// repository is not safe for concurrent use
+	m sync.Mutex
+}
+
+const (
+	gitRemoteName  = "origin"
+	gitLibraryFile = "spotify-library.yaml"
+)
+
+func NewGitBackupAction(conf *config.AppConfig) (GitBackupAction, error) {
+	act := &gitBackupService{
+		enabled:   conf.GitActionEnabled,
+		dir:       conf.GitDir,
+		remoteUrl: conf.GitRemoteUrl,
+		user:      conf.GitUser,
+		password:  conf.GitPassword,
+	}
+
+	// files have to stay readable for diffs, so they are never compressed or encrypted
+	if act.enabled && (len(conf.ArtifactAgeRecipients) > 0 || conf.ArtifactPassphrase != "") {
+		log.Warn().Msg("git_backup_action: artifact encryption is configured, but git repository is never encrypted")
+	}
+
+	if act.enabled {
+		_, err := act.openRepository()
+		return act, err
+	}
+
+	return act, nil
+}
+
+// Playlists are stored as one file per playlist named by id, so renaming
+// a playlist shows up as a change instead of a new file. Tracks are sorted
+// so that reordering doesn't create noise in diffs.
+type gitPlaylist struct {
+	Id          string     `yaml:"id"`
+	Name        string     `yaml:"name"`
+	Owner       string     `yaml:"owner,omitempty"`
+	Description string     `yaml:"description,omitempty"`
+	Tracks      []gitTrack `yaml:"tracks"`
+}
+
+type gitTrack struct {
+	Id     string `yaml:"id"`
+	Name   string `yaml:"name"`
+	Artist string `yaml:"artist,omitempty"`
+	Album  string `yaml:"album,omitempty"`
+}
+
+type gitLibrary struct {
+	Albums  []gitTrack `yaml:"albums"`
+	Artists []gitTrack `yaml:"artists"`
+	Shows   []gitTrack `yaml:"shows"`
+}
+
+func (s *gitBackupService) Do(bp *backup.Backup, p *[]backup.Playlist, t *[]backup.Track) (err error) {
+	if !s.enabled {
+		log.Debug().Msg("git_backup_action: action is not enabled")
+		return nil
+	}
+
+	tracks := make(map[int64][]gitTrack)
+	for _, v := range *t {
+		id := v.SpotifyId
+		if id == "" {
+			id = v.Uri // local files don't have ids
+		}
+
+		tracks[v.PlaylistId] = append(tracks[v.PlaylistId], gitTrack{id, v.Name, v.Artist, v.Album})
+	}
+
+	playlists := make([]gitPlaylist, 0, len(*p))
+	for _, v := range *p {
+		playlists = append(playlists, gitPlaylist{v.SpotifyId, v.Name, v.OwnerName, v.Description, tracks[v.Id]})
+	}
+
+	return s.commitPlaylists(bp, "spotify", "Spotify", playlists)
+}
+
+// Music metadata is preferred when it exists, same as for playlist files.
+func (s *gitBackupService) DoYoutube(bp *backup.Backup, p *[]backup.YoutubePlaylist, t *[]backup.YoutubeTrack) (err error) {
+	if !s.enabled {
+		log.Debug().Msg("git_backup_action: action is not enabled")
+		return nil
+	}
+
+	tracks := make(map[int64][]gitTrack)
+	for _, v := range *t {
+		gt := gitTrack{v.YoutubeId, v.Name, v.ChannelTitle, ""}
+		if v.MusicTitle != "" {
+			gt = gitTrack{v.YoutubeId, v.MusicTitle, v.MusicArtist, v.MusicAlbum}
+		}
+
+		tracks[v.PlaylistId] = append(tracks[v.PlaylistId], gt)
+	}
+
+	playlists := make([]gitPlaylist, 0, len(*p))
+	for _, v := range *p {
+		playlists = append(playlists, gitPlaylist{v.YoutubeId, v.Name, v.ChannelTitle, v.Description, tracks[v.Id]})
+	}
+
+	return s.commitPlaylists(bp, "youtube", "Youtube", playlists)
+}
+
+func (s *gitBackupService) DoLibrary(bp *backup.Backup, l *backup.Library) (err error) {
+	if !s.enabled {
+		log.Debug().Msg("git_backup_action: action is not enabled")
+		return nil
+	}
+
+	if l.IsEmpty() {
+		return s.removeLibrary(bp)
+	}
+
+	lib := gitLibrary{}
+	for _, v := range l.Albums {
+		lib.Albums = append(lib.Albums, gitTrack{Id: v.SpotifyId, Name: v.Name, Artist: v.Artist})
+	}
+
+	for _, v := range l.Artists {
+		lib.Artists = append(lib.Artists, gitTrack{Id: v.SpotifyId, Name: v.Name})
+	}
+
+	for _, v := range l.Shows {
+		lib.Shows = append(lib.Shows, gitTrack{Id: v.SpotifyId, Name: v.Name, Artist: v.Publisher})
+	}
+
+	sortGitTracks(lib.Albums)
+	sortGitTracks(lib.Artists)
+	sortGitTracks(lib.Shows)
+
+	data, err := yaml.Marshal(&lib)
+	if err != nil {
+		log.Error().Err(err).Msg("git_backup_action: failed to marshal library")
+		return
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	repo, err := s.openRepository()
+	if err != nil {
+		return
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		return
+	}
+
+	err = ioutil.WriteFile(filepath.Join(s.dir, gitLibraryFile), data, 0666)
+	if err != nil {
+		log.Error().Err(err).Msgf("git_backup_action: failed to write %s", gitLibraryFile)
+		return
+	}
+
+	_, err = wt.Add(gitLibraryFile)
+	if err != nil {
+		return
+	}
+
+	summary := fmt.Sprintf("%d albums, %d artists, %d shows", len(lib.Albums), len(lib.Artists), len(lib.Shows))
+	return s.commit(repo, wt, fmt.Sprintf("Spotify library backup of %s at %s\n\n%s\n", bp.UserId, bp.Started.Format(time.RFC3339), summary))
+}
+
+// Library file of an earlier backup is removed, otherwise it would keep
+// showing the last library which wasn't empty.
+func (s *gitBackupService) removeLibrary(bp *backup.Backup) (err error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	_, err = os.Stat(filepath.Join(s.dir, gitLibraryFile))
+	if errors.Is(err, os.ErrNotExist) {
+		log.Debug().Msg("git_backup_action: library is empty")
+		return nil
+	}
+
+	if err != nil {
+		return
+	}
+
+	repo, err := s.openRepository()
+	if err != nil {
+		return
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		return
+	}
+
+	_, err = wt.Remove(gitLibraryFile)
+	if err != nil {
+		log.Error().Err(err).Msgf("git_backup_action: failed to remove %s", gitLibraryFile)
+		return
+	}
+
+	return s.commit(repo, wt, fmt.Sprintf("Spotify library backup of %s at %s\n\nlibrary is empty\n", bp.UserId, bp.Started.Format(time.RFC3339)))
+}
+
+// Playlists of a platform are kept in its own directory, files of playlists
+// which are not in the backup anymore are removed.
+func (s *gitBackupService) commitPlaylists(bp *backup.Backup, dir string, platform string, playlists []gitPlaylist) (err error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	repo, err := s.openRepository()
+	if err != nil {
+		return
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		return
+	}
+
+	err = os.MkdirAll(filepath.Join(s.dir, dir), os.ModePerm)
+	if err != nil {
+		log.Error().Err(err).Msgf("git_backup_action: failed to create directory %s", dir)
+		return
+	}
+
+	existing, err := ioutil.ReadDir(filepath.Join(s.dir, dir))
+	if err != nil {
+		return
+	}
+
+	var changes []string
+	written := make(map[string]bool)
+	for _, p := range playlists {
+		sortGitTracks(p.Tracks)
+
+		name := path.Join(dir, sanitizeFileName(p.Id)+".yaml")
+		previous, err := s.readPlaylist(name)
+		if err != nil {
+			log.Warn().Err(err).Msgf("git_backup_action: failed to read previous version of %s", name)
+		}
+
+		data, err := yaml.Marshal(&p)
+		if err != nil {
+			log.Error().Err(err).Msg("git_backup_action: failed to marshal playlist")
+			return err
+		}
+
+		err = ioutil.WriteFile(filepath.Join(s.dir, name), data, 0666)
+		if err != nil {
+			log.Error().Err(err).Msgf("git_backup_action: failed to write %s", name)
+			return err
+		}
+
+		_, err = wt.Add(name)
+		if err != nil {
+			return err
+		}
+
+		written[name] = true
+		if change := describePlaylistChange(previous, &p); change != "" {
+			changes = append(changes, change)
+		}
+	}
+
+	for _, f := range existing {
+		name := path.Join(dir, f.Name())
+		if f.IsDir() || written[name] {
+			continue
+		}
+
+		previous, _ := s.readPlaylist(name)
+		_, err = wt.Remove(name)
+		if err != nil {
+			log.Error().Err(err).Msgf("git_backup_action: failed to remove %s", name)
+			return
+		}
+
+		if previous != nil {
+			changes = append(changes, fmt.Sprintf("%s: removed playlist", previous.Name))
+		}
+	}
+
+	sort.Strings(changes)
+	msg := fmt.Sprintf("%s backup of %s at %s\n", platform, bp.UserId, bp.Started.Format(time.RFC3339))
+	if len(changes) > 0 {
+		msg += "\n" + strings.Join(changes, "\n") + "\n"
+	}
+
+	return s.commit(repo, wt, msg)
+}
+
+func (s *gitBackupService) readPlaylist(name string) (p *gitPlaylist, err error) {
+	data, err := ioutil.ReadFile(filepath.Join(s.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return
+	}
+
+	p = &gitPlaylist{}
+	err = yaml.Unmarshal(data, p)
+	return
+}
+
+// Tracks can be in a playlist multiple times, so they are compared as multisets.
+func describePlaylistChange(previous *gitPlaylist, current *gitPlaylist) string {
+	if previous == nil {
+		return fmt.Sprintf("%s: new playlist, %d tracks", current.Name, len(current.Tracks))
+	}
+
+	counts := make(map[string]int)
+	for _, t := range previous.Tracks {
+		counts[t.Id]++
+	}
+
+	added := 0
+	for _, t := range current.Tracks {
+		if counts[t.Id] > 0 {
+			counts[t.Id]--
+		} else {
+			added++
+		}
+	}
+
+	removed := 0
+	for _, c := range counts {
+		removed += c
+	}
+
+	if added == 0 && removed == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("%s: +%d -%d", current.Name, added, removed)
+}
+
+func sortGitTracks(t []gitTrack) {
+	sort.SliceStable(t, func(i, j int) bool {
+		if t[i].Artist != t[j].Artist {
+			return t[i].Artist < t[j].Artist
+		}
+
+		if t[i].Name != t[j].Name {
+			return t[i].Name < t[j].Name
+		}
+
+		return t[i].Id < t[j].Id
+	})
+}
+
+// Nothing is committed if files didn't change, but it is still
+// pushed in case previous push has failed.
+func (s *gitBackupService) commit(repo *git.Repository, wt *git.Worktree, msg string) (err error) {
+	status, err := wt.Status()
+	if err != nil {
+		return
+	}
+
+	if status.IsClean() {
+		log.Debug().Msg("git_backup_action: nothing changed")
+	} else {
+		hash, err := wt.Commit(msg, &git.CommitOptions{Author: &object.Signature{
+			Name:  "crispy-musicular",
+			Email: "crispy-musicular@localhost",
+			When:  time.Now(),
+		}})
+		if err != nil {
+			log.Error().Err(err).Msg("git_backup_action: failed to commit")
+			return err
+		}
+
+		log.Debug().Msgf("git_backup_action: created commit %s", hash)
+	}
+
+	return s.push(repo)
+}
+
+func (s *gitBackupService) push(repo *git.Repository) (err error) {
+	if s.remoteUrl == "" {
+		return
+	}
+
+	var auth *githttp.BasicAuth
+	if s.user != "" || s.password != "" {
+		auth = &githttp.BasicAuth{Username: s.user, Password: s.password}
+	}
+
+	opts := &git.PushOptions{RemoteName: gitRemoteName}
+	if auth != nil {
+		opts.Auth = auth
+	}
+
+	err = repo.Push(opts)
+	if err == git.NoErrAlreadyUpToDate {
+		return nil
+	}
+
+	if err != nil {
+		log.Error().Err(err).Msgf("git_backup_action: failed to push to %s", s.remoteUrl)
+	}
+
+	return
+}
+
+// Creates repository if it doesn't exist and keeps remote in sync with config.
+// Remote is only managed when gitRemoteUrl is set, so that a remote added by hand is kept.
+func (s *gitBackupService) openRepository() (repo *git.Repository, err error) {
+	repo, err = git.PlainOpen(s.dir)
+	if err == git.ErrRepositoryNotExists {
+		err = os.MkdirAll(s.dir, os.ModePerm)
+		if err != nil {
+			return
+		}
+
+		repo, err = git.PlainInit(s.dir, false)
+	}
+
+	if err != nil {
+		log.Error().Err(err).Msgf("git_backup_action: failed to open repository at %s", s.dir)
+		return
+	}
+
+	if s.remoteUrl == "" {
+		return
+	}
+
+	remote, err := repo.Remote(gitRemoteName)
+	if err != nil && err != git.ErrRemoteNotFound {
+		return
+	}
+
+	if remote != nil && remote.Config().URLs[0] == s.remoteUrl {
+		return repo, nil
+	}
+
+	if remote != nil {
+		err = repo.DeleteRemote(gitRemoteName)
+		if err != nil {
+			return
+		}
+	}
+
+	_, err = repo.CreateRemote(&gitconfig.RemoteConfig{Name: gitRemoteName, URLs: []string{s.remoteUrl}})
+	if err != nil {
+		return
+	}
+
+	return repo, nil
+}
@@ -0,0 +1,141 @@
+package actions
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/hoffs/crispy-musicular/pkg/backup"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGitDo(t *testing.T) {
+	dir := t.TempDir()
+	remote := t.TempDir()
+	_, err := git.PlainInit(remote, true)
+	require.NoError(t, err)
+
+	s := &gitBackupService{enabled: true, dir: dir, remoteUrl: remote}
+	bp := &backup.Backup{UserId: "user", Started: time.Unix(0, 0).UTC()}
+
+	p := []backup.Playlist{{Id: 1, SpotifyId: "a", Name: "First"}, {Id: 2, SpotifyId: "b", Name: "Second"}}
+	tr := []backup.Track{
+		{SpotifyId: "2", Name: "B", Artist: "Z", PlaylistId: 1},
+		{SpotifyId: "1", Name: "A", Artist: "Z", PlaylistId: 1},
+		{SpotifyId: "1", Name: "A", Artist: "Z", PlaylistId: 2},
+	}
+
+	err = s.Do(bp, &p, &tr)
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(dir, "spotify", "a.yaml"))
+	require.NoError(t, err)
+	require.Equal(t, "id: a\nname: First\ntracks:\n"+
+		"    - id: \"1\"\n      name: A\n      artist: Z\n"+
+		"    - id: \"2\"\n      name: B\n      artist: Z\n", string(data))
+
+	// same data doesn't create commits
+	err = s.Do(bp, &p, &tr)
+	require.NoError(t, err)
+
+	p = p[:1]
+	tr = []backup.Track{
+		{SpotifyId: "1", Name: "A", Artist: "Z", PlaylistId: 1},
+		{SpotifyId: "3", Name: "C", Artist: "Z", PlaylistId: 1},
+		{SpotifyId: "4", Name: "D", Artist: "Z", PlaylistId: 1},
+	}
+	err = s.Do(bp, &p, &tr)
+	require.NoError(t, err)
+
+	_, err = os.Stat(filepath.Join(dir, "spotify", "b.yaml"))
+	require.True(t, os.IsNotExist(err))
+
+	repo, err := git.PlainOpen(dir)
+	require.NoError(t, err)
+
+	commits, err := repo.Log(&git.LogOptions{})
+	require.NoError(t, err)
+
+	var messages []string
+	commits.ForEach(func(c *object.Commit) error {
+		messages = append(messages, c.Message)
+		return nil
+	})
+
+	require.Equal(t, []string{
+		"Spotify backup of user at 1970-01-01T00:00:00Z\n\nFirst: +2 -1\nSecond: removed playlist\n",
+		"Spotify backup of user at 1970-01-01T00:00:00Z\n\nFirst: new playlist, 2 tracks\nSecond: new playlist, 1 tracks\n",
+	}, messages)
+
+	head, err := repo.Head()
+	require.NoError(t, err)
+
+	pushed, err := git.PlainOpen(remote)
+	require.NoError(t, err)
+	ref, err := pushed.Reference(head.Name(), true)
+	require.NoError(t, err)
+	require.Equal(t, head.Hash(), ref.Hash())
+}
+
+func TestDescribePlaylistChange(t *testing.T) {
+	prev := &gitPlaylist{Name: "P", Tracks: []gitTrack{{Id: "1"}, {Id: "1"}, {Id: "2"}}}
+
+	require.Equal(t, "", describePlaylistChange(prev, &gitPlaylist{Name: "P", Tracks: []gitTrack{{Id: "2"}, {Id: "1"}, {Id: "1"}}}))
+	require.Equal(t, "P: +1 -1", describePlaylistChange(prev, &gitPlaylist{Name: "P", Tracks: []gitTrack{{Id: "1"}, {Id: "2"}, {Id: "3"}}}))
+	require.Equal(t, "P: new playlist, 0 tracks", describePlaylistChange(nil, &gitPlaylist{Name: "P"}))
+}
+
+func TestGitDoLibrary(t *testing.T) {
+	dir := t.TempDir()
+	s := &gitBackupService{enabled: true, dir: dir}
+	bp := &backup.Backup{UserId: "user", Started: time.Unix(0, 0).UTC()}
+
+	// nothing is written before library has anything in it
+	err := s.DoLibrary(bp, &backup.Library{})
+	require.NoError(t, err)
+	require.NoFileExists(t, filepath.Join(dir, gitLibraryFile))
+
+	err = s.DoLibrary(bp, &backup.Library{Albums: []backup.Album{{SpotifyId: "a", Name: "Album"}}})
+	require.NoError(t, err)
+	require.FileExists(t, filepath.Join(dir, gitLibraryFile))
+
+	err = s.DoLibrary(bp, &backup.Library{})
+	require.NoError(t, err)
+	require.NoFileExists(t, filepath.Join(dir, gitLibraryFile))
+
+	repo, err := git.PlainOpen(dir)
+	require.NoError(t, err)
+	head, err := repo.Head()
+	require.NoError(t, err)
+	c, err := repo.CommitObject(head.Hash())
+	require.NoError(t, err)
+	require.Equal(t, "Spotify library backup of user at 1970-01-01T00:00:00Z\n\nlibrary is empty\n", c.Message)
+}
+
+func TestGitKeepsRemoteWithoutUrl(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	require.NoError(t, err)
+	_, err = repo.CreateRemote(&gitconfig.RemoteConfig{Name: gitRemoteName, URLs: []string{"https://example.com/repo.git"}})
+	require.NoError(t, err)
+
+	s := &gitBackupService{enabled: true, dir: dir}
+	repo, err = s.openRepository()
+	require.NoError(t, err)
+
+	remote, err := repo.Remote(gitRemoteName)
+	require.NoError(t, err)
+	require.Equal(t, []string{"https://example.com/repo.git"}, remote.Config().URLs)
+
+	s.remoteUrl = "https://example.com/other.git"
+	repo, err = s.openRepository()
+	require.NoError(t, err)
+
+	remote, err = repo.Remote(gitRemoteName)
+	require.NoError(t, err)
+	require.Equal(t, []string{"https://example.com/other.git"}, remote.Config().URLs)
+}
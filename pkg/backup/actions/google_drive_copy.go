@@ -2,13 +2,19 @@ package actions
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	gdrive "google.golang.org/api/drive/v3"
 
+	"github.com/hoffs/crispy-musicular/pkg/artifact"
 	"github.com/hoffs/crispy-musicular/pkg/auth"
 	"github.com/hoffs/crispy-musicular/pkg/backup"
 	"github.com/hoffs/crispy-musicular/pkg/config"
@@ -19,17 +25,47 @@ import (
 type GoogleDriveBackupAction interface {
 	Do(bp *backup.Backup, p *[]backup.Playlist, t *[]backup.Track) error
 	DoYoutube(bp *backup.Backup, p *[]backup.YoutubePlaylist, t *[]backup.YoutubeTrack) error
+	DoLibrary(bp *backup.Backup, l *backup.Library) error
+	DoDocument(d *backup.Document) error
 }
 
 type googleDriveBackupService struct {
-	enabled   bool
-	dir       string
-	auth      auth.Service
-	driveAuth drive.Authenticator
+	enabled       bool
+	legacy        bool
+	dir           string
+	auth          auth.Service
+	driveAuth     drive.Authenticator
+	skipUnchanged bool
+	// nil if retention is disabled
+	retention *backup.RetentionPolicy
+	artifact  artifact.Encoder
 }
 
 func NewGoogleDriveBackupAction(conf *config.AppConfig, auth auth.Service) (a GoogleDriveBackupAction, err error) {
-	a = &googleDriveBackupService{conf.DriveActionEnabled, conf.DriveDir, auth, drive.NewAuthenticator(conf.DriveId, conf.DriveSecret, conf.DriveCallback)}
+	s := &googleDriveBackupService{
+		enabled:       conf.DriveActionEnabled,
+		legacy:        conf.JsonLegacyFormat,
+		dir:           conf.DriveDir,
+		auth:          auth,
+		driveAuth:     drive.NewAuthenticator(conf.DriveId, conf.DriveSecret, conf.DriveCallback),
+		skipUnchanged: conf.DriveSkipUnchanged,
+	}
+
+	s.artifact, err = artifact.NewEncoderFromConfig(conf)
+	if err != nil {
+		return
+	}
+
+	if conf.DriveRetentionEnabled {
+		s.retention = &backup.RetentionPolicy{
+			KeepLast:        conf.DriveRetentionKeepLast,
+			KeepDailyDays:   conf.DriveRetentionKeepDailyDays,
+			KeepWeeklyWeeks: conf.DriveRetentionKeepWeeklyWeeks,
+			KeepMonthly:     conf.DriveRetentionKeepMonthly,
+		}
+	}
+
+	a = s
 	return
 }
 
@@ -41,12 +77,18 @@ type googleDriveBackup struct {
 	Tracks    *[]backup.Track
 }
 
+// Files are the same as files written by JSON action,
+// legacy files are only stored if jsonLegacyFormat is set
 func (s *googleDriveBackupService) Do(bp *backup.Backup, p *[]backup.Playlist, t *[]backup.Track) (err error) {
 	if !s.enabled {
 		log.Debug().Msg("google_drive_backup_action: action is not enabled")
 		return nil
 	}
 
+	if !s.legacy {
+		return nil
+	}
+
 	backup := &googleDriveBackup{bp, p, t}
 	data, err := json.Marshal(backup)
 	if err != nil {
@@ -54,8 +96,13 @@ func (s *googleDriveBackupService) Do(bp *backup.Backup, p *[]backup.Playlist, t
 		return
 	}
 
+	hash, err := spotifyContentHash(p, t)
+	if err != nil {
+		return
+	}
+
 	fname := fmt.Sprintf("spotify-%s+%s.json", bp.UserId, bp.Started.Format(time.RFC3339))
-	return s.storeFile(fname, "Spotify playlists backup", data)
+	return s.storeFile(fname, "Spotify playlists backup", data, hash)
 }
 
 type youtubeGoogleDriveBackup struct {
@@ -70,6 +117,10 @@ func (s *googleDriveBackupService) DoYoutube(bp *backup.Backup, p *[]backup.Yout
 		return nil
 	}
 
+	if !s.legacy {
+		return nil
+	}
+
 	backup := &youtubeGoogleDriveBackup{bp, p, t}
 	data, err := json.Marshal(backup)
 	if err != nil {
@@ -77,11 +128,77 @@ func (s *googleDriveBackupService) DoYoutube(bp *backup.Backup, p *[]backup.Yout
 		return
 	}
 
+	hash, err := youtubeContentHash(p, t)
+	if err != nil {
+		return
+	}
+
 	fname := fmt.Sprintf("youtube-%s+%s.json", bp.UserId, bp.Started.Format(time.RFC3339))
-	return s.storeFile(fname, "Youtube playlists backup", data)
+	return s.storeFile(fname, "Youtube playlists backup", data, hash)
+}
+
+type libraryGoogleDriveBackup struct {
+	Backup  *backup.Backup
+	Library *backup.Library
+}
+
+func (s *googleDriveBackupService) DoLibrary(bp *backup.Backup, l *backup.Library) (err error) {
+	if !s.enabled {
+		log.Debug().Msg("google_drive_backup_action: action is not enabled")
+		return nil
+	}
+
+	if !s.legacy {
+		return nil
+	}
+
+	if l.IsEmpty() {
+		log.Debug().Msg("google_drive_backup_action: library is empty")
+		return nil
+	}
+
+	backup := &libraryGoogleDriveBackup{bp, l}
+	data, err := json.Marshal(backup)
+	if err != nil {
+		log.Error().Err(err).Msg("google_drive_backup_action: failed to marshal json")
+		return
+	}
+
+	hash, err := libraryContentHash(l)
+	if err != nil {
+		return
+	}
+
+	fname := fmt.Sprintf("spotify-library-%s+%s.json", bp.UserId, bp.Started.Format(time.RFC3339))
+	return s.storeFile(fname, "Spotify library backup", data, hash)
+}
+
+func (s *googleDriveBackupService) DoDocument(d *backup.Document) (err error) {
+	if !s.enabled {
+		log.Debug().Msg("google_drive_backup_action: action is not enabled")
+		return nil
+	}
+
+	if s.legacy {
+		log.Debug().Msg("google_drive_backup_action: legacy format is enabled, skipping document")
+		return nil
+	}
+
+	data, err := json.Marshal(d)
+	if err != nil {
+		log.Error().Err(err).Msg("google_drive_backup_action: failed to marshal json")
+		return
+	}
+
+	hash, err := documentContentHash(d)
+	if err != nil {
+		return
+	}
+
+	return s.storeFile(documentFileName(d), "Backup document", data, hash)
 }
 
-func (s *googleDriveBackupService) storeFile(name string, description string, data []byte) (err error) {
+func (s *googleDriveBackupService) storeFile(name string, description string, data []byte, hash string) (err error) {
 	st, err := s.auth.GetState()
 	if err != nil {
 		return
@@ -91,6 +208,14 @@ func (s *googleDriveBackupService) storeFile(name string, description string, da
 		return errors.New("google_drive_backup_action: drive refresh token is not set")
 	}
 
+	data, err = s.artifact.Encode(data)
+	if err != nil {
+		log.Error().Err(err).Msg("google_drive_backup_action: failed to encode artifact")
+		return
+	}
+
+	name += s.artifact.Extension()
+
 	drive, err := s.driveAuth.FromRefreshToken(st.DriveRefreshToken)
 	if err != nil {
 		return
@@ -107,21 +232,39 @@ func (s *googleDriveBackupService) storeFile(name string, description string, da
 		return errors.New("google_drive_backup_action: got empty folder id")
 	}
 
-	file := &gdrive.File{
-		Name:        name,
-		Description: description,
-		MimeType:    "application/json",
-		Parents:     []string{folder.Id},
+	var files []*driveBackupFile
+	if s.skipUnchanged || s.retention != nil {
+		files, err = s.listBackupFiles(drive, folder.Id, name)
+		if err != nil {
+			log.Error().Err(err).Msg("google_drive_backup_action: failed to list files")
+			return
+		}
 	}
-	r := bytes.NewReader(data)
-	file, err = drive.Files.Create(file).Media(r).UseContentAsIndexableText(false).Fields("id,name").Do()
-	if err != nil {
-		log.Error().Err(err).Msg("google_drive_backup_action: failed to upload file")
+
+	if s.skipUnchanged && len(files) > 0 && files[0].hash == hash {
+		log.Debug().Msgf("google_drive_backup_action: content of %s is unchanged since %s, skipping upload", name, files[0].name)
 	} else {
+		file := &gdrive.File{
+			Name:          name,
+			Description:   description,
+			MimeType:      s.artifact.ContentType("application/json"),
+			Parents:       []string{folder.Id},
+			AppProperties: map[string]string{driveContentHashProperty: hash},
+		}
+		r := bytes.NewReader(data)
+		file, err = drive.Files.Create(file).Media(r).UseContentAsIndexableText(false).Fields("id,name").Do()
+		if err != nil {
+			log.Error().Err(err).Msg("google_drive_backup_action: failed to upload file")
+			return
+		}
+
 		log.Debug().Msgf("google_drive_backup_action: uploaded to google drive with id %s and name %s in folder %s", file.Id, file.Name, folder.Name)
+
+		_, created := parseDriveBackupName(name)
+		files = append([]*driveBackupFile{{file.Id, file.Name, hash, created}}, files...)
 	}
 
-	return
+	return s.deleteExpired(drive, files)
 }
 
 func (s *googleDriveBackupService) getOrCreateFolder(drive *gdrive.Service) (*gdrive.File, error) {
@@ -152,3 +295,180 @@ func (s *googleDriveBackupService) getOrCreateFolder(drive *gdrive.Service) (*gd
 
 	return created, nil
 }
+
+const driveContentHashProperty = "contentHash"
+
+type driveBackupFile struct {
+	id      string
+	name    string
+	hash    string
+	created time.Time
+}
+
+// Backup file names are <kind>-<user id>+<backup start time>.json with optional
+// artifact extensions, everything before "+" identifies the kind of backup
+// which retention is applied to.
+func parseDriveBackupName(name string) (series string, created time.Time) {
+	name = artifact.TrimExtension(name)
+	sep := strings.LastIndex(name, "+")
+	if sep == -1 || !strings.HasSuffix(name, ".json") {
+		return "", time.Time{}
+	}
+
+	created, err := time.Parse(time.RFC3339, strings.TrimSuffix(name[sep+1:], ".json"))
+	if err != nil {
+		return "", time.Time{}
+	}
+
+	return name[:sep], created
+}
+
+// Returns files of the same kind as the named file, newest first.
+// Files which don't look like backups are never returned.
+func (s *googleDriveBackupService) listBackupFiles(drive *gdrive.Service, folderId string, name string) (files []*driveBackupFile, err error) {
+	series, _ := parseDriveBackupName(name)
+
+	query := fmt.Sprintf("'%s' in parents and trashed = false", folderId)
+	call := drive.Files.List().Q(query).Fields("nextPageToken,files(id,name,appProperties)").PageSize(1000)
+	err = call.Pages(context.Background(), func(l *gdrive.FileList) error {
+		for _, f := range l.Files {
+			fs, created := parseDriveBackupName(f.Name)
+			if fs != series || created.IsZero() {
+				continue
+			}
+
+			files = append(files, &driveBackupFile{f.Id, f.Name, f.AppProperties[driveContentHashProperty], created})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].created.After(files[j].created)
+	})
+
+	return
+}
+
+func (s *googleDriveBackupService) deleteExpired(drive *gdrive.Service, files []*driveBackupFile) (err error) {
+	if s.retention == nil {
+		return
+	}
+
+	times := make([]time.Time, 0, len(files))
+	for _, f := range files {
+		times = append(times, f.created)
+	}
+
+	for id, keep := range s.retention.Kept(times, time.Now()) {
+		if keep {
+			continue
+		}
+
+		err = drive.Files.Delete(files[id].id).Do()
+		if err != nil {
+			log.Error().Err(err).Msgf("google_drive_backup_action: failed to delete expired file %s", files[id].name)
+			return
+		}
+
+		log.Debug().Msgf("google_drive_backup_action: deleted expired file %s", files[id].name)
+	}
+
+	return
+}
+
+// Content hashes leave out ids and timestamps which are different
+// for every backup, so only changes to actual content are noticed.
+func spotifyContentHash(p *[]backup.Playlist, t *[]backup.Track) (string, error) {
+	type content struct {
+		Playlist backup.Playlist
+		Tracks   []backup.Track
+	}
+
+	tracks := make(map[int64][]backup.Track)
+	for _, v := range *t {
+		id := v.PlaylistId
+		v.Id, v.PlaylistId, v.Created = 0, 0, time.Time{}
+		tracks[id] = append(tracks[id], v)
+	}
+
+	var c []content
+	for _, v := range *p {
+		id := v.Id
+		v.Id, v.Created = 0, time.Time{}
+		c = append(c, content{v, tracks[id]})
+	}
+
+	sort.SliceStable(c, func(i, j int) bool {
+		return c[i].Playlist.SpotifyId < c[j].Playlist.SpotifyId
+	})
+
+	return hashJson(c)
+}
+
+func youtubeContentHash(p *[]backup.YoutubePlaylist, t *[]backup.YoutubeTrack) (string, error) {
+	type content struct {
+		Playlist backup.YoutubePlaylist
+		Tracks   []backup.YoutubeTrack
+	}
+
+	tracks := make(map[int64][]backup.YoutubeTrack)
+	for _, v := range *t {
+		id := v.PlaylistId
+		v.Id, v.PlaylistId, v.Created = 0, 0, time.Time{}
+		tracks[id] = append(tracks[id], v)
+	}
+
+	var c []content
+	for _, v := range *p {
+		id := v.Id
+		v.Id, v.Created = 0, time.Time{}
+		c = append(c, content{v, tracks[id]})
+	}
+
+	sort.SliceStable(c, func(i, j int) bool {
+		return c[i].Playlist.YoutubeId < c[j].Playlist.YoutubeId
+	})
+
+	return hashJson(c)
+}
+
+func libraryContentHash(l *backup.Library) (string, error) {
+	c := backup.Library{}
+	for _, v := range l.Albums {
+		v.Id, v.Created = 0, time.Time{}
+		c.Albums = append(c.Albums, v)
+	}
+
+	for _, v := range l.Artists {
+		v.Id, v.Created = 0, time.Time{}
+		c.Artists = append(c.Artists, v)
+	}
+
+	for _, v := range l.Shows {
+		v.Id, v.Created = 0, time.Time{}
+		c.Shows = append(c.Shows, v)
+	}
+
+	return hashJson(&c)
+}
+
+// Document doesn't have ids, so only backup information other than scope has to be left out
+func documentContentHash(d *backup.Document) (string, error) {
+	c := *d
+	c.Backup = backup.DocumentBackup{Scope: d.Backup.Scope}
+	return hashJson(&c)
+}
+
+func hashJson(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:]), nil
+}
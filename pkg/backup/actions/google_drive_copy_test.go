@@ -0,0 +1,82 @@
+package actions
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hoffs/crispy-musicular/pkg/backup"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDriveBackupName(t *testing.T) {
+	series, created := parseDriveBackupName("spotify-library-user+2021-06-01T10:00:00Z.json")
+	require.Equal(t, "spotify-library-user", series)
+	require.Equal(t, time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC), created.UTC())
+
+	series, created = parseDriveBackupName("youtube-user+2021-06-01T10:00:00Z.json.zst.age")
+	require.Equal(t, "youtube-user", series)
+	require.False(t, created.IsZero())
+
+	series, created = parseDriveBackupName("notes.txt")
+	require.Equal(t, "", series)
+	require.True(t, created.IsZero())
+
+	series, created = parseDriveBackupName("spotify-user+yesterday.json")
+	require.Equal(t, "", series)
+	require.True(t, created.IsZero())
+}
+
+func TestSpotifyContentHashIgnoresIds(t *testing.T) {
+	p1 := []backup.Playlist{{Id: 1, SpotifyId: "a", Name: "A", Created: time.Unix(1, 0)}, {Id: 2, SpotifyId: "b", Name: "B"}}
+	t1 := []backup.Track{{Id: 1, SpotifyId: "t", PlaylistId: 1, Created: time.Unix(1, 0)}}
+
+	p2 := []backup.Playlist{{Id: 4, SpotifyId: "b", Name: "B"}, {Id: 3, SpotifyId: "a", Name: "A", Created: time.Unix(2, 0)}}
+	t2 := []backup.Track{{Id: 1, SpotifyId: "t", PlaylistId: 3, Created: time.Unix(2, 0)}}
+
+	h1, err := spotifyContentHash(&p1, &t1)
+	require.NoError(t, err)
+	h2, err := spotifyContentHash(&p2, &t2)
+	require.NoError(t, err)
+	require.Equal(t, h1, h2)
+
+	// same track in another playlist is a change
+	t2[0].PlaylistId = 4
+	h2, err = spotifyContentHash(&p2, &t2)
+	require.NoError(t, err)
+	require.NotEqual(t, h1, h2)
+}
+
+func TestDocumentContentHashIgnoresBackup(t *testing.T) {
+	d1 := &backup.Document{
+		Version: backup.DocumentVersion,
+		Backup:  backup.DocumentBackup{UserId: "user", Success: true, Started: time.Unix(1, 0), Finished: time.Unix(2, 0)},
+		Spotify: backup.DocumentSpotify{Playlists: []backup.DocumentPlaylist{{Id: "p", Tracks: []backup.DocumentTrack{{Id: "t"}}}}},
+	}
+	d2 := *d1
+	d2.Backup.Started, d2.Backup.Finished = time.Unix(10, 0), time.Unix(20, 0)
+
+	h1, err := documentContentHash(d1)
+	require.NoError(t, err)
+	h2, err := documentContentHash(&d2)
+	require.NoError(t, err)
+	require.Equal(t, h1, h2)
+	// backup itself is not changed
+	require.Equal(t, "user", d1.Backup.UserId)
+
+	d2.Spotify = backup.DocumentSpotify{Playlists: []backup.DocumentPlaylist{{Id: "p", Tracks: []backup.DocumentTrack{{Id: "other"}}}}}
+	h2, err = documentContentHash(&d2)
+	require.NoError(t, err)
+	require.NotEqual(t, h1, h2)
+}
+
+func TestDriveDocumentFileSeries(t *testing.T) {
+	d := &backup.Document{Backup: backup.DocumentBackup{UserId: "user", Started: time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC)}}
+	series, created := parseDriveBackupName(documentFileName(d) + ".gz")
+	require.Equal(t, "backup-user", series)
+	require.Equal(t, d.Backup.Started, created.UTC())
+
+	// partial backups are kept separately
+	d.Backup.Scope = backup.ScopeYoutube
+	series, _ = parseDriveBackupName(documentFileName(d))
+	require.Equal(t, "backup-youtube-user", series)
+}
@@ -0,0 +1,144 @@
+package actions
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hoffs/crispy-musicular/pkg/artifact"
+	"github.com/hoffs/crispy-musicular/pkg/backup"
+	"github.com/rs/zerolog/log"
+)
+
+// Stores a finished file, name already has the artifact extension
+type jsonUploader interface {
+	upload(name string, data []byte) error
+}
+
+// Builds files which are stored by JSON, S3, WebDAV and SFTP actions: the backup
+// document, or legacy files instead of it if jsonLegacyFormat is set. Files are
+// encoded as artifacts, actions embed it and only differ in how they upload files.
+type jsonPayload struct {
+	action   string
+	enabled  bool
+	legacy   bool
+	artifact artifact.Encoder
+	uploader jsonUploader
+}
+
+// File name of the versioned backup document, same for all actions storing it.
+// Partial backups have their scope in the name, so that they are not mistaken
+// for complete ones, e.g. by retention of uploaded files.
+func documentFileName(d *backup.Document) string {
+	if d.Backup.Scope != backup.ScopeAll && d.Backup.Scope != "" {
+		return fmt.Sprintf("backup-%s-%s+%s.json", d.Backup.Scope, d.Backup.UserId, d.Backup.Started.Format(time.RFC3339))
+	}
+
+	return fmt.Sprintf("backup-%s+%s.json", d.Backup.UserId, d.Backup.Started.Format(time.RFC3339))
+}
+
+// Legacy format, which is written instead of the document if jsonLegacyFormat is set.
+// Spotify, Youtube and library are written to separate files.
+type jsonBackup struct {
+	Backup    *backup.Backup
+	Playlists *[]backup.Playlist
+	Tracks    *[]backup.Track
+}
+
+type youtubeJsonBackup struct {
+	Backup    *backup.Backup
+	Playlists *[]backup.YoutubePlaylist
+	Tracks    *[]backup.YoutubeTrack
+}
+
+type libraryJsonBackup struct {
+	Backup  *backup.Backup
+	Library *backup.Library
+}
+
+func (s *jsonPayload) DoDocument(d *backup.Document) (err error) {
+	if !s.enabled {
+		log.Debug().Msgf("%s: action is not enabled", s.action)
+		return nil
+	}
+
+	if s.legacy {
+		log.Debug().Msgf("%s: legacy format is enabled, skipping document", s.action)
+		return nil
+	}
+
+	return s.store(documentFileName(d), d)
+}
+
+func (s *jsonPayload) Do(bp *backup.Backup, p *[]backup.Playlist, t *[]backup.Track) (err error) {
+	if !s.enabled {
+		log.Debug().Msgf("%s: action is not enabled", s.action)
+		return nil
+	}
+
+	if !s.legacy {
+		return nil
+	}
+
+	fname := fmt.Sprintf("spotify-%s+%s.json", bp.UserId, bp.Started.Format(time.RFC3339))
+	return s.store(fname, &jsonBackup{bp, p, t})
+}
+
+func (s *jsonPayload) DoYoutube(bp *backup.Backup, p *[]backup.YoutubePlaylist, t *[]backup.YoutubeTrack) (err error) {
+	if !s.enabled {
+		log.Debug().Msgf("%s: action is not enabled", s.action)
+		return nil
+	}
+
+	if !s.legacy {
+		return nil
+	}
+
+	fname := fmt.Sprintf("youtube-%s+%s.json", bp.UserId, bp.Started.Format(time.RFC3339))
+	return s.store(fname, &youtubeJsonBackup{bp, p, t})
+}
+
+func (s *jsonPayload) DoLibrary(bp *backup.Backup, l *backup.Library) (err error) {
+	if !s.enabled {
+		log.Debug().Msgf("%s: action is not enabled", s.action)
+		return nil
+	}
+
+	if !s.legacy {
+		return nil
+	}
+
+	if l.IsEmpty() {
+		log.Debug().Msgf("%s: library is empty", s.action)
+		return nil
+	}
+
+	fname := fmt.Sprintf("spotify-library-%s+%s.json", bp.UserId, bp.Started.Format(time.RFC3339))
+	return s.store(fname, &libraryJsonBackup{bp, l})
+}
+
+func (s *jsonPayload) store(name string, v interface{}) (err error) {
+	name, data, err := s.encode(name, v)
+	if err != nil {
+		return
+	}
+
+	return s.uploader.upload(name, data)
+}
+
+// Returns file name with the artifact extension and encoded json
+func (s *jsonPayload) encode(name string, v interface{}) (fname string, data []byte, err error) {
+	data, err = json.Marshal(v)
+	if err != nil {
+		log.Error().Err(err).Msgf("%s: failed to marshal json", s.action)
+		return
+	}
+
+	data, err = s.artifact.Encode(data)
+	if err != nil {
+		log.Error().Err(err).Msgf("%s: failed to encode artifact", s.action)
+		return
+	}
+
+	return name + s.artifact.Extension(), data, nil
+}
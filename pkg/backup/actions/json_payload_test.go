@@ -0,0 +1,55 @@
+package actions
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hoffs/crispy-musicular/pkg/artifact"
+	"github.com/hoffs/crispy-musicular/pkg/backup"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryUploader struct {
+	files map[string][]byte
+}
+
+func (u *memoryUploader) upload(name string, data []byte) error {
+	u.files[name] = data
+	return nil
+}
+
+func TestJsonPayload(t *testing.T) {
+	enc, err := artifact.NewEncoder(artifact.Options{Compression: artifact.CompressionGzip})
+	require.NoError(t, err)
+
+	u := &memoryUploader{files: map[string][]byte{}}
+	s := &jsonPayload{"test_action", true, true, enc, u}
+	bp := &backup.Backup{UserId: "user", Started: time.Unix(0, 0).UTC()}
+	d := backup.NewDocument(bp, nil, nil, nil, nil, nil)
+
+	require.NoError(t, s.Do(bp, &[]backup.Playlist{}, &[]backup.Track{}))
+	require.NoError(t, s.DoYoutube(bp, &[]backup.YoutubePlaylist{}, &[]backup.YoutubeTrack{}))
+	require.NoError(t, s.DoLibrary(bp, &backup.Library{}))
+	require.NoError(t, s.DoDocument(d))
+
+	// empty library and the document are skipped in legacy format
+	require.Len(t, u.files, 2)
+	require.Contains(t, u.files, "spotify-user+1970-01-01T00:00:00Z.json.gz")
+	require.Contains(t, u.files, "youtube-user+1970-01-01T00:00:00Z.json.gz")
+
+	data, err := artifact.Decode(u.files["spotify-user+1970-01-01T00:00:00Z.json.gz"], artifact.DecodeOptions{})
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"UserId":"user"`)
+
+	u.files = map[string][]byte{}
+	s.legacy = false
+	require.NoError(t, s.Do(bp, &[]backup.Playlist{}, &[]backup.Track{}))
+	require.NoError(t, s.DoDocument(d))
+	require.Len(t, u.files, 1)
+	require.Contains(t, u.files, "backup-user+1970-01-01T00:00:00Z.json.gz")
+
+	u.files = map[string][]byte{}
+	s.enabled = false
+	require.NoError(t, s.DoDocument(d))
+	require.Empty(t, u.files)
+}
@@ -0,0 +1,303 @@
+package actions
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/hoffs/crispy-musicular/pkg/artifact"
+	"github.com/hoffs/crispy-musicular/pkg/backup"
+	"github.com/hoffs/crispy-musicular/pkg/config"
+	"github.com/rs/zerolog/log"
+)
+
+type PlaylistFileBackupAction interface {
+	Do(bp *backup.Backup, p *[]backup.Playlist, t *[]backup.Track) error
+	DoYoutube(bp *backup.Backup, p *[]backup.YoutubePlaylist, t *[]backup.YoutubeTrack) error
+	DoLibrary(bp *backup.Backup, l *backup.Library) error
+}
+
+type playlistFileBackupService struct {
+	enabled  bool
+	m3u      bool
+	dir      string
+	artifact artifact.Encoder
+}
+
+func NewPlaylistFileBackupAction(conf *config.AppConfig) (PlaylistFileBackupAction, error) {
+	enc, err := artifact.NewEncoderFromConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	act := &playlistFileBackupService{conf.PlaylistFileActionEnabled, conf.PlaylistFileM3uEnabled, conf.PlaylistFileDir, enc}
+	if act.enabled {
+		err := os.MkdirAll(act.dir, os.ModePerm)
+		return act, err
+	} else {
+		return act, nil
+	}
+}
+
+// https://www.xspf.org/spec
+type xspfPlaylist struct {
+	XMLName    xml.Name    `xml:"http://xspf.org/ns/0/ playlist"`
+	Version    int         `xml:"version,attr"`
+	Title      string      `xml:"title,omitempty"`
+	Creator    string      `xml:"creator,omitempty"`
+	Annotation string      `xml:"annotation,omitempty"`
+	Location   string      `xml:"location,omitempty"`
+	Identifier string      `xml:"identifier,omitempty"`
+	Image      string      `xml:"image,omitempty"`
+	Tracks     []xspfTrack `xml:"trackList>track"`
+}
+
+type xspfTrack struct {
+	Location   []string `xml:"location,omitempty"`
+	Identifier []string `xml:"identifier,omitempty"`
+	Title      string   `xml:"title,omitempty"`
+	Creator    string   `xml:"creator,omitempty"`
+	Album      string   `xml:"album,omitempty"`
+	TrackNum   int      `xml:"trackNum,omitempty"`
+	Duration   int      `xml:"duration,omitempty"` // milliseconds
+	Annotation string   `xml:"annotation,omitempty"`
+}
+
+func (s *playlistFileBackupService) Do(bp *backup.Backup, p *[]backup.Playlist, t *[]backup.Track) (err error) {
+	if !s.enabled {
+		log.Debug().Msg("playlist_file_backup_action: action is not enabled")
+		return nil
+	}
+
+	tracks := make(map[int64][]backup.Track)
+	for _, v := range *t {
+		tracks[v.PlaylistId] = append(tracks[v.PlaylistId], v)
+	}
+
+	for _, pl := range *p {
+		x := newSpotifyXspf(&pl, tracks[pl.Id])
+		err = s.writePlaylist(bp, "spotify", pl.Name, pl.SpotifyId, x)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func (s *playlistFileBackupService) DoYoutube(bp *backup.Backup, p *[]backup.YoutubePlaylist, t *[]backup.YoutubeTrack) (err error) {
+	if !s.enabled {
+		log.Debug().Msg("playlist_file_backup_action: action is not enabled")
+		return nil
+	}
+
+	tracks := make(map[int64][]backup.YoutubeTrack)
+	for _, v := range *t {
+		tracks[v.PlaylistId] = append(tracks[v.PlaylistId], v)
+	}
+
+	for _, pl := range *p {
+		x := newYoutubeXspf(&pl, tracks[pl.Id])
+		err = s.writePlaylist(bp, "youtube", pl.Name, pl.YoutubeId, x)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// Library has no tracks which could be played
+func (s *playlistFileBackupService) DoLibrary(bp *backup.Backup, l *backup.Library) error {
+	return nil
+}
+
+func newSpotifyXspf(p *backup.Playlist, t []backup.Track) *xspfPlaylist {
+	x := &xspfPlaylist{
+		Version:    1,
+		Title:      p.Name,
+		Creator:    p.OwnerName,
+		Annotation: p.Description,
+		Identifier: "spotify:playlist:" + p.SpotifyId,
+		Image:      p.CoverUrl,
+	}
+
+	if p.SpotifyId != backup.LikedSongsId {
+		x.Location = "https://open.spotify.com/playlist/" + p.SpotifyId
+	} else {
+		x.Identifier = ""
+	}
+
+	for _, v := range t {
+		xt := xspfTrack{
+			Title:    v.Name,
+			Creator:  v.Artist,
+			Album:    v.Album,
+			TrackNum: v.TrackNumber,
+			Duration: v.DurationMs,
+		}
+
+		if v.SpotifyId != "" {
+			xt.Location = append(xt.Location, "https://open.spotify.com/track/"+v.SpotifyId)
+		}
+
+		if v.Uri != "" {
+			xt.Identifier = append(xt.Identifier, v.Uri)
+		}
+
+		if v.Isrc != "" {
+			xt.Identifier = append(xt.Identifier, "isrc:"+v.Isrc)
+		}
+
+		x.Tracks = append(x.Tracks, xt)
+	}
+
+	return x
+}
+
+// Music metadata is preferred when it exists, since channel
+// and video title usually don't match artist and song name.
+func newYoutubeXspf(p *backup.YoutubePlaylist, t []backup.YoutubeTrack) *xspfPlaylist {
+	x := &xspfPlaylist{
+		Version:    1,
+		Title:      p.Name,
+		Creator:    p.ChannelTitle,
+		Annotation: p.Description,
+		Location:   "https://www.youtube.com/playlist?list=" + p.YoutubeId,
+		Image:      p.CoverUrl,
+	}
+
+	for _, v := range t {
+		url := "https://www.youtube.com/watch?v=" + v.YoutubeId
+		xt := xspfTrack{
+			Location:   []string{url},
+			Identifier: []string{url},
+			Title:      v.Name,
+			Creator:    v.ChannelTitle,
+			Duration:   v.DurationSeconds * 1000,
+		}
+
+		if v.MusicTitle != "" {
+			xt.Title = v.MusicTitle
+			xt.Creator = v.MusicArtist
+			xt.Album = v.MusicAlbum
+		}
+
+		x.Tracks = append(x.Tracks, xt)
+	}
+
+	return x
+}
+
+// Extended M3U only supports location, duration and a display title
+func formatM3u(x *xspfPlaylist) string {
+	var s strings.Builder
+	s.WriteString("#EXTM3U\n")
+	s.WriteString("#PLAYLIST:" + x.Title + "\n")
+
+	for _, t := range x.Tracks {
+		if len(t.Location) == 0 {
+			continue
+		}
+
+		duration := -1
+		if t.Duration > 0 {
+			duration = t.Duration / 1000
+		}
+
+		title := t.Title
+		if t.Creator != "" {
+			title = t.Creator + " - " + t.Title
+		}
+
+		s.WriteString(fmt.Sprintf("#EXTINF:%d,%s\n", duration, title))
+		if t.Album != "" {
+			s.WriteString("#EXTALB:" + t.Album + "\n")
+		}
+
+		s.WriteString(t.Location[0] + "\n")
+	}
+
+	return s.String()
+}
+
+// Every backup gets its own directory, files are named by platform,
+// playlist name and id, since names don't have to be unique.
+func (s *playlistFileBackupService) writePlaylist(bp *backup.Backup, platform string, name string, id string, x *xspfPlaylist) (err error) {
+	dir := path.Join(s.dir, sanitizeFileName(fmt.Sprintf("%s+%s", bp.UserId, bp.Started.Format(time.RFC3339))))
+	err = os.MkdirAll(dir, os.ModePerm)
+	if err != nil {
+		log.Error().Err(err).Msgf("playlist_file_backup_action: failed to create directory %s", dir)
+		return
+	}
+
+	fname := fmt.Sprintf("%s-%s-%s", platform, sanitizeFileName(name), sanitizeFileName(id))
+
+	data, err := xml.MarshalIndent(x, "", "  ")
+	if err != nil {
+		log.Error().Err(err).Msg("playlist_file_backup_action: failed to marshal xspf")
+		return
+	}
+
+	err = s.writeNewFile(path.Join(dir, fname+".xspf"), append([]byte(xml.Header), data...))
+	if err != nil {
+		return
+	}
+
+	if s.m3u {
+		err = s.writeNewFile(path.Join(dir, fname+".m3u8"), []byte(formatM3u(x)))
+	}
+
+	return
+}
+
+func (s *playlistFileBackupService) writeNewFile(fpath string, data []byte) (err error) {
+	data, err = s.artifact.Encode(data)
+	if err != nil {
+		log.Error().Err(err).Msg("playlist_file_backup_action: failed to encode artifact")
+		return
+	}
+
+	fpath += s.artifact.Extension()
+
+	f, err := os.OpenFile(fpath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+	if err != nil {
+		log.Error().Err(err).Msgf("playlist_file_backup_action: failed to open file at %s", fpath)
+		return
+	}
+	defer f.Close()
+
+	n, err := f.Write(data)
+	if err != nil {
+		log.Error().Err(err).Msg("playlist_file_backup_action: failed to write")
+		return
+	}
+
+	log.Debug().Msgf("playlist_file_backup_action: wrote %d bytes to %s", n, fpath)
+	return
+}
+
+// Keeps file names portable by replacing characters that
+// are not allowed or have special meaning in some file systems.
+func sanitizeFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < 32 || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(name))
+
+	// most file systems limit names to 255 bytes, rest of the name needs some space too
+	if len(name) > 100 {
+		name = strings.ToValidUTF8(name[:100], "")
+	}
+
+	if name == "" || name == "." || name == ".." {
+		return "_"
+	}
+
+	return name
+}
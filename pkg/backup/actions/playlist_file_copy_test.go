@@ -0,0 +1,80 @@
+package actions
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hoffs/crispy-musicular/pkg/artifact"
+	"github.com/hoffs/crispy-musicular/pkg/backup"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSanitizeFileName(t *testing.T) {
+	require.Equal(t, "AC_DC_ Best", sanitizeFileName(" AC/DC: Best "))
+	require.Equal(t, "_", sanitizeFileName(".."))
+	require.Equal(t, "_", sanitizeFileName(""))
+	require.Len(t, sanitizeFileName(string(make([]byte, 300))), 100)
+}
+
+func TestFormatM3u(t *testing.T) {
+	x := &xspfPlaylist{
+		Title: "Playlist",
+		Tracks: []xspfTrack{
+			{Location: []string{"https://example.com/1"}, Title: "Song", Creator: "Artist", Album: "Album", Duration: 61500},
+			{Title: "No location"},
+			{Location: []string{"https://example.com/2"}, Title: "Unknown"},
+		},
+	}
+
+	require.Equal(t, "#EXTM3U\n#PLAYLIST:Playlist\n"+
+		"#EXTINF:61,Artist - Song\n#EXTALB:Album\nhttps://example.com/1\n"+
+		"#EXTINF:-1,Unknown\nhttps://example.com/2\n", formatM3u(x))
+}
+
+func TestPlaylistFileDo(t *testing.T) {
+	dir := t.TempDir()
+	s := &playlistFileBackupService{enabled: true, m3u: true, dir: dir, artifact: newPlainEncoder(t)}
+
+	bp := &backup.Backup{UserId: "user", Started: time.Unix(0, 0).UTC()}
+	p := []backup.Playlist{{Id: 1, SpotifyId: "S", Name: "Name/1", OwnerName: "Owner"}}
+	tr := []backup.Track{{SpotifyId: "T", Uri: "spotify:track:T", Isrc: "I", Name: "Song", Artist: "Artist", Album: "Album", DurationMs: 1000, PlaylistId: 1}}
+
+	err := s.Do(bp, &p, &tr)
+	require.NoError(t, err)
+
+	backupDir := filepath.Join(dir, "user+1970-01-01T00_00_00Z")
+	data, err := os.ReadFile(filepath.Join(backupDir, "spotify-Name_1-S.xspf"))
+	require.NoError(t, err)
+
+	var x xspfPlaylist
+	err = xml.Unmarshal(data, &x)
+	require.NoError(t, err)
+	require.Equal(t, "Name/1", x.Title)
+	require.Equal(t, "Owner", x.Creator)
+	require.Len(t, x.Tracks, 1)
+	require.Equal(t, []string{"https://open.spotify.com/track/T"}, x.Tracks[0].Location)
+	require.Equal(t, []string{"spotify:track:T", "isrc:I"}, x.Tracks[0].Identifier)
+	require.Equal(t, 1000, x.Tracks[0].Duration)
+
+	_, err = os.Stat(filepath.Join(backupDir, "spotify-Name_1-S.m3u8"))
+	require.NoError(t, err)
+
+	// encoded files get the artifact extension
+	s.dir = t.TempDir()
+	s.artifact, err = artifact.NewEncoder(artifact.Options{Compression: artifact.CompressionGzip})
+	require.NoError(t, err)
+	err = s.Do(bp, &p, &tr)
+	require.NoError(t, err)
+
+	backupDir = filepath.Join(s.dir, "user+1970-01-01T00_00_00Z")
+	data, err = artifact.DecodeFile(filepath.Join(backupDir, "spotify-Name_1-S.xspf.gz"), artifact.DecodeOptions{})
+	require.NoError(t, err)
+	require.Contains(t, string(data), "<title>Name/1</title>")
+
+	data, err = artifact.DecodeFile(filepath.Join(backupDir, "spotify-Name_1-S.m3u8.gz"), artifact.DecodeOptions{})
+	require.NoError(t, err)
+	require.Contains(t, string(data), "#EXTM3U")
+}
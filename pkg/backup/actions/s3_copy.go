@@ -0,0 +1,65 @@
+package actions
+
+import (
+	"strings"
+
+	"github.com/hoffs/crispy-musicular/pkg/artifact"
+	"github.com/hoffs/crispy-musicular/pkg/backup"
+	"github.com/hoffs/crispy-musicular/pkg/config"
+	"github.com/hoffs/crispy-musicular/pkg/s3"
+	"github.com/rs/zerolog/log"
+)
+
+type S3BackupAction interface {
+	Do(bp *backup.Backup, p *[]backup.Playlist, t *[]backup.Track) error
+	DoYoutube(bp *backup.Backup, p *[]backup.YoutubePlaylist, t *[]backup.YoutubeTrack) error
+	DoLibrary(bp *backup.Backup, l *backup.Library) error
+	DoDocument(d *backup.Document) error
+}
+
+type s3BackupService struct {
+	jsonPayload
+	bucket string
+	prefix string
+	client s3.Client
+}
+
+func NewS3BackupAction(conf *config.AppConfig) (a S3BackupAction, err error) {
+	enc, err := artifact.NewEncoderFromConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	act := &s3BackupService{bucket: conf.S3Bucket, prefix: conf.S3Prefix}
+	act.jsonPayload = jsonPayload{"s3_backup_action", conf.S3ActionEnabled, conf.JsonLegacyFormat, enc, act}
+	if !act.enabled {
+		return act, nil
+	}
+
+	act.client, err = s3.NewClient(s3.Options{
+		Endpoint:        conf.S3Endpoint,
+		Region:          conf.S3Region,
+		AccessKeyId:     conf.S3AccessKeyId,
+		SecretAccessKey: conf.S3SecretAccessKey,
+		PathStyle:       conf.S3PathStyle,
+	})
+
+	return act, err
+}
+
+// Objects are the same as files written by JSON action
+func (s *s3BackupService) upload(name string, data []byte) (err error) {
+	key := name
+	if s.prefix != "" {
+		key = strings.TrimSuffix(s.prefix, "/") + "/" + name
+	}
+
+	err = s.client.PutObject(s.bucket, key, s.artifact.ContentType("application/json"), data)
+	if err != nil {
+		log.Error().Err(err).Msgf("s3_backup_action: failed to upload %s to bucket %s", key, s.bucket)
+		return
+	}
+
+	log.Debug().Msgf("s3_backup_action: uploaded %d bytes to %s in bucket %s", len(data), key, s.bucket)
+	return
+}
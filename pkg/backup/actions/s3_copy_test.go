@@ -0,0 +1,81 @@
+package actions
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hoffs/crispy-musicular/pkg/artifact"
+	"github.com/hoffs/crispy-musicular/pkg/backup"
+	"github.com/hoffs/crispy-musicular/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestS3Do(t *testing.T) {
+	var mu sync.Mutex
+	objects := make(map[string][]byte)
+	contentTypes := make(map[string]string)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if r.Method != http.MethodPut || r.Header.Get("Authorization") == "" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		objects[r.URL.Path], _ = ioutil.ReadAll(r.Body)
+		contentTypes[r.URL.Path] = r.Header.Get("Content-Type")
+	}))
+	defer srv.Close()
+
+	a, err := NewS3BackupAction(&config.AppConfig{
+		S3ActionEnabled:     true,
+		S3Endpoint:          srv.URL,
+		S3Bucket:            "bucket",
+		S3Prefix:            "backups/crispy/",
+		S3AccessKeyId:       "id",
+		S3SecretAccessKey:   "secret",
+		S3PathStyle:         true,
+		JsonLegacyFormat:    true,
+		ArtifactCompression: artifact.CompressionGzip,
+	})
+	require.NoError(t, err)
+	s := a.(*s3BackupService)
+
+	bp := &backup.Backup{UserId: "user", Started: time.Unix(0, 0).UTC()}
+	d := backup.NewDocument(bp, nil, nil, nil, nil, nil)
+
+	err = s.Do(bp, &[]backup.Playlist{}, &[]backup.Track{})
+	require.NoError(t, err)
+	err = s.DoDocument(d)
+	require.NoError(t, err)
+
+	// key is prefix, file name and artifact extension, document is skipped in legacy format
+	require.Len(t, objects, 1)
+	key := "/bucket/backups/crispy/spotify-user+1970-01-01T00:00:00Z.json.gz"
+	require.Contains(t, objects, key)
+	require.Equal(t, "application/octet-stream", contentTypes[key])
+
+	data, err := artifact.Decode(objects[key], artifact.DecodeOptions{})
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"UserId":"user"`)
+
+	s.legacy = false
+	err = s.Do(bp, &[]backup.Playlist{}, &[]backup.Track{})
+	require.NoError(t, err)
+	err = s.DoDocument(d)
+	require.NoError(t, err)
+
+	require.Len(t, objects, 2)
+	data, err = artifact.Decode(objects["/bucket/backups/crispy/backup-user+1970-01-01T00:00:00Z.json.gz"], artifact.DecodeOptions{})
+	require.NoError(t, err)
+
+	parsed, err := backup.ParseDocument(data)
+	require.NoError(t, err)
+	require.Equal(t, "user", parsed.Backup.UserId)
+}
@@ -0,0 +1,154 @@
+package actions
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+
+	"github.com/hoffs/crispy-musicular/pkg/artifact"
+	"github.com/hoffs/crispy-musicular/pkg/backup"
+	"github.com/hoffs/crispy-musicular/pkg/config"
+	"github.com/pkg/sftp"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/ssh"
+)
+
+type SftpBackupAction interface {
+	Do(bp *backup.Backup, p *[]backup.Playlist, t *[]backup.Track) error
+	DoYoutube(bp *backup.Backup, p *[]backup.YoutubePlaylist, t *[]backup.YoutubeTrack) error
+	DoLibrary(bp *backup.Backup, l *backup.Library) error
+	DoDocument(d *backup.Document) error
+}
+
+type sftpBackupService struct {
+	jsonPayload
+	dir string
+	// connect returns a client and a function which closes all connections
+	connect func() (*sftp.Client, func(), error)
+}
+
+func NewSftpBackupAction(conf *config.AppConfig) (a SftpBackupAction, err error) {
+	enc, err := artifact.NewEncoderFromConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	act := &sftpBackupService{dir: conf.SftpDir}
+	act.jsonPayload = jsonPayload{"sftp_backup_action", conf.SftpActionEnabled, conf.JsonLegacyFormat, enc, act}
+	if !act.enabled {
+		return act, nil
+	}
+
+	sshConf, err := newSftpSshConfig(conf)
+	if err != nil {
+		return act, err
+	}
+
+	address := conf.SftpAddress
+	act.connect = func() (*sftp.Client, func(), error) {
+		conn, err := ssh.Dial("tcp", address, sshConf)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		c, err := sftp.NewClient(conn)
+		if err != nil {
+			conn.Close()
+			return nil, nil, err
+		}
+
+		return c, func() {
+			c.Close()
+			conn.Close()
+		}, nil
+	}
+
+	return act, nil
+}
+
+// Host key has to be provided, since there is no way
+// to interactively confirm it on the first connection.
+func newSftpSshConfig(conf *config.AppConfig) (c *ssh.ClientConfig, err error) {
+	if conf.SftpAddress == "" {
+		return nil, errors.New("sftp_backup_action: sftpAddress must be configured")
+	}
+
+	if conf.SftpHostKey == "" {
+		return nil, errors.New("sftp_backup_action: sftpHostKey must be configured")
+	}
+
+	hostKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(conf.SftpHostKey))
+	if err != nil {
+		return nil, fmt.Errorf("sftp_backup_action: failed to parse host key: %w", err)
+	}
+
+	var auth []ssh.AuthMethod
+	if conf.SftpPrivateKeyPath != "" {
+		key, err := ioutil.ReadFile(conf.SftpPrivateKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("sftp_backup_action: failed to read private key: %w", err)
+		}
+
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("sftp_backup_action: failed to parse private key: %w", err)
+		}
+
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+
+	if conf.SftpPassword != "" {
+		auth = append(auth, ssh.Password(conf.SftpPassword))
+	}
+
+	if len(auth) == 0 {
+		return nil, errors.New("sftp_backup_action: either SFTP_PASSWORD or SFTP_PRIVATE_KEY_PATH must be provided")
+	}
+
+	c = &ssh.ClientConfig{
+		User:            conf.SftpUser,
+		Auth:            auth,
+		HostKeyCallback: ssh.FixedHostKey(hostKey),
+		Timeout:         30 * time.Second,
+	}
+
+	return
+}
+
+// Files are the same as written by JSON action
+func (s *sftpBackupService) upload(name string, data []byte) (err error) {
+	c, close, err := s.connect()
+	if err != nil {
+		log.Error().Err(err).Msg("sftp_backup_action: failed to connect")
+		return
+	}
+	defer close()
+
+	if s.dir != "" {
+		err = c.MkdirAll(s.dir)
+		if err != nil {
+			log.Error().Err(err).Msgf("sftp_backup_action: failed to create directory %s", s.dir)
+			return
+		}
+	}
+
+	fpath := path.Join(s.dir, name)
+	f, err := c.OpenFile(fpath, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+	if err != nil {
+		log.Error().Err(err).Msgf("sftp_backup_action: failed to open file at %s", fpath)
+		return
+	}
+	defer f.Close()
+
+	n, err := f.Write(data)
+	if err != nil {
+		log.Error().Err(err).Msg("sftp_backup_action: failed to write")
+		return
+	}
+
+	log.Debug().Msgf("sftp_backup_action: wrote %d bytes to %s", n, fpath)
+	return
+}
@@ -0,0 +1,85 @@
+package actions
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hoffs/crispy-musicular/pkg/artifact"
+	"github.com/hoffs/crispy-musicular/pkg/backup"
+	"github.com/hoffs/crispy-musicular/pkg/config"
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSftpDo(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "backups", "crispy")
+	enc, err := artifact.NewEncoder(artifact.Options{})
+	require.NoError(t, err)
+
+	s := &sftpBackupService{dir: dir, connect: func() (*sftp.Client, func(), error) {
+		client, server := net.Pipe()
+		srv, err := sftp.NewServer(server)
+		if err != nil {
+			return nil, nil, err
+		}
+		go srv.Serve()
+
+		c, err := sftp.NewClientPipe(client, client)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return c, func() {
+			c.Close()
+			srv.Close()
+		}, nil
+	}}
+	s.jsonPayload = jsonPayload{"sftp_backup_action", true, true, enc, s}
+
+	bp := &backup.Backup{UserId: "user", Started: time.Unix(0, 0).UTC()}
+	err = s.Do(bp, &[]backup.Playlist{}, &[]backup.Track{})
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(dir, "spotify-user+1970-01-01T00:00:00Z.json"))
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"UserId":"user"`)
+
+	// backups are never overwritten
+	err = s.Do(bp, &[]backup.Playlist{}, &[]backup.Track{})
+	require.Error(t, err)
+
+	// only legacy files or the document are stored, never both
+	err = s.DoDocument(backup.NewDocument(bp, nil, nil, nil, nil, nil))
+	require.NoError(t, err)
+	require.NoFileExists(t, filepath.Join(dir, "backup-user+1970-01-01T00:00:00Z.json"))
+
+	s.legacy = false
+	err = s.DoDocument(backup.NewDocument(bp, nil, nil, nil, nil, nil))
+	require.NoError(t, err)
+
+	data, err = os.ReadFile(filepath.Join(dir, "backup-user+1970-01-01T00:00:00Z.json"))
+	require.NoError(t, err)
+
+	d, err := backup.ParseDocument(data)
+	require.NoError(t, err)
+	require.Equal(t, "user", d.Backup.UserId)
+}
+
+func TestNewSftpSshConfig(t *testing.T) {
+	conf := &config.AppConfig{SftpAddress: "localhost:22", SftpUser: "user", SftpPassword: "pass"}
+	_, err := newSftpSshConfig(conf)
+	require.Error(t, err)
+
+	conf.SftpHostKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl"
+	c, err := newSftpSshConfig(conf)
+	require.NoError(t, err)
+	require.Equal(t, "user", c.User)
+	require.Len(t, c.Auth, 1)
+
+	conf.SftpPassword = ""
+	_, err = newSftpSshConfig(conf)
+	require.Error(t, err)
+}
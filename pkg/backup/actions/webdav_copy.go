@@ -0,0 +1,139 @@
+package actions
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hoffs/crispy-musicular/pkg/artifact"
+	"github.com/hoffs/crispy-musicular/pkg/backup"
+	"github.com/hoffs/crispy-musicular/pkg/config"
+	"github.com/rs/zerolog/log"
+)
+
+type WebdavBackupAction interface {
+	Do(bp *backup.Backup, p *[]backup.Playlist, t *[]backup.Track) error
+	DoYoutube(bp *backup.Backup, p *[]backup.YoutubePlaylist, t *[]backup.YoutubeTrack) error
+	DoLibrary(bp *backup.Backup, l *backup.Library) error
+	DoDocument(d *backup.Document) error
+}
+
+type webdavBackupService struct {
+	jsonPayload
+	url      string
+	dir      string
+	user     string
+	password string
+	client   *http.Client
+}
+
+func NewWebdavBackupAction(conf *config.AppConfig) (WebdavBackupAction, error) {
+	enc, err := artifact.NewEncoderFromConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	act := &webdavBackupService{
+		url:      strings.TrimSuffix(conf.WebdavUrl, "/"),
+		dir:      strings.Trim(conf.WebdavDir, "/"),
+		user:     conf.WebdavUser,
+		password: conf.WebdavPassword,
+		client:   &http.Client{Timeout: 5 * time.Minute},
+	}
+	act.jsonPayload = jsonPayload{"webdav_backup_action", conf.WebdavActionEnabled, conf.JsonLegacyFormat, enc, act}
+
+	if act.enabled && act.url == "" {
+		return act, fmt.Errorf("webdav_backup_action: webdavUrl must be configured")
+	}
+
+	return act, nil
+}
+
+// Files are the same as written by JSON action
+func (s *webdavBackupService) upload(name string, data []byte) (err error) {
+	err = s.createCollection()
+	if err != nil {
+		log.Error().Err(err).Msgf("webdav_backup_action: failed to create collection %s", s.dir)
+		return
+	}
+
+	res, err := s.request(http.MethodPut, s.resourceUrl(s.dir, name), data)
+	if err != nil {
+		log.Error().Err(err).Msgf("webdav_backup_action: failed to upload %s", name)
+		return
+	}
+
+	if res != http.StatusCreated && res != http.StatusNoContent && res != http.StatusOK {
+		err = fmt.Errorf("webdav_backup_action: upload of %s failed with status %d", name, res)
+		log.Error().Err(err).Send()
+		return
+	}
+
+	log.Debug().Msgf("webdav_backup_action: uploaded %d bytes to %s/%s", len(data), s.dir, name)
+	return
+}
+
+// MKCOL can't create parent collections, so every level is created separately.
+// Existing collections respond with 405 Method Not Allowed.
+func (s *webdavBackupService) createCollection() (err error) {
+	if s.dir == "" {
+		return
+	}
+
+	var parts []string
+	for _, p := range strings.Split(s.dir, "/") {
+		parts = append(parts, p)
+
+		res, err := s.request("MKCOL", s.resourceUrl(parts...)+"/", nil)
+		if err != nil {
+			return err
+		}
+
+		if res != http.StatusCreated && res != http.StatusMethodNotAllowed {
+			return fmt.Errorf("webdav_backup_action: MKCOL %s failed with status %d", strings.Join(parts, "/"), res)
+		}
+	}
+
+	return
+}
+
+func (s *webdavBackupService) resourceUrl(parts ...string) string {
+	var escaped []string
+	for _, p := range parts {
+		for _, v := range strings.Split(p, "/") {
+			if v != "" {
+				escaped = append(escaped, url.PathEscape(v))
+			}
+		}
+	}
+
+	return s.url + "/" + strings.Join(escaped, "/")
+}
+
+func (s *webdavBackupService) request(method string, url string, data []byte) (status int, err error) {
+	req, err := http.NewRequest(method, url, bytes.NewReader(data))
+	if err != nil {
+		return
+	}
+
+	if s.user != "" || s.password != "" {
+		req.SetBasicAuth(s.user, s.password)
+	}
+
+	if data != nil {
+		req.Header.Set("Content-Type", s.artifact.ContentType("application/json"))
+	}
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer res.Body.Close()
+	ioutil.ReadAll(res.Body)
+
+	return res.StatusCode, nil
+}
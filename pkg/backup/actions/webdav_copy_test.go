@@ -0,0 +1,69 @@
+package actions
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hoffs/crispy-musicular/pkg/artifact"
+	"github.com/hoffs/crispy-musicular/pkg/backup"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebdavDo(t *testing.T) {
+	var mu sync.Mutex
+	collections := map[string]bool{"/dav/": true}
+	files := make(map[string][]byte)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "user" || pass != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch r.Method {
+		case "MKCOL":
+			if collections[r.URL.Path] {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			collections[r.URL.Path] = true
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodPut:
+			files[r.URL.Path], _ = ioutil.ReadAll(r.Body)
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	defer srv.Close()
+
+	enc, err := artifact.NewEncoder(artifact.Options{Compression: artifact.CompressionGzip})
+	require.NoError(t, err)
+
+	s := &webdavBackupService{url: srv.URL + "/dav", dir: "backups/crispy", user: "user", password: "pass", client: srv.Client()}
+	s.jsonPayload = jsonPayload{"webdav_backup_action", true, true, enc, s}
+	bp := &backup.Backup{UserId: "user", Started: time.Unix(0, 0).UTC()}
+
+	err = s.Do(bp, &[]backup.Playlist{}, &[]backup.Track{})
+	require.NoError(t, err)
+	err = s.DoYoutube(bp, &[]backup.YoutubePlaylist{}, &[]backup.YoutubeTrack{})
+	require.NoError(t, err)
+
+	require.True(t, collections["/dav/backups/"])
+	require.True(t, collections["/dav/backups/crispy/"])
+	require.Contains(t, files, "/dav/backups/crispy/youtube-user+1970-01-01T00:00:00Z.json.gz")
+
+	data, err := artifact.Decode(files["/dav/backups/crispy/spotify-user+1970-01-01T00:00:00Z.json.gz"], artifact.DecodeOptions{})
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"UserId":"user"`)
+
+	s.password = "wrong"
+	err = s.Do(bp, &[]backup.Playlist{}, &[]backup.Track{})
+	require.Error(t, err)
+}
@@ -0,0 +1,113 @@
+package backup
+
+import (
+	"time"
+
+	"github.com/zmb3/spotify"
+	"google.golang.org/api/youtube/v3"
+)
+
+type Availability string
+
+const (
+	AvailabilityPlayable         Availability = "playable"
+	AvailabilityRegionRestricted Availability = "region_restricted"
+	AvailabilityDeleted          Availability = "deleted"
+	AvailabilityPrivate          Availability = "private"
+	AvailabilityLocal            Availability = "local"
+)
+
+// Local files are never available on the platform, so they are
+// not considered to be lost either.
+func (a Availability) IsLost() bool {
+	return a == AvailabilityRegionRestricted || a == AvailabilityDeleted || a == AvailabilityPrivate
+}
+
+// Track that was playable in an earlier backup, but isn't anymore.
+type LostTrack struct {
+	// Last known metadata from the latest backup where track was still playable
+	Track        Track
+	Availability Availability
+	LastSeen     time.Time
+
+	PlaylistId   string
+	PlaylistName string
+}
+
+type LostYoutubeTrack struct {
+	Track        YoutubeTrack
+	Availability Availability
+	LastSeen     time.Time
+
+	PlaylistId   string
+	PlaylistName string
+}
+
+// is_playable is only returned when tracks are requested with a market,
+// tracks removed from Spotify are returned without any track data.
+func spotifyAvailability(st *spotify.PlaylistTrack) Availability {
+	if st.IsLocal {
+		return AvailabilityLocal
+	}
+
+	if st.Track.ID == "" {
+		return AvailabilityDeleted
+	}
+
+	if st.Track.IsPlayable != nil && !*st.Track.IsPlayable {
+		return AvailabilityRegionRestricted
+	}
+
+	return AvailabilityPlayable
+}
+
+// Youtube keeps removed videos in playlists, but replaces their
+// title, so that is the only way to tell them apart.
+// Videos that can't be listed anymore are either deleted or made private
+// by their owner, which can't be told apart, so they are treated as deleted.
+func youtubeAvailability(st *youtube.PlaylistItem, v *youtube.Video) Availability {
+	if st.Snippet != nil {
+		switch st.Snippet.Title {
+		case "Deleted video":
+			return AvailabilityDeleted
+		case "Private video":
+			return AvailabilityPrivate
+		}
+	}
+
+	if st.Status != nil && st.Status.PrivacyStatus == "private" {
+		return AvailabilityPrivate
+	}
+
+	if v == nil {
+		return AvailabilityDeleted
+	}
+
+	if v.Status != nil && v.Status.PrivacyStatus == "private" {
+		return AvailabilityPrivate
+	}
+
+	return AvailabilityPlayable
+}
+
+// Tracks of each platform are compared using the newest backup which covers it,
+// so a partial backup doesn't hide lost tracks of the other platform.
+func (b *backuper) GetLostTracks(userId string) (t *[]LostTrack, yt *[]LostYoutubeTrack, err error) {
+	sbp, err := b.repo.GetLastBackup(userId, ScopeSpotify)
+	if err != nil {
+		return
+	}
+
+	ybp, err := b.repo.GetLastBackup(userId, ScopeYoutube)
+	if err != nil {
+		return
+	}
+
+	t, yt, err = b.repo.GetLostTracks(sbp)
+	if err != nil || ybp.Id == sbp.Id {
+		return
+	}
+
+	_, yt, err = b.repo.GetLostTracks(ybp)
+	return
+}
@@ -0,0 +1,47 @@
+package backup
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zmb3/spotify"
+	"google.golang.org/api/youtube/v3"
+)
+
+func TestSpotifyAvailability(t *testing.T) {
+	playable := true
+	notPlayable := false
+
+	require.Equal(t, AvailabilityLocal, spotifyAvailability(&spotify.PlaylistTrack{IsLocal: true}))
+	require.Equal(t, AvailabilityDeleted, spotifyAvailability(&spotify.PlaylistTrack{}))
+
+	st := spotify.PlaylistTrack{}
+	st.Track.ID = "id"
+	require.Equal(t, AvailabilityPlayable, spotifyAvailability(&st))
+
+	st.Track.IsPlayable = &playable
+	require.Equal(t, AvailabilityPlayable, spotifyAvailability(&st))
+
+	st.Track.IsPlayable = &notPlayable
+	require.Equal(t, AvailabilityRegionRestricted, spotifyAvailability(&st))
+}
+
+func TestYoutubeAvailability(t *testing.T) {
+	item := func(title string, privacy string) *youtube.PlaylistItem {
+		return &youtube.PlaylistItem{
+			Snippet: &youtube.PlaylistItemSnippet{Title: title},
+			Status:  &youtube.PlaylistItemStatus{PrivacyStatus: privacy},
+		}
+	}
+
+	video := func(privacy string) *youtube.Video {
+		return &youtube.Video{Status: &youtube.VideoStatus{PrivacyStatus: privacy}}
+	}
+
+	require.Equal(t, AvailabilityPlayable, youtubeAvailability(item("Song", "public"), video("public")))
+	require.Equal(t, AvailabilityDeleted, youtubeAvailability(item("Deleted video", ""), nil))
+	require.Equal(t, AvailabilityPrivate, youtubeAvailability(item("Private video", ""), nil))
+	require.Equal(t, AvailabilityPrivate, youtubeAvailability(item("Song", "private"), video("public")))
+	require.Equal(t, AvailabilityPrivate, youtubeAvailability(item("Song", "public"), video("private")))
+	require.Equal(t, AvailabilityDeleted, youtubeAvailability(item("Song", "public"), nil))
+}
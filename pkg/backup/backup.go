@@ -1,6 +1,11 @@
 package backup
 
-import "time"
+import (
+	"errors"
+	"time"
+)
+
+var ErrBackupNotFound = errors.New("backup: backup not found")
 
 type Backup struct {
 	Id       int64
@@ -8,4 +13,11 @@ type Backup struct {
 	Success  bool
 	Started  time.Time
 	Finished time.Time
+	// Platforms which were backed up, scheduled runs can back up only one of them
+	Scope Scope
+}
+
+// Backups without scope were made before partial backups existed, so they cover everything
+func (b *Backup) Covers(platform Scope) bool {
+	return b.Scope == "" || b.Scope.covers(platform)
 }
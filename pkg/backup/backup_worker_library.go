@@ -0,0 +1,218 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/hoffs/crispy-musicular/pkg/syncplus"
+	"github.com/rs/zerolog/log"
+	"github.com/zmb3/spotify"
+)
+
+type libraryCollection string
+
+const (
+	collectionAlbums  libraryCollection = "albums"
+	collectionArtists libraryCollection = "artists"
+	collectionShows   libraryCollection = "shows"
+)
+
+func (b *backuper) libraryCollections() (c []libraryCollection) {
+	if b.config.SpotifySavedAlbumsEnabled {
+		c = append(c, collectionAlbums)
+	}
+
+	if b.config.SpotifyFollowedArtistsEnabled {
+		c = append(c, collectionArtists)
+	}
+
+	if b.config.SpotifySavedShowsEnabled {
+		c = append(c, collectionShows)
+	}
+
+	return
+}
+
+// Saves enabled library collections using the same worker approach as playlists,
+// every worker saves a single collection at a time.
+func (b *backuper) backupLibrary(ctx context.Context, state *backupState) (err error) {
+	collections := b.libraryCollections()
+	if len(collections) == 0 {
+		log.Debug().Msg("backuper: no library collections enabled")
+		return
+	}
+
+	workers := int(b.config.WorkerCount)
+	if workers > len(collections) {
+		workers = len(collections)
+	}
+
+	log.Info().Msgf("backuper: starting library backup of %v with %d workers", collections, workers)
+
+	lch := make(chan libraryCollection, len(collections))
+
+	// Library has its own wait group, so that it doesn't depend on playlist workers.
+	// Errors are stored before Done, otherwise they could be missed after waiting.
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	errs := make([]error, workers)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			err := b.libraryWorker(state, lch)
+			log.Debug().Err(err).Msgf("library worker %d ended", id)
+
+			mu.Lock()
+			errs[id] = err
+			mu.Unlock()
+		}(i)
+	}
+
+	for _, c := range collections {
+		lch <- c
+	}
+
+	// Close to trigger end of work queue
+	close(lch)
+
+	timedOut := syncplus.WaitContext(ctx, &wg)
+	if timedOut {
+		log.Warn().Msg("backuper: library workers did not finish in time")
+		err = fmt.Errorf("backuper: library workers did not finish in time: %w", ctx.Err())
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for id, idErr := range errs {
+		if idErr != nil {
+			err = idErr
+			log.Error().Err(idErr).Msgf("backuper: library worker %d errored", id)
+		}
+	}
+
+	return
+}
+
+// listens for library collections on channel
+func (b *backuper) libraryWorker(st *backupState, collections <-chan libraryCollection) (err error) {
+	for {
+		select {
+		case c, ok := <-collections:
+			if !ok {
+				log.Debug().Msgf("backuper_library_worker: channel closed, exiting")
+				return
+			}
+
+			log.Debug().Msgf("backuper_library_worker: received collection '%s'", c)
+			if err != nil {
+				log.Warn().Err(err).Msgf("backuper_library_worker: skipping collection '%s' because error'ed already", c)
+				continue
+			}
+
+			err = b.saveLibraryCollection(st, c)
+			if err != nil {
+				log.Error().Err(err).Msgf("backuper_library_worker: encountered an error while saving collection '%s'", c)
+				b.publishError(Event{BackupId: st.bp.Id, Platform: PlatformSpotify, Name: string(c)}, err)
+			}
+		case <-st.ctx.Done():
+			log.Debug().Msg("backuper_library_worker: exiting")
+			return
+		}
+	}
+}
+
+func (b *backuper) saveLibraryCollection(st *backupState, c libraryCollection) error {
+	switch c {
+	case collectionAlbums:
+		return b.saveAlbums(st)
+	case collectionArtists:
+		return b.saveArtists(st)
+	case collectionShows:
+		return b.saveShows(st)
+	}
+
+	return fmt.Errorf("backuper_library_worker: unknown collection '%s'", c)
+}
+
+func (b *backuper) saveAlbums(st *backupState) (err error) {
+	limit := 50 // Max albums per page
+	albums, err := st.spotify.CurrentUsersAlbumsOpt(&spotify.Options{Limit: &limit})
+	if err != nil {
+		log.Error().Err(err).Msg("backuper_library_worker: failed to get initial saved albums")
+		return
+	}
+
+	var all []spotify.SavedAlbum
+	for {
+		log.Debug().Msgf("backuper_library_worker: got album page, offset %d, limit %d, total %d", albums.Offset, albums.Limit, albums.Total)
+
+		all = append(all, albums.Albums...)
+
+		err = st.spotify.NextPage(albums)
+		if err == spotify.ErrNoMorePages {
+			break
+		}
+
+		if err != nil {
+			return
+		}
+	}
+
+	return b.addSpotifyAlbums(st.bp, all)
+}
+
+// Followed artists use a cursor instead of offset, so NextPage can't be used
+func (b *backuper) saveArtists(st *backupState) (err error) {
+	limit := 50 // Max artists per page
+	var all []spotify.FullArtist
+	after := ""
+	for {
+		artists, err := st.spotify.CurrentUsersFollowedArtistsOpt(limit, after)
+		if err != nil {
+			log.Error().Err(err).Msg("backuper_library_worker: failed to get followed artists")
+			return err
+		}
+
+		log.Debug().Msgf("backuper_library_worker: got artist page, after '%s', limit %d, total %d", after, artists.Limit, artists.Total)
+
+		all = append(all, artists.Artists...)
+
+		if artists.Next == "" || artists.Cursor.After == "" {
+			break
+		}
+
+		after = artists.Cursor.After
+	}
+
+	return b.addSpotifyArtists(st.bp, all)
+}
+
+func (b *backuper) saveShows(st *backupState) (err error) {
+	limit := 50 // Max shows per page
+	shows, err := st.spotify.CurrentUsersShowsOpt(&spotify.Options{Limit: &limit})
+	if err != nil {
+		log.Error().Err(err).Msg("backuper_library_worker: failed to get initial saved shows")
+		return
+	}
+
+	var all []spotify.SavedShow
+	for {
+		log.Debug().Msgf("backuper_library_worker: got show page, offset %d, limit %d, total %d", shows.Offset, shows.Limit, shows.Total)
+
+		all = append(all, shows.Shows...)
+
+		err = st.spotify.NextPage(shows)
+		if err == spotify.ErrNoMorePages {
+			break
+		}
+
+		if err != nil {
+			return
+		}
+	}
+
+	return b.addSpotifyShows(st.bp, all)
+}
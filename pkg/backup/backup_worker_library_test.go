@@ -0,0 +1,57 @@
+package backup
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/hoffs/crispy-musicular/pkg/config"
+	"github.com/stretchr/testify/require"
+	"github.com/zmb3/spotify"
+)
+
+// Sends all requests to the test server instead of Spotify
+type serverTransport struct {
+	url *url.URL
+}
+
+func (t *serverTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r.URL.Scheme = t.url.Scheme
+	r.URL.Host = t.url.Host
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestBackupLibraryErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error":{"status":500,"message":"failed"}}`))
+	}))
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+
+	b := &backuper{
+		config: &config.AppConfig{
+			WorkerCount:                   3,
+			SpotifySavedAlbumsEnabled:     true,
+			SpotifyFollowedArtistsEnabled: true,
+			SpotifySavedShowsEnabled:      true,
+		},
+		events: NewEventBus(),
+	}
+
+	state := &backupState{
+		ctx:     context.Background(),
+		spotify: spotify.NewClient(&http.Client{Transport: &serverTransport{u}}),
+		bp:      &Backup{Id: 1},
+	}
+
+	// every failed collection has to be noticed after workers finish
+	for i := 0; i < 20; i++ {
+		err = b.backupLibrary(state.ctx, state)
+		require.Error(t, err)
+	}
+}
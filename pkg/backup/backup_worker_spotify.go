@@ -2,7 +2,9 @@ package backup
 
 import (
 	"context"
+	"fmt"
 	"math"
+	"sync"
 
 	"github.com/hoffs/crispy-musicular/pkg/auth"
 	"github.com/hoffs/crispy-musicular/pkg/syncplus"
@@ -12,12 +14,7 @@ import (
 )
 
 func (b *backuper) backupSpotify(ctx context.Context, state *backupState, authState *auth.State) (err error) {
-	// There should be no long term issues with this as refresh token doesn't change on subsequent
-	// authorizations and probably only changes if auth is revoked for the app or something is reset
-	// by Spotify.
-	sAuth := spotify.NewAuthenticator("", spotify.ScopePlaylistReadPrivate)
-	state.spotify = sAuth.NewClient(&oauth2.Token{RefreshToken: authState.RefreshToken})
-	state.spotify.AutoRetry = true // Auto retry on rate limit
+	state.spotify = newSpotifyClient(authState.RefreshToken)
 
 	usr, err := state.spotify.CurrentUser()
 	if err != nil {
@@ -25,7 +22,7 @@ func (b *backuper) backupSpotify(ctx context.Context, state *backupState, authSt
 		return
 	}
 
-	state.bp, err = b.createBackup(usr.ID)
+	state.bp, err = b.createBackup(usr.ID, state.scope)
 	if err != nil {
 		log.Error().Err(err).Msg("backuper: could not create backup entry")
 		return
@@ -38,18 +35,23 @@ func (b *backuper) backupSpotify(ctx context.Context, state *backupState, authSt
 	// Would maybe make sense to add also an upper bound with math.Min, so that the buffer size would
 	// be too big.
 	bufferSize := int(math.Max(float64(51), float64(workers+1)))
-	pch := make(chan *spotify.SimplePlaylist, bufferSize)
+	pch := make(chan *libraryPlaylist, bufferSize)
 
-	errs := make(map[uint8]error)
+	// Errors are stored before Done, otherwise they could be missed after waiting
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	errs := make([]error, workers)
 
 	for i := uint8(0); i < workers; i++ {
-		state.wg.Add(1)
+		wg.Add(1)
 		go func(id uint8) {
+			defer wg.Done()
 			err := b.worker(state, pch)
 			log.Debug().Err(err).Msgf("worker %d ended", id)
-			if err != nil {
-				errs[id] = err
-			}
+
+			mu.Lock()
+			errs[id] = err
+			mu.Unlock()
 		}(i)
 	}
 
@@ -60,12 +62,15 @@ func (b *backuper) backupSpotify(ctx context.Context, state *backupState, authSt
 		return
 	}
 
+	position := 0
 	for {
 		log.Debug().Msgf("backuper: got playlist page, offset %d, limit %d, total %d", playlists.Offset, playlists.Limit, playlists.Total)
 
 		for id := range playlists.Playlists {
 			// use from array, since value from range function changes, but not the pointer
 			p := &playlists.Playlists[id]
+			// position counts skipped playlists too, so that it matches the order in user's library
+			position++
 
 			if !b.shouldSavePlaylist(usr.ID, p) {
 				log.Debug().Msgf("backuper: skipping '%s' with id '%s'", p.Name, p.ID)
@@ -76,7 +81,14 @@ func (b *backuper) backupSpotify(ctx context.Context, state *backupState, authSt
 
 			// New struct is created, so sending pointer causes no issues even if next page is loaded before
 			// previous page has finished.
-			pch <- p
+			b.publishQueued(state, PlatformSpotify, string(p.ID), p.Name)
+			select {
+			case pch <- &libraryPlaylist{p, position - 1}:
+			case <-ctx.Done():
+				// workers have exited, so nothing would receive remaining playlists
+				close(pch)
+				return ctx.Err()
+			}
 		}
 
 		err = state.spotify.NextPage(playlists)
@@ -92,14 +104,29 @@ func (b *backuper) backupSpotify(ctx context.Context, state *backupState, authSt
 		}
 	}
 
+	if b.config.SpotifyLikedSongsEnabled {
+		log.Debug().Msg("backuper: sending liked songs to worker")
+		liked := newLikedSongsPlaylist(usr)
+		b.publishQueued(state, PlatformSpotify, string(liked.ID), liked.Name)
+		select {
+		case pch <- &libraryPlaylist{liked, position}:
+		case <-ctx.Done():
+			close(pch)
+			return ctx.Err()
+		}
+	}
+
 	// Close to trigger end of work queue
 	close(pch)
 
-	timedOut := syncplus.WaitContext(ctx, &state.wg)
+	timedOut := syncplus.WaitContext(ctx, &wg)
 	if timedOut {
 		log.Warn().Msg("backuper: workers did not finish in time")
+		err = fmt.Errorf("backuper: workers did not finish in time: %w", ctx.Err())
 	}
 
+	mu.Lock()
+	defer mu.Unlock()
 	for id, idErr := range errs {
 		if idErr != nil {
 			err = idErr
@@ -110,10 +137,24 @@ func (b *backuper) backupSpotify(ctx context.Context, state *backupState, authSt
 	return
 }
 
-// listens for playlists on channel
-func (b *backuper) worker(st *backupState, playlists <-chan *spotify.SimplePlaylist) (err error) {
-	defer st.wg.Done()
+// There should be no long term issues with this as refresh token doesn't change on subsequent
+// authorizations and probably only changes if auth is revoked for the app or something is reset
+// by Spotify. Scopes are defined by the authorization, so they don't matter here.
+func newSpotifyClient(refreshToken string) spotify.Client {
+	sAuth := spotify.NewAuthenticator("", spotify.ScopePlaylistReadPrivate)
+	c := sAuth.NewClient(&oauth2.Token{RefreshToken: refreshToken})
+	c.AutoRetry = true // Auto retry on rate limit
+	return c
+}
 
+// Playlist together with its position in user's library
+type libraryPlaylist struct {
+	*spotify.SimplePlaylist
+	position int
+}
+
+// listens for playlists on channel
+func (b *backuper) worker(st *backupState, playlists <-chan *libraryPlaylist) (err error) {
 	for {
 		select {
 		case p := <-playlists:
@@ -123,8 +164,10 @@ func (b *backuper) worker(st *backupState, playlists <-chan *spotify.SimplePlayl
 			}
 
 			log.Debug().Msgf("backuper_worker: received playlist '%s' with pointer '%p'", p.Name, p)
+			ev := Event{BackupId: st.bp.Id, Platform: PlatformSpotify, PlaylistId: string(p.ID), Name: p.Name}
 			if err != nil {
 				log.Warn().Err(err).Msgf("backuper_worker: skipping playlist '%s' because error'ed already", p.Name)
+				b.publishError(ev, err)
 				continue
 			}
 
@@ -132,6 +175,10 @@ func (b *backuper) worker(st *backupState, playlists <-chan *spotify.SimplePlayl
 			if err != nil {
 				// don't exit, try to save other playlists
 				log.Error().Err(err).Msgf("backuper_worker: encountered an error while saving playlist '%s'", p.Name)
+				b.publishError(ev, err)
+			} else {
+				ev.Type = EventPlaylistCompleted
+				b.publish(ev)
 			}
 		case <-st.ctx.Done():
 			log.Debug().Msg("backuper_worker: exiting")
@@ -140,42 +187,73 @@ func (b *backuper) worker(st *backupState, playlists <-chan *spotify.SimplePlayl
 	}
 }
 
-func (b *backuper) savePlaylist(st *backupState, playlist *spotify.SimplePlaylist) (err error) {
-	// This already has default limit as max, so no need for options
-	tracks, err := st.spotify.GetPlaylistTracks(playlist.ID)
+func (b *backuper) savePlaylist(st *backupState, playlist *libraryPlaylist) (err error) {
+	p := newSpotifyPlaylist(playlist.SimplePlaylist, playlist.position)
+	if isLikedSongs(playlist.SimplePlaylist) {
+		return b.saveLikedSongs(st, p)
+	}
+
+	// Snapshot id changes with every modification of a playlist, so if the same one
+	// was already stored there is no need to fetch and store the tracks again.
+	// Availability of tracks can change without playlist being modified though.
+	if b.config.ReuseSpotifySnapshots {
+		reused, err := b.repo.AddPlaylistFromSnapshot(st.bp, p)
+		if err != nil {
+			log.Error().Err(err).Msgf("backuper_worker: could not reuse playlist entry for '%s'", playlist.Name)
+			return err
+		}
+
+		if reused {
+			log.Debug().Msgf("backuper_worker: playlist '%s' is unchanged since snapshot '%s'", playlist.Name, playlist.SnapshotID)
+			return b.reuseCover(p)
+		}
+	}
+
+	p.CoverBlob = b.storeCover(p.CoverUrl)
+
+	// Description is not returned when listing playlists
+	full, err := st.spotify.GetPlaylistOpt(playlist.ID, "description")
 	if err != nil {
-		log.Error().Err(err).Msgf("backuper_worker: failed to get initial playlist tracks for '%s'", playlist.Name)
+		log.Error().Err(err).Msgf("backuper_worker: failed to get description for '%s'", playlist.Name)
 		return
 	}
 
-	p, err := b.addSpotifyPlaylist(st.bp, playlist)
+	p.Description = full.Description
+
+	// Market is required for Spotify to return whether track is playable.
+	// This already has default limit as max, so no need for other options
+	market := spotify.MarketFromToken
+	tracks, err := st.spotify.GetPlaylistTracksOpt(playlist.ID, &spotify.Options{Country: &market}, "")
 	if err != nil {
-		log.Error().Err(err).Msgf("backuper_worker: could not create playlist entry for '%s'", playlist.Name)
+		log.Error().Err(err).Msgf("backuper_worker: failed to get initial playlist tracks for '%s'", playlist.Name)
 		return
 	}
 
+	// Tracks are collected first and stored with the playlist at once, because storage
+	// deduplicates by the full track list.
+	var all []spotify.PlaylistTrack
 	for {
 		log.Debug().Msgf("backuper_worker: got track page for '%s', offset %d, limit %d, total %d", playlist.Name, tracks.Offset, tracks.Limit, tracks.Total)
 
-		for _, t := range tracks.Tracks {
-			log.Debug().Msgf("backuper_worker: playlist '%s', track '%s'", playlist.Name, t.Track.Name)
-
-			err = b.addSpotifyTrack(st.bp, p, &t)
-			if err != nil {
-				log.Error().Err(err).Msgf("backuper_worker: could not create track entry for '%s'/'%s'", playlist.Name, t.Track.Name)
-				return
-			}
-		}
+		all = append(all, tracks.Tracks...)
 
 		err = st.spotify.NextPage(tracks)
 		if err == spotify.ErrNoMorePages {
-			return nil
+			break
 		}
 
 		if err != nil {
 			return
 		}
 	}
+
+	err = b.addSpotifyPlaylist(st.bp, p, all)
+	if err != nil {
+		log.Error().Err(err).Msgf("backuper_worker: could not create playlist entry for '%s'", playlist.Name)
+		return
+	}
+
+	return nil
 }
 
 // applies all rules in order, by default => should save
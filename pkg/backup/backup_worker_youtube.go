@@ -2,7 +2,9 @@ package backup
 
 import (
 	"context"
+	"fmt"
 	"math"
+	"sync"
 
 	"github.com/hoffs/crispy-musicular/pkg/auth"
 	"github.com/hoffs/crispy-musicular/pkg/syncplus"
@@ -24,58 +26,97 @@ func (b *backuper) backupYoutube(ctx context.Context, state *backupState, authSt
 		return
 	}
 
-	// instead of .Do(), theres pretty cool Pages() which can call a function for every page.
-	playlists, err := state.youtube.Playlists.List([]string{"snippet"}).Id(b.config.YoutubeSavedPlaylistIds...).MaxResults(50).Do()
+	channelId, err := b.youtubeChannel(state)
 	if err != nil {
-		log.Error().Err(err).Msg("backuper: failed to get youtube playlists")
 		return
 	}
 
 	workers := b.config.WorkerCount
-	log.Info().Msgf("backuper: starting youtube backup with %d workers", workers)
+	log.Info().Msgf("backuper: starting youtube backup for %s with %d workers", channelId, workers)
 
 	bufferSize := int(math.Max(float64(51), float64(workers+1)))
-	pch := make(chan *gyoutube.Playlist, bufferSize)
+	pch := make(chan *youtubeLibraryPlaylist, bufferSize)
 
-	errs := make(map[uint8]error)
+	// Errors are stored before Done, otherwise they could be missed after waiting
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	errs := make([]error, workers)
 
 	for i := uint8(0); i < workers; i++ {
-		state.wg.Add(1)
+		wg.Add(1)
 		go func(id uint8) {
+			defer wg.Done()
 			err := b.worker_youtube(state, pch)
 			log.Debug().Err(err).Msgf("worker %d ended", id)
-			if err != nil {
-				errs[id] = err
-			}
+
+			mu.Lock()
+			errs[id] = err
+			mu.Unlock()
 		}(i)
 	}
 
-	for {
+	// Explicit ids are listed first, so that playlists which are not owned
+	// and special ones like LL are included, then all of user's own playlists.
+	// Playlist can be in both, so already seen ones are skipped.
+	seen := make(map[string]bool)
+	position := 0
+	send := func(playlists *gyoutube.PlaylistListResponse) error {
 		for id := range playlists.Items {
 			// Items is already array of pointers
 			p := playlists.Items[id]
-			pch <- p
-		}
+			if seen[p.Id] {
+				continue
+			}
 
-		if playlists.NextPageToken == "" {
-			break
-		} else {
-			playlists, err = state.youtube.Playlists.List([]string{"snippet"}).PageToken(playlists.NextPageToken).Id(b.config.YoutubeSavedPlaylistIds...).MaxResults(50).Do()
-			if err != nil {
-				close(pch)
-				return
+			seen[p.Id] = true
+			position++
+
+			if !b.shouldSaveYoutubePlaylist(channelId, p) {
+				log.Debug().Msgf("backuper: skipping youtube playlist '%s' with id '%s'", p.Snippet.Title, p.Id)
+				continue
+			}
+
+			b.publishQueued(state, PlatformYoutube, p.Id, p.Snippet.Title)
+			select {
+			case pch <- &youtubeLibraryPlaylist{p, position - 1}:
+			case <-ctx.Done():
+				// workers have exited, so nothing would receive remaining playlists
+				return ctx.Err()
 			}
 		}
+
+		return nil
+	}
+
+	if len(b.config.YoutubeSavedPlaylistIds) > 0 {
+		err = listYoutubePlaylists(state.youtube.Playlists.List(youtubePlaylistParts).Id(b.config.YoutubeSavedPlaylistIds...), send)
+		if err != nil {
+			log.Error().Err(err).Msg("backuper: failed to get youtube playlists")
+			close(pch)
+			return
+		}
+	}
+
+	if b.config.YoutubeMinePlaylistsEnabled && channelId != "" {
+		err = listYoutubePlaylists(state.youtube.Playlists.List(youtubePlaylistParts).Mine(true), send)
+		if err != nil {
+			log.Error().Err(err).Msg("backuper: failed to get own youtube playlists")
+			close(pch)
+			return
+		}
 	}
 
 	// Close to trigger end of work queue
 	close(pch)
 
-	timedOut := syncplus.WaitContext(ctx, &state.wg)
+	timedOut := syncplus.WaitContext(ctx, &wg)
 	if timedOut {
 		log.Warn().Msg("backuper: workers did not finish in time")
+		err = fmt.Errorf("backuper: workers did not finish in time: %w", ctx.Err())
 	}
 
+	mu.Lock()
+	defer mu.Unlock()
 	for id, idErr := range errs {
 		if idErr != nil {
 			err = idErr
@@ -86,10 +127,84 @@ func (b *backuper) backupYoutube(ctx context.Context, state *backupState, authSt
 	return
 }
 
-// listens for playlists on channel
-func (b *backuper) worker_youtube(st *backupState, playlists <-chan *gyoutube.Playlist) (err error) {
-	defer st.wg.Done()
+var youtubePlaylistParts = []string{"snippet", "status"}
+
+// Channel is only needed for own playlists and ownership rules. Brand accounts or
+// accounts without a channel can still back up explicitly listed playlists, so
+// missing channel is returned as empty id instead of an error.
+func (b *backuper) youtubeChannel(state *backupState) (channelId string, err error) {
+	if !b.config.YoutubeMinePlaylistsEnabled && !b.config.YoutubeIgnoreNotOwnedPlaylists && !b.config.YoutubeIgnoreOwnedPlaylists {
+		return
+	}
+
+	channels, err := state.youtube.Channels.List([]string{"id"}).Mine(true).Do()
+	if err != nil {
+		log.Error().Err(err).Msg("backuper: failed to get youtube channel")
+		return
+	}
+
+	if len(channels.Items) == 0 {
+		log.Warn().Msg("backuper: youtube account has no channel, only explicitly saved playlists are backed up")
+		return
+	}
+
+	return channels.Items[0].Id, nil
+}
+
+// Playlist together with its position in order of listing
+type youtubeLibraryPlaylist struct {
+	*gyoutube.Playlist
+	position int
+}
+
+func listYoutubePlaylists(call *gyoutube.PlaylistsListCall, send func(*gyoutube.PlaylistListResponse) error) error {
+	call = call.MaxResults(50)
+	for {
+		playlists, err := call.Do()
+		if err != nil {
+			return err
+		}
+
+		err = send(playlists)
+		if err != nil {
+			return err
+		}
+
+		if playlists.NextPageToken == "" {
+			return nil
+		}
+
+		call = call.PageToken(playlists.NextPageToken)
+	}
+}
+
+// applies the same rules as shouldSavePlaylist, but for Youtube
+// and with owner being the channel of the user. Ownership rules are
+// skipped if the user has no channel.
+func (b *backuper) shouldSaveYoutubePlaylist(channelId string, p *gyoutube.Playlist) (save bool) {
+	save = true
+	owned := channelId != "" && p.Snippet.ChannelId == channelId
+	if b.config.YoutubeIgnoreNotOwnedPlaylists && channelId != "" && !owned {
+		save = false
+	}
+
+	if b.config.YoutubeIgnoreOwnedPlaylists && owned {
+		save = false
+	}
+
+	for _, ignoredId := range b.config.YoutubeIgnoredPlaylistIds {
+		save = save && (ignoredId != p.Id)
+	}
+
+	for _, savedId := range b.config.YoutubeSavedPlaylistIds {
+		save = save || (savedId == p.Id)
+	}
+
+	return
+}
 
+// listens for playlists on channel
+func (b *backuper) worker_youtube(st *backupState, playlists <-chan *youtubeLibraryPlaylist) (err error) {
 	for {
 		select {
 		case p := <-playlists:
@@ -99,8 +214,10 @@ func (b *backuper) worker_youtube(st *backupState, playlists <-chan *gyoutube.Pl
 			}
 
 			log.Debug().Msgf("backuper_worker_youtube: received playlist '%s' with pointer '%p'", p.Snippet.Title, p)
+			ev := Event{BackupId: st.bp.Id, Platform: PlatformYoutube, PlaylistId: p.Id, Name: p.Snippet.Title}
 			if err != nil {
 				log.Warn().Err(err).Msgf("backuper_worker_youtube: skipping playlist '%s' because error'ed already", p.Snippet.Title)
+				b.publishError(ev, err)
 				continue
 			}
 
@@ -108,6 +225,10 @@ func (b *backuper) worker_youtube(st *backupState, playlists <-chan *gyoutube.Pl
 			if err != nil {
 				// don't exit, try to save other playlists
 				log.Error().Err(err).Msgf("backuper_worker_youtube: encountered an error while saving playlist '%s'", p.Snippet.Title)
+				b.publishError(ev, err)
+			} else {
+				ev.Type = EventPlaylistCompleted
+				b.publish(ev)
 			}
 		case <-st.ctx.Done():
 			log.Debug().Msg("backuper_worker_youtube: exiting")
@@ -116,16 +237,11 @@ func (b *backuper) worker_youtube(st *backupState, playlists <-chan *gyoutube.Pl
 	}
 }
 
-func (b *backuper) savePlaylistYoutube(st *backupState, playlist *gyoutube.Playlist) (err error) {
-	p, err := b.addYoutubePlaylist(st.bp, playlist)
-	if err != nil {
-		log.Error().Err(err).Msgf("backuper_worker_youtube: could not create playlist entry for '%s'", playlist.Snippet.Title)
-		return
-	}
-
+func (b *backuper) savePlaylistYoutube(st *backupState, playlist *youtubeLibraryPlaylist) (err error) {
+	var all []*gyoutube.PlaylistItem
 	pageToken := ""
 	for {
-		call := st.youtube.PlaylistItems.List([]string{"id", "snippet", "contentDetails"}).PlaylistId(playlist.Id).MaxResults(50)
+		call := st.youtube.PlaylistItems.List([]string{"id", "snippet", "contentDetails", "status"}).PlaylistId(playlist.Id).MaxResults(50)
 		if pageToken != "" {
 			call = call.PageToken(pageToken)
 		}
@@ -138,21 +254,24 @@ func (b *backuper) savePlaylistYoutube(st *backupState, playlist *gyoutube.Playl
 		log.Debug().Msgf("backuper_worker_youtube: got track page for '%s', total %d", playlist.Snippet.Title, tracks.PageInfo.TotalResults)
 
 		pageToken = tracks.NextPageToken
-
-		for _, t := range tracks.Items {
-			log.Debug().Msgf("backuper_worker_youtube: playlist '%s', track '%s'", playlist.Snippet.Title, t.Snippet.Title)
-
-			err = b.addYoutubeTrack(st.bp, p, t)
-			if err != nil {
-				log.Error().Err(err).Msgf("backuper_worker_youtube: could not create track entry for '%s'/'%s'", playlist.Snippet.Title, t.Snippet.Title)
-				return err
-			}
-		}
+		all = append(all, tracks.Items...)
 
 		if pageToken == "" {
 			break
 		}
 	}
 
+	videos, err := b.getYoutubeVideos(st, all)
+	if err != nil {
+		log.Error().Err(err).Msgf("backuper_worker_youtube: failed to get videos for '%s'", playlist.Snippet.Title)
+		return
+	}
+
+	_, err = b.addYoutubePlaylist(st.bp, playlist.Playlist, playlist.position, all, videos)
+	if err != nil {
+		log.Error().Err(err).Msgf("backuper_worker_youtube: could not create playlist entry for '%s'", playlist.Snippet.Title)
+		return
+	}
+
 	return nil
 }
@@ -0,0 +1,53 @@
+package backup
+
+import (
+	"testing"
+
+	"github.com/hoffs/crispy-musicular/pkg/config"
+	"github.com/stretchr/testify/require"
+	gyoutube "google.golang.org/api/youtube/v3"
+)
+
+func TestShouldSaveYoutubePlaylist(t *testing.T) {
+	playlist := func(id string, channelId string) *gyoutube.Playlist {
+		return &gyoutube.Playlist{Id: id, Snippet: &gyoutube.PlaylistSnippet{ChannelId: channelId}}
+	}
+
+	own := playlist("own", "me")
+	other := playlist("other", "someone")
+	liked := playlist("LL", "me")
+
+	b := &backuper{config: &config.AppConfig{}}
+	require.True(t, b.shouldSaveYoutubePlaylist("me", own))
+	require.True(t, b.shouldSaveYoutubePlaylist("me", other))
+
+	b.config.YoutubeIgnoreNotOwnedPlaylists = true
+	require.True(t, b.shouldSaveYoutubePlaylist("me", own))
+	require.False(t, b.shouldSaveYoutubePlaylist("me", other))
+
+	b.config.YoutubeSavedPlaylistIds = []string{"other"}
+	require.True(t, b.shouldSaveYoutubePlaylist("me", other))
+
+	b.config.YoutubeIgnoredPlaylistIds = []string{"own"}
+	require.False(t, b.shouldSaveYoutubePlaylist("me", own))
+
+	b.config.YoutubeIgnoreOwnedPlaylists = true
+	b.config.YoutubeSavedPlaylistIds = []string{"LL"}
+	require.False(t, b.shouldSaveYoutubePlaylist("me", own))
+	require.True(t, b.shouldSaveYoutubePlaylist("me", liked))
+
+	// ownership can't be told without a channel
+	b.config.YoutubeIgnoreNotOwnedPlaylists = true
+	b.config.YoutubeSavedPlaylistIds = nil
+	b.config.YoutubeIgnoredPlaylistIds = nil
+	require.True(t, b.shouldSaveYoutubePlaylist("", own))
+	require.True(t, b.shouldSaveYoutubePlaylist("", other))
+}
+
+func TestYoutubeChannelNotNeeded(t *testing.T) {
+	// service is nil, so it would panic if channel was requested
+	b := &backuper{config: &config.AppConfig{YoutubeSavedPlaylistIds: []string{"LL"}}}
+	channelId, err := b.youtubeChannel(&backupState{})
+	require.NoError(t, err)
+	require.Empty(t, channelId)
+}
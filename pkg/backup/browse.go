@@ -0,0 +1,125 @@
+package backup
+
+import "errors"
+
+const (
+	PlatformSpotify = "spotify"
+	PlatformYoutube = "youtube"
+)
+
+var ErrUnknownPlatform = errors.New("backup: unknown platform")
+
+// Backup together with counts of its content
+type BackupSummary struct {
+	Backup        Backup
+	PlaylistCount int64
+	TrackCount    int64
+}
+
+type BackupPage struct {
+	Backups []BackupSummary
+	Total   int64
+}
+
+// Spotify or Youtube playlist of a backup without its tracks
+type PlaylistSummary struct {
+	Platform    string
+	Id          string
+	Name        string
+	Description string
+	Owner       string
+	CoverUrl    string
+	Position    int
+	TrackCount  int64
+}
+
+type PlaylistPage struct {
+	Playlists []PlaylistSummary
+	Total     int64
+}
+
+// Page of tracks of a single playlist, only tracks
+// of the playlist platform are set.
+type PlaylistTracks struct {
+	Backup        *Backup
+	Playlist      PlaylistSummary
+	Tracks        *[]Track
+	YoutubeTracks *[]YoutubeTrack
+}
+
+// Returns backups of the user newest first
+func (b *backuper) ListBackups(userId string, offset int, limit int) (page *BackupPage, err error) {
+	backups, err := b.repo.GetBackupsPage(userId, offset, limit)
+	if err != nil {
+		return
+	}
+
+	page = &BackupPage{Backups: make([]BackupSummary, 0, len(*backups))}
+	page.Total, err = b.repo.GetBackupCount(userId)
+	if err != nil {
+		return
+	}
+
+	for _, v := range *backups {
+		s := BackupSummary{Backup: v}
+		s.PlaylistCount, err = b.repo.GetBackupPlaylistCount(&v, ScopeAll)
+		if err != nil {
+			return
+		}
+
+		s.TrackCount, err = b.repo.GetBackupTrackCount(&v, ScopeAll)
+		if err != nil {
+			return
+		}
+
+		page.Backups = append(page.Backups, s)
+	}
+
+	return
+}
+
+// Returns Spotify playlists followed by Youtube playlists of the backup
+func (b *backuper) GetBackupPlaylists(backupId int64, offset int, limit int) (bp *Backup, page *PlaylistPage, err error) {
+	bp, err = b.repo.GetBackup(backupId)
+	if err != nil {
+		return
+	}
+
+	p, err := b.repo.GetPlaylistSummaries(bp, offset, limit)
+	if err != nil {
+		return
+	}
+
+	page = &PlaylistPage{Playlists: *p}
+	page.Total, err = b.repo.GetBackupPlaylistCount(bp, ScopeAll)
+	return
+}
+
+func (b *backuper) GetPlaylistTracks(backupId int64, platform string, playlistId string, offset int, limit int) (t *PlaylistTracks, err error) {
+	if platform != PlatformSpotify && platform != PlatformYoutube {
+		return nil, ErrUnknownPlatform
+	}
+
+	bp, err := b.repo.GetBackup(backupId)
+	if err != nil {
+		return
+	}
+
+	p, err := b.repo.GetPlaylistSummary(bp, platform, playlistId)
+	if err != nil {
+		return
+	}
+
+	t = &PlaylistTracks{Backup: bp, Playlist: *p}
+	if platform == PlatformSpotify {
+		t.Tracks, err = b.repo.GetPlaylistTracks(bp, playlistId, offset, limit)
+	} else {
+		t.YoutubeTracks, err = b.repo.GetYoutubePlaylistTracks(bp, playlistId, offset, limit)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return
+}
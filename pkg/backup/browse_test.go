@@ -0,0 +1,101 @@
+package backup
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type browseRepository struct {
+	Repository
+	backups []Backup
+}
+
+func (r *browseRepository) GetBackupsPage(userId string, offset int, limit int) (*[]Backup, error) {
+	b := r.backups[offset : offset+limit]
+	return &b, nil
+}
+
+func (r *browseRepository) GetBackupCount(userId string) (int64, error) {
+	return int64(len(r.backups)), nil
+}
+
+func (r *browseRepository) GetBackupPlaylistCount(b *Backup, platform Scope) (int64, error) {
+	return b.Id * 10, nil
+}
+
+func (r *browseRepository) GetBackupTrackCount(b *Backup, platform Scope) (int64, error) {
+	return b.Id * 100, nil
+}
+
+func (r *browseRepository) GetBackup(id int64) (*Backup, error) {
+	for _, v := range r.backups {
+		if v.Id == id {
+			return &v, nil
+		}
+	}
+
+	return nil, ErrBackupNotFound
+}
+
+var browsePlaylists = []PlaylistSummary{{Platform: PlatformSpotify, Id: "p1", TrackCount: 2}, {Platform: PlatformYoutube, Id: "p1", TrackCount: 1}}
+
+func (r *browseRepository) GetPlaylistSummaries(b *Backup, offset int, limit int) (*[]PlaylistSummary, error) {
+	p := browsePlaylists[offset : offset+limit]
+	return &p, nil
+}
+
+func (r *browseRepository) GetPlaylistSummary(b *Backup, platform string, id string) (*PlaylistSummary, error) {
+	for _, v := range browsePlaylists {
+		if v.Platform == platform && v.Id == id {
+			return &v, nil
+		}
+	}
+
+	return nil, ErrPlaylistNotFound
+}
+
+func (r *browseRepository) GetYoutubePlaylistTracks(b *Backup, youtubeId string, offset int, limit int) (*[]YoutubeTrack, error) {
+	return &[]YoutubeTrack{{YoutubeId: "v1"}}, nil
+}
+
+func TestListBackups(t *testing.T) {
+	b := &backuper{repo: &browseRepository{backups: []Backup{{Id: 3}, {Id: 2}, {Id: 1}}}}
+
+	page, err := b.ListBackups("user", 1, 2)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), page.Total)
+	require.Equal(t, []BackupSummary{{Backup{Id: 2}, 20, 200}, {Backup{Id: 1}, 10, 100}}, page.Backups)
+}
+
+func TestGetBackupPlaylists(t *testing.T) {
+	b := &backuper{repo: &browseRepository{backups: []Backup{{Id: 1}}}}
+
+	bp, page, err := b.GetBackupPlaylists(1, 1, 1)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), bp.Id)
+	require.Equal(t, int64(10), page.Total)
+	require.Equal(t, []PlaylistSummary{{Platform: PlatformYoutube, Id: "p1", TrackCount: 1}}, page.Playlists)
+
+	_, _, err = b.GetBackupPlaylists(2, 0, 1)
+	require.ErrorIs(t, err, ErrBackupNotFound)
+}
+
+func TestGetPlaylistTracks(t *testing.T) {
+	b := &backuper{repo: &browseRepository{backups: []Backup{{Id: 1}}}}
+
+	res, err := b.GetPlaylistTracks(1, PlatformYoutube, "p1", 0, 10)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), res.Playlist.TrackCount)
+	require.Nil(t, res.Tracks)
+	require.Len(t, *res.YoutubeTracks, 1)
+
+	_, err = b.GetPlaylistTracks(1, PlatformSpotify, "missing", 0, 10)
+	require.ErrorIs(t, err, ErrPlaylistNotFound)
+
+	_, err = b.GetPlaylistTracks(2, PlatformSpotify, "p1", 0, 10)
+	require.ErrorIs(t, err, ErrBackupNotFound)
+
+	_, err = b.GetPlaylistTracks(1, "other", "p1", 0, 10)
+	require.ErrorIs(t, err, ErrUnknownPlatform)
+}
@@ -0,0 +1,244 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrBackupCancelled = errors.New("backup: backup was cancelled")
+	ErrRunNotFound     = errors.New("backup: no such active or queued run")
+)
+
+// What caused the run to start
+type Trigger string
+
+const (
+	TriggerManual    Trigger = "manual"
+	TriggerScheduled Trigger = "scheduled"
+	TriggerApi       Trigger = "api"
+)
+
+// Which part of the backup is run, Spotify includes library as well
+type Scope string
+
+const (
+	ScopeAll     Scope = "all"
+	ScopeSpotify Scope = "spotify"
+	ScopeYoutube Scope = "youtube"
+)
+
+func (s Scope) covers(other Scope) bool {
+	return s == ScopeAll || s == other
+}
+
+type RunState string
+
+const (
+	RunQueued     RunState = "queued"
+	RunRunning    RunState = "running"
+	RunCancelling RunState = "cancelling"
+	RunFinished   RunState = "finished"
+	RunFailed     RunState = "failed"
+	RunCancelled  RunState = "cancelled"
+)
+
+// Snapshot of a single run
+type RunInfo struct {
+	Id        int64     `json:"id"`
+	Trigger   Trigger   `json:"trigger"`
+	Scope     Scope     `json:"scope"`
+	State     RunState  `json:"state"`
+	Requested time.Time `json:"requested"`
+	Started   time.Time `json:"started"`
+	Finished  time.Time `json:"finished"`
+	Error     string    `json:"error,omitempty"`
+	// Amount of triggers which joined the run instead of starting a new one
+	Joined int `json:"joined"`
+}
+
+type RunStatus struct {
+	Current *RunInfo `json:"current"`
+	Queued  *RunInfo `json:"queued"`
+	Last    *RunInfo `json:"last"`
+}
+
+type run struct {
+	info   RunInfo
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+	err    error
+}
+
+// Returns error of the run once it has finished
+func (r *run) wait() error {
+	<-r.done
+	return r.err
+}
+
+// Makes sure that only a single backup runs at a time. Triggers either join
+// the active run or queue a single follow up run, further triggers join the queued one.
+type coordinator struct {
+	mu      sync.Mutex
+	execute func(ctx context.Context, scope Scope) error
+	nextId  int64
+	current *run
+	queued  *run
+	last    *run
+}
+
+func newCoordinator(execute func(ctx context.Context, scope Scope) error) *coordinator {
+	return &coordinator{execute: execute}
+}
+
+// Joined is true if an already existing run was returned. Run that is being
+// cancelled or doesn't cover the scope is never joined, trigger is queued instead.
+// Queued run is widened to all if scopes of its triggers differ.
+func (c *coordinator) start(trigger Trigger, scope Scope, queue bool) (r *run, joined bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.current == nil {
+		r = c.newRun(trigger, scope)
+		c.begin(r)
+		return r, false
+	}
+
+	if !queue && c.current.info.State == RunRunning && c.current.info.Scope.covers(scope) {
+		c.current.info.Joined++
+		return c.current, true
+	}
+
+	if c.queued != nil {
+		if !c.queued.info.Scope.covers(scope) {
+			c.queued.info.Scope = ScopeAll
+		}
+
+		c.queued.info.Joined++
+		return c.queued, true
+	}
+
+	c.queued = c.newRun(trigger, scope)
+	return c.queued, false
+}
+
+// Cancels active or queued run, id 0 means the active one
+func (c *coordinator) cancel(id int64) (info RunInfo, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.current != nil && (id == 0 || id == c.current.info.Id) {
+		if c.current.info.State == RunRunning {
+			c.current.info.State = RunCancelling
+			c.current.cancel()
+		}
+
+		return c.current.info, nil
+	}
+
+	if c.queued != nil && id == c.queued.info.Id {
+		r := c.queued
+		c.queued = nil
+
+		r.info.State = RunCancelled
+		r.info.Finished = time.Now()
+		r.err = ErrBackupCancelled
+		r.cancel()
+		close(r.done)
+		return r.info, nil
+	}
+
+	return info, ErrRunNotFound
+}
+
+func (c *coordinator) status() RunStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return RunStatus{
+		Current: c.current.snapshot(),
+		Queued:  c.queued.snapshot(),
+		Last:    c.last.snapshot(),
+	}
+}
+
+func (c *coordinator) info(r *run) RunInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return r.info
+}
+
+// must be called with lock held, nil run has no info
+func (r *run) snapshot() *RunInfo {
+	if r == nil {
+		return nil
+	}
+
+	info := r.info
+	return &info
+}
+
+// must be called with lock held
+func (c *coordinator) newRun(trigger Trigger, scope Scope) *run {
+	c.nextId++
+	ctx, cancel := context.WithCancel(context.Background())
+	return &run{
+		info: RunInfo{
+			Id:        c.nextId,
+			Trigger:   trigger,
+			Scope:     scope,
+			State:     RunQueued,
+			Requested: time.Now(),
+		},
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+}
+
+// must be called with lock held
+func (c *coordinator) begin(r *run) {
+	c.current = r
+	r.info.State = RunRunning
+	r.info.Started = time.Now()
+	scope := r.info.Scope
+
+	go func() {
+		c.finish(r, c.execute(r.ctx, scope))
+	}()
+}
+
+func (c *coordinator) finish(r *run, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	r.cancel()
+	r.err = err
+	r.info.Finished = time.Now()
+	switch {
+	case errors.Is(err, ErrBackupCancelled):
+		r.info.State = RunCancelled
+	case err != nil:
+		r.info.State = RunFailed
+	default:
+		r.info.State = RunFinished
+	}
+
+	if err != nil {
+		r.info.Error = err.Error()
+	}
+
+	close(r.done)
+	c.last = r
+	c.current = nil
+
+	if c.queued != nil {
+		next := c.queued
+		c.queued = nil
+		c.begin(next)
+	}
+}
@@ -0,0 +1,141 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Runs block until released or cancelled
+type blockingRuns struct {
+	started chan int
+	release chan struct{}
+	count   int
+}
+
+func newBlockingRuns() *blockingRuns {
+	return &blockingRuns{started: make(chan int, 10), release: make(chan struct{})}
+}
+
+func (br *blockingRuns) execute(ctx context.Context, scope Scope) error {
+	br.count++
+	br.started <- br.count
+
+	select {
+	case <-br.release:
+		return nil
+	case <-ctx.Done():
+		return ErrBackupCancelled
+	}
+}
+
+func TestCoordinatorJoin(t *testing.T) {
+	br := newBlockingRuns()
+	c := newCoordinator(br.execute)
+
+	first, joined := c.start(TriggerScheduled, ScopeAll, false)
+	require.False(t, joined)
+	require.Equal(t, 1, <-br.started)
+
+	second, joined := c.start(TriggerManual, ScopeAll, false)
+	require.True(t, joined)
+	require.Equal(t, first, second)
+
+	s := c.status()
+	require.Equal(t, RunRunning, s.Current.State)
+	require.Equal(t, TriggerScheduled, s.Current.Trigger)
+	require.Equal(t, 1, s.Current.Joined)
+	require.Nil(t, s.Queued)
+
+	close(br.release)
+	require.NoError(t, second.wait())
+
+	s = c.status()
+	require.Nil(t, s.Current)
+	require.Equal(t, RunFinished, s.Last.State)
+	require.Equal(t, 1, br.count)
+}
+
+func TestCoordinatorQueue(t *testing.T) {
+	br := newBlockingRuns()
+	c := newCoordinator(br.execute)
+
+	first, _ := c.start(TriggerManual, ScopeAll, true)
+	<-br.started
+
+	queued, joined := c.start(TriggerManual, ScopeAll, true)
+	require.False(t, joined)
+	require.NotEqual(t, first, queued)
+
+	// only a single run is queued
+	again, joined := c.start(TriggerApi, ScopeAll, true)
+	require.True(t, joined)
+	require.Equal(t, queued, again)
+	require.Equal(t, RunQueued, c.status().Queued.State)
+
+	close(br.release)
+	require.NoError(t, first.wait())
+	require.Equal(t, 2, <-br.started)
+	require.NoError(t, queued.wait())
+	require.Equal(t, int64(2), c.status().Last.Id)
+}
+
+func TestCoordinatorCancel(t *testing.T) {
+	br := newBlockingRuns()
+	c := newCoordinator(br.execute)
+
+	_, err := c.cancel(0)
+	require.True(t, errors.Is(err, ErrRunNotFound))
+
+	first, _ := c.start(TriggerManual, ScopeAll, false)
+	<-br.started
+	queued, _ := c.start(TriggerManual, ScopeAll, true)
+
+	info, err := c.cancel(c.info(queued).Id)
+	require.NoError(t, err)
+	require.Equal(t, RunCancelled, info.State)
+	require.True(t, errors.Is(queued.wait(), ErrBackupCancelled))
+
+	info, err = c.cancel(0)
+	require.NoError(t, err)
+	require.Equal(t, RunCancelling, info.State)
+
+	// cancelling run is not joined, trigger is queued instead
+	next, joined := c.start(TriggerManual, ScopeAll, false)
+	require.False(t, joined)
+	require.NotEqual(t, first, next)
+
+	require.True(t, errors.Is(first.wait(), ErrBackupCancelled))
+	require.Equal(t, RunCancelled, c.status().Last.State)
+
+	require.Equal(t, 2, <-br.started)
+	close(br.release)
+	require.NoError(t, next.wait())
+}
+
+func TestCoordinatorScope(t *testing.T) {
+	br := newBlockingRuns()
+	c := newCoordinator(br.execute)
+
+	first, _ := c.start(TriggerScheduled, ScopeSpotify, false)
+	<-br.started
+
+	spotify, joined := c.start(TriggerManual, ScopeSpotify, false)
+	require.True(t, joined)
+	require.Equal(t, first, spotify)
+
+	// running Spotify backup doesn't save Youtube
+	youtube, joined := c.start(TriggerScheduled, ScopeYoutube, false)
+	require.False(t, joined)
+	require.Equal(t, ScopeYoutube, c.info(youtube).Scope)
+
+	all, joined := c.start(TriggerManual, ScopeAll, false)
+	require.True(t, joined)
+	require.Equal(t, youtube, all)
+	require.Equal(t, ScopeAll, c.status().Queued.Scope)
+
+	close(br.release)
+	require.NoError(t, all.wait())
+}
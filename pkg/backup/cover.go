@@ -0,0 +1,89 @@
+package backup
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/zmb3/spotify"
+	gyoutube "google.golang.org/api/youtube/v3"
+)
+
+// Covers are small, so anything taking longer is most likely stuck
+var coverClient = &http.Client{Timeout: 30 * time.Second}
+
+// Downloads cover into blob store and returns its key. Missing cover
+// shouldn't fail the whole playlist, so errors are only logged.
+func (b *backuper) storeCover(url string) string {
+	if b.covers == nil || url == "" {
+		return ""
+	}
+
+	data, err := downloadCover(url)
+	if err != nil {
+		log.Warn().Err(err).Msgf("backuper: failed to download cover from %s", url)
+		return ""
+	}
+
+	key, err := b.covers.Put(data)
+	if err != nil {
+		log.Warn().Err(err).Msgf("backuper: failed to store cover from %s", url)
+		return ""
+	}
+
+	return key
+}
+
+// Reused playlist keeps cover stored with its snapshot, it is only
+// downloaded if it wasn't stored before, e.g. covers were disabled.
+func (b *backuper) reuseCover(p *Playlist) error {
+	if p.CoverBlob != "" {
+		return nil
+	}
+
+	p.CoverBlob = b.storeCover(p.CoverUrl)
+	if p.CoverBlob == "" {
+		return nil
+	}
+
+	return b.repo.SetPlaylistCover(p)
+}
+
+func downloadCover(url string) (data []byte, err error) {
+	resp, err := coverClient.Get(url)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("backuper: cover request returned %d", resp.StatusCode)
+	}
+
+	return ioutil.ReadAll(resp.Body)
+}
+
+// Images are ordered widest first
+func spotifyCoverUrl(images []spotify.Image) string {
+	if len(images) == 0 {
+		return ""
+	}
+
+	return images[0].URL
+}
+
+func youtubeCoverUrl(t *gyoutube.ThumbnailDetails) string {
+	if t == nil {
+		return ""
+	}
+
+	for _, v := range []*gyoutube.Thumbnail{t.Maxres, t.Standard, t.High, t.Medium, t.Default} {
+		if v != nil && v.Url != "" {
+			return v.Url
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,89 @@
+package backup
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hoffs/crispy-musicular/pkg/blob"
+	"github.com/stretchr/testify/require"
+	"github.com/zmb3/spotify"
+	gyoutube "google.golang.org/api/youtube/v3"
+)
+
+func TestSpotifyCoverUrl(t *testing.T) {
+	require.Equal(t, "", spotifyCoverUrl(nil))
+	require.Equal(t, "wide", spotifyCoverUrl([]spotify.Image{{URL: "wide"}, {URL: "small"}}))
+}
+
+func TestYoutubeCoverUrl(t *testing.T) {
+	require.Equal(t, "", youtubeCoverUrl(nil))
+	require.Equal(t, "high", youtubeCoverUrl(&gyoutube.ThumbnailDetails{
+		Default: &gyoutube.Thumbnail{Url: "default"},
+		High:    &gyoutube.Thumbnail{Url: "high"},
+	}))
+}
+
+func TestStoreCover(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/cover" {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Write([]byte("image"))
+	}))
+	defer srv.Close()
+
+	b := &backuper{}
+	require.Equal(t, "", b.storeCover(srv.URL+"/cover"))
+
+	covers, err := blob.NewFileStore(t.TempDir())
+	require.NoError(t, err)
+	b.covers = covers
+
+	key := b.storeCover(srv.URL + "/cover")
+	require.NotEmpty(t, key)
+
+	data, err := covers.Get(key)
+	require.NoError(t, err)
+	require.Equal(t, []byte("image"), data)
+
+	require.Equal(t, "", b.storeCover(srv.URL+"/missing"))
+}
+
+type coverRepository struct {
+	Repository
+	updated []Playlist
+}
+
+func (r *coverRepository) SetPlaylistCover(p *Playlist) error {
+	r.updated = append(r.updated, *p)
+	return nil
+}
+
+func TestReuseCover(t *testing.T) {
+	downloads := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downloads++
+		w.Write([]byte("image"))
+	}))
+	defer srv.Close()
+
+	covers, err := blob.NewFileStore(t.TempDir())
+	require.NoError(t, err)
+	repo := &coverRepository{}
+	b := &backuper{covers: covers, repo: repo}
+
+	// cover stored with the snapshot is not downloaded again
+	p := &Playlist{Id: 1, CoverUrl: srv.URL, CoverBlob: "stored"}
+	require.NoError(t, b.reuseCover(p))
+	require.Equal(t, 0, downloads)
+	require.Empty(t, repo.updated)
+
+	p.CoverBlob = ""
+	require.NoError(t, b.reuseCover(p))
+	require.Equal(t, 1, downloads)
+	require.Len(t, repo.updated, 1)
+	require.NotEmpty(t, repo.updated[0].CoverBlob)
+}
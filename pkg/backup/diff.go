@@ -0,0 +1,312 @@
+package backup
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrDifferentUsers = errors.New("backup: backups belong to different users")
+	ErrNoCommonScope  = errors.New("backup: backups don't have any platform in common")
+)
+
+// Change of a single track between two backups of the same playlist.
+// Positions are 0 based, OldPosition is -1 for added tracks and
+// Position is -1 for removed tracks.
+type TrackChange struct {
+	Id          string
+	Name        string
+	OldName     string
+	Artist      string
+	Position    int
+	OldPosition int
+}
+
+type PlaylistDiff struct {
+	Id      string
+	Name    string
+	OldName string
+	Created bool
+	Deleted bool
+
+	Added     []TrackChange
+	Removed   []TrackChange
+	Renamed   []TrackChange
+	Reordered []TrackChange
+}
+
+func (d *PlaylistDiff) IsRenamed() bool {
+	return d.OldName != "" && d.OldName != d.Name
+}
+
+func (d *PlaylistDiff) HasChanges() bool {
+	return d.Created || d.Deleted || d.IsRenamed() ||
+		len(d.Added) > 0 || len(d.Removed) > 0 || len(d.Renamed) > 0 || len(d.Reordered) > 0
+}
+
+type BackupDiff struct {
+	From *Backup
+	To   *Backup
+	// Platforms covered by both backups, others are not compared
+	Scope   Scope
+	Spotify []PlaylistDiff
+	Youtube []PlaylistDiff
+}
+
+// Compares backups with provided ids, where from is expected to be the older one.
+// Only platforms which are covered by both backups are compared, otherwise a partial
+// backup would show every playlist of the other platform as created or deleted.
+func (b *backuper) DiffBackups(fromId int64, toId int64) (d *BackupDiff, err error) {
+	from, err := b.repo.GetBackup(fromId)
+	if err != nil {
+		return
+	}
+
+	to, err := b.repo.GetBackup(toId)
+	if err != nil {
+		return
+	}
+
+	if from.UserId != to.UserId {
+		return nil, ErrDifferentUsers
+	}
+
+	spotify := from.Covers(ScopeSpotify) && to.Covers(ScopeSpotify)
+	youtube := from.Covers(ScopeYoutube) && to.Covers(ScopeYoutube)
+	scope := ScopeAll
+	switch {
+	case spotify && youtube:
+	case spotify:
+		scope = ScopeSpotify
+	case youtube:
+		scope = ScopeYoutube
+	default:
+		return nil, ErrNoCommonScope
+	}
+
+	fp, ft, fyp, fyt, _, err := b.repo.GetBackupData(from)
+	if err != nil {
+		return
+	}
+
+	tp, tt, typ, tyt, _, err := b.repo.GetBackupData(to)
+	if err != nil {
+		return
+	}
+
+	d = &BackupDiff{From: from, To: to, Scope: scope}
+	if spotify {
+		d.Spotify = diffPlaylists(spotifyDiffPlaylists(fp, ft), spotifyDiffPlaylists(tp, tt))
+	}
+
+	if youtube {
+		d.Youtube = diffPlaylists(youtubeDiffPlaylists(fyp, fyt), youtubeDiffPlaylists(typ, tyt))
+	}
+
+	return
+}
+
+// Common representation of Spotify and Youtube data used for comparison
+type diffPlaylist struct {
+	id     string
+	name   string
+	tracks []diffTrack
+}
+
+type diffTrack struct {
+	key    string
+	id     string
+	name   string
+	artist string
+}
+
+func spotifyDiffPlaylists(p *[]Playlist, t *[]Track) map[string]*diffPlaylist {
+	byId := make(map[int64]*diffPlaylist)
+	playlists := make(map[string]*diffPlaylist)
+	if p != nil {
+		for _, v := range *p {
+			dp := &diffPlaylist{id: v.SpotifyId, name: v.Name}
+			byId[v.Id] = dp
+			playlists[v.SpotifyId] = dp
+		}
+	}
+
+	if t != nil {
+		for _, v := range *t {
+			dp, ok := byId[v.PlaylistId]
+			if !ok {
+				continue
+			}
+
+			// local files don't have an id, so other values have to identify them
+			key := v.SpotifyId
+			if key == "" {
+				key = strings.Join([]string{"local", v.Name, v.Artist, v.Album}, "\x1f")
+			}
+
+			dp.tracks = append(dp.tracks, diffTrack{key: key, id: v.SpotifyId, name: v.Name, artist: v.Artist})
+		}
+	}
+
+	return playlists
+}
+
+func youtubeDiffPlaylists(p *[]YoutubePlaylist, t *[]YoutubeTrack) map[string]*diffPlaylist {
+	byId := make(map[int64]*diffPlaylist)
+	playlists := make(map[string]*diffPlaylist)
+	if p != nil {
+		for _, v := range *p {
+			dp := &diffPlaylist{id: v.YoutubeId, name: v.Name}
+			byId[v.Id] = dp
+			playlists[v.YoutubeId] = dp
+		}
+	}
+
+	if t != nil {
+		for _, v := range *t {
+			dp, ok := byId[v.PlaylistId]
+			if !ok {
+				continue
+			}
+
+			dp.tracks = append(dp.tracks, diffTrack{key: v.YoutubeId, id: v.YoutubeId, name: v.Name, artist: v.ChannelTitle})
+		}
+	}
+
+	return playlists
+}
+
+// Returns only playlists which have changes, sorted by name.
+func diffPlaylists(from map[string]*diffPlaylist, to map[string]*diffPlaylist) (d []PlaylistDiff) {
+	for id, tp := range to {
+		fp, ok := from[id]
+		if !ok {
+			pd := PlaylistDiff{Id: id, Name: tp.name, Created: true}
+			for pos, t := range tp.tracks {
+				pd.Added = append(pd.Added, TrackChange{Id: t.id, Name: t.name, Artist: t.artist, Position: pos, OldPosition: -1})
+			}
+
+			d = append(d, pd)
+			continue
+		}
+
+		pd := diffTracks(fp.tracks, tp.tracks)
+		pd.Id = id
+		pd.Name = tp.name
+		if fp.name != tp.name {
+			pd.OldName = fp.name
+		}
+
+		if pd.HasChanges() {
+			d = append(d, pd)
+		}
+	}
+
+	for id, fp := range from {
+		if _, ok := to[id]; ok {
+			continue
+		}
+
+		pd := PlaylistDiff{Id: id, Name: fp.name, Deleted: true}
+		for pos, t := range fp.tracks {
+			pd.Removed = append(pd.Removed, TrackChange{Id: t.id, Name: t.name, Artist: t.artist, Position: -1, OldPosition: pos})
+		}
+
+		d = append(d, pd)
+	}
+
+	sort.SliceStable(d, func(i, j int) bool {
+		if d[i].Name == d[j].Name {
+			return d[i].Id < d[j].Id
+		}
+		return d[i].Name < d[j].Name
+	})
+
+	return
+}
+
+// Tracks are matched by key, if the same track is in a playlist multiple
+// times occurrences are matched in order. Matched tracks that are not part
+// of the longest sequence which kept its relative order are reported as reordered,
+// that way moving a single track doesn't report every track in between.
+func diffTracks(from []diffTrack, to []diffTrack) (d PlaylistDiff) {
+	occurrences := make(map[string][]int)
+	for pos, t := range from {
+		occurrences[t.key] = append(occurrences[t.key], pos)
+	}
+
+	matchedFrom := make([]bool, len(from))
+	// old position for each matched track in the new order
+	var matchedNew []int
+	var matchedOld []int
+
+	for pos, t := range to {
+		o := occurrences[t.key]
+		if len(o) == 0 {
+			d.Added = append(d.Added, TrackChange{Id: t.id, Name: t.name, Artist: t.artist, Position: pos, OldPosition: -1})
+			continue
+		}
+
+		oldPos := o[0]
+		occurrences[t.key] = o[1:]
+		matchedFrom[oldPos] = true
+		matchedNew = append(matchedNew, pos)
+		matchedOld = append(matchedOld, oldPos)
+
+		if from[oldPos].name != t.name {
+			d.Renamed = append(d.Renamed, TrackChange{Id: t.id, Name: t.name, OldName: from[oldPos].name, Artist: t.artist, Position: pos, OldPosition: oldPos})
+		}
+	}
+
+	for pos, t := range from {
+		if !matchedFrom[pos] {
+			d.Removed = append(d.Removed, TrackChange{Id: t.id, Name: t.name, Artist: t.artist, Position: -1, OldPosition: pos})
+		}
+	}
+
+	stable := longestIncreasing(matchedOld)
+	for id := range matchedOld {
+		if stable[id] {
+			continue
+		}
+
+		t := to[matchedNew[id]]
+		d.Reordered = append(d.Reordered, TrackChange{Id: t.id, Name: t.name, Artist: t.artist, Position: matchedNew[id], OldPosition: matchedOld[id]})
+	}
+
+	return
+}
+
+// Marks values which are part of the longest strictly increasing subsequence.
+func longestIncreasing(v []int) []bool {
+	// tails[l] is index of the smallest tail of increasing subsequence with length l+1
+	tails := make([]int, 0, len(v))
+	prev := make([]int, len(v))
+
+	for i, x := range v {
+		l := sort.Search(len(tails), func(j int) bool { return v[tails[j]] >= x })
+		if l > 0 {
+			prev[i] = tails[l-1]
+		} else {
+			prev[i] = -1
+		}
+
+		if l == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[l] = i
+		}
+	}
+
+	marked := make([]bool, len(v))
+	if len(tails) == 0 {
+		return marked
+	}
+
+	for i := tails[len(tails)-1]; i >= 0; i = prev[i] {
+		marked[i] = true
+	}
+
+	return marked
+}
@@ -0,0 +1,156 @@
+package backup
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func tracks(keys ...string) (t []diffTrack) {
+	for _, k := range keys {
+		t = append(t, diffTrack{key: k, id: k, name: k})
+	}
+	return
+}
+
+func TestDiffTracksUnchanged(t *testing.T) {
+	d := diffTracks(tracks("a", "b", "c"), tracks("a", "b", "c"))
+	require.False(t, d.HasChanges())
+}
+
+func TestDiffTracksAddedRemoved(t *testing.T) {
+	d := diffTracks(tracks("a", "b", "c"), tracks("a", "c", "d"))
+
+	require.Len(t, d.Added, 1)
+	require.Equal(t, "d", d.Added[0].Id)
+	require.Equal(t, 2, d.Added[0].Position)
+	require.Equal(t, -1, d.Added[0].OldPosition)
+
+	require.Len(t, d.Removed, 1)
+	require.Equal(t, "b", d.Removed[0].Id)
+	require.Equal(t, 1, d.Removed[0].OldPosition)
+
+	require.Empty(t, d.Reordered)
+}
+
+func TestDiffTracksSingleMove(t *testing.T) {
+	d := diffTracks(tracks("a", "b", "c", "d", "e"), tracks("b", "c", "d", "e", "a"))
+
+	require.Empty(t, d.Added)
+	require.Empty(t, d.Removed)
+	require.Len(t, d.Reordered, 1)
+	require.Equal(t, "a", d.Reordered[0].Id)
+	require.Equal(t, 4, d.Reordered[0].Position)
+	require.Equal(t, 0, d.Reordered[0].OldPosition)
+}
+
+func TestDiffTracksDuplicates(t *testing.T) {
+	d := diffTracks(tracks("a", "b", "a"), tracks("a", "b"))
+
+	require.Len(t, d.Removed, 1)
+	require.Equal(t, "a", d.Removed[0].Id)
+	require.Equal(t, 2, d.Removed[0].OldPosition)
+	require.Empty(t, d.Reordered)
+}
+
+func TestDiffTracksRenamed(t *testing.T) {
+	from := tracks("a", "b")
+	to := tracks("a", "b")
+	to[1].name = "Deleted video"
+
+	d := diffTracks(from, to)
+	require.Len(t, d.Renamed, 1)
+	require.Equal(t, "b", d.Renamed[0].OldName)
+	require.Equal(t, "Deleted video", d.Renamed[0].Name)
+}
+
+func TestDiffPlaylists(t *testing.T) {
+	from := spotifyDiffPlaylists(
+		&[]Playlist{{Id: 1, SpotifyId: "P1", Name: "One"}, {Id: 2, SpotifyId: "P2", Name: "Two"}},
+		&[]Track{{SpotifyId: "a", Name: "a", PlaylistId: 1}, {SpotifyId: "b", Name: "b", PlaylistId: 2}},
+	)
+	to := spotifyDiffPlaylists(
+		&[]Playlist{{Id: 3, SpotifyId: "P1", Name: "One renamed"}, {Id: 4, SpotifyId: "P3", Name: "Three"}},
+		&[]Track{{SpotifyId: "a", Name: "a", PlaylistId: 3}, {SpotifyId: "c", Name: "c", PlaylistId: 4}},
+	)
+
+	d := diffPlaylists(from, to)
+	require.Len(t, d, 3)
+
+	require.Equal(t, "One renamed", d[0].Name)
+	require.True(t, d[0].IsRenamed())
+	require.Equal(t, "One", d[0].OldName)
+
+	require.Equal(t, "Three", d[1].Name)
+	require.True(t, d[1].Created)
+	require.Len(t, d[1].Added, 1)
+
+	require.Equal(t, "Two", d[2].Name)
+	require.True(t, d[2].Deleted)
+	require.Len(t, d[2].Removed, 1)
+}
+
+func TestDiffPlaylistsLocalFiles(t *testing.T) {
+	from := spotifyDiffPlaylists(
+		&[]Playlist{{Id: 1, SpotifyId: "P1", Name: "One"}},
+		&[]Track{{Name: "local a", Artist: "x", PlaylistId: 1}, {Name: "local b", Artist: "x", PlaylistId: 1}},
+	)
+	to := spotifyDiffPlaylists(
+		&[]Playlist{{Id: 2, SpotifyId: "P1", Name: "One"}},
+		&[]Track{{Name: "local a", Artist: "x", PlaylistId: 2}},
+	)
+
+	d := diffPlaylists(from, to)
+	require.Len(t, d, 1)
+	require.Len(t, d[0].Removed, 1)
+	require.Equal(t, "local b", d[0].Removed[0].Name)
+}
+
+func TestDiffBackupsDifferentUsers(t *testing.T) {
+	b := &backuper{repo: &browseRepository{backups: []Backup{{Id: 1, UserId: "a"}, {Id: 2, UserId: "b"}}}}
+
+	_, err := b.DiffBackups(1, 2)
+	require.ErrorIs(t, err, ErrDifferentUsers)
+}
+
+type diffRepository struct {
+	browseRepository
+}
+
+// Every backup has different playlists, so compared platforms always have changes
+func (r *diffRepository) GetBackupData(b *Backup) (*[]Playlist, *[]Track, *[]YoutubePlaylist, *[]YoutubeTrack, *Library, error) {
+	id := fmt.Sprint(b.Id)
+	return &[]Playlist{{Id: b.Id, SpotifyId: "s" + id}}, &[]Track{},
+		&[]YoutubePlaylist{{Id: b.Id, YoutubeId: "y" + id}}, &[]YoutubeTrack{}, &Library{}, nil
+}
+
+func TestDiffBackupsPartial(t *testing.T) {
+	b := &backuper{repo: &diffRepository{browseRepository{backups: []Backup{
+		{Id: 1, UserId: "a", Scope: ScopeAll},
+		{Id: 2, UserId: "a", Scope: ScopeYoutube},
+		{Id: 3, UserId: "a", Scope: ScopeSpotify},
+		{Id: 4, UserId: "a"},
+	}}}}
+
+	d, err := b.DiffBackups(1, 4)
+	require.NoError(t, err)
+	require.Equal(t, ScopeAll, d.Scope)
+	require.Len(t, d.Spotify, 2)
+	require.Len(t, d.Youtube, 2)
+
+	d, err = b.DiffBackups(1, 2)
+	require.NoError(t, err)
+	require.Equal(t, ScopeYoutube, d.Scope)
+	require.Nil(t, d.Spotify)
+	require.Len(t, d.Youtube, 2)
+
+	d, err = b.DiffBackups(3, 1)
+	require.NoError(t, err)
+	require.Equal(t, ScopeSpotify, d.Scope)
+	require.Len(t, d.Spotify, 2)
+	require.Nil(t, d.Youtube)
+
+	_, err = b.DiffBackups(2, 3)
+	require.ErrorIs(t, err, ErrNoCommonScope)
+}
@@ -0,0 +1,462 @@
+package backup
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Every change to Document must increase the version and be reflected in document.schema.json
+const DocumentVersion = 2
+
+//go:embed document.schema.json
+var DocumentJsonSchema []byte
+
+var (
+	ErrUnsupportedDocument = errors.New("backup: unsupported document version")
+	ErrBackupExists        = errors.New("backup: backup already exists")
+	ErrForeignBackup       = errors.New("backup: backup belongs to another user")
+	ErrUnknownScope        = errors.New("backup: unknown backup scope")
+)
+
+// Versioned format of a whole backup as written by file based
+// post backup actions and read by import.
+type Document struct {
+	Version int             `json:"version"`
+	Backup  DocumentBackup  `json:"backup"`
+	Spotify DocumentSpotify `json:"spotify"`
+	Youtube DocumentYoutube `json:"youtube"`
+}
+
+type DocumentBackup struct {
+	UserId   string    `json:"userId"`
+	Success  bool      `json:"success"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	// Missing in version 1, which always had all platforms
+	Scope Scope `json:"scope"`
+}
+
+type DocumentSpotify struct {
+	Playlists []DocumentPlaylist `json:"playlists"`
+	Albums    []DocumentAlbum    `json:"albums"`
+	Artists   []DocumentArtist   `json:"artists"`
+	Shows     []DocumentShow     `json:"shows"`
+}
+
+type DocumentPlaylist struct {
+	Id            string          `json:"id"`
+	SnapshotId    string          `json:"snapshotId"`
+	Name          string          `json:"name"`
+	Description   string          `json:"description"`
+	OwnerId       string          `json:"ownerId"`
+	OwnerName     string          `json:"ownerName"`
+	Collaborative bool            `json:"collaborative"`
+	Public        bool            `json:"public"`
+	CoverUrl      string          `json:"coverUrl"`
+	CoverBlob     string          `json:"coverBlob"`
+	Position      int             `json:"position"`
+	Tracks        []DocumentTrack `json:"tracks"`
+}
+
+type DocumentTrack struct {
+	Id               string       `json:"id"`
+	Uri              string       `json:"uri"`
+	Isrc             string       `json:"isrc"`
+	Name             string       `json:"name"`
+	Artist           string       `json:"artist"`
+	ArtistIds        []string     `json:"artistIds"`
+	Album            string       `json:"album"`
+	AlbumArtist      string       `json:"albumArtist"`
+	AlbumReleaseDate string       `json:"albumReleaseDate"`
+	DurationMs       int          `json:"durationMs"`
+	TrackNumber      int          `json:"trackNumber"`
+	DiscNumber       int          `json:"discNumber"`
+	Explicit         bool         `json:"explicit"`
+	IsLocal          bool         `json:"isLocal"`
+	AddedBy          string       `json:"addedBy"`
+	AddedAt          string       `json:"addedAt"`
+	Availability     Availability `json:"availability"`
+}
+
+type DocumentAlbum struct {
+	Id          string `json:"id"`
+	Name        string `json:"name"`
+	Artist      string `json:"artist"`
+	ReleaseDate string `json:"releaseDate"`
+	AddedAt     string `json:"addedAt"`
+}
+
+type DocumentArtist struct {
+	Id     string `json:"id"`
+	Name   string `json:"name"`
+	Genres string `json:"genres"`
+}
+
+type DocumentShow struct {
+	Id        string `json:"id"`
+	Name      string `json:"name"`
+	Publisher string `json:"publisher"`
+	AddedAt   string `json:"addedAt"`
+}
+
+type DocumentYoutube struct {
+	Playlists []DocumentYoutubePlaylist `json:"playlists"`
+}
+
+type DocumentYoutubePlaylist struct {
+	Id            string                 `json:"id"`
+	Name          string                 `json:"name"`
+	Description   string                 `json:"description"`
+	ChannelId     string                 `json:"channelId"`
+	ChannelTitle  string                 `json:"channelTitle"`
+	PrivacyStatus string                 `json:"privacyStatus"`
+	CoverUrl      string                 `json:"coverUrl"`
+	CoverBlob     string                 `json:"coverBlob"`
+	Position      int                    `json:"position"`
+	Tracks        []DocumentYoutubeTrack `json:"tracks"`
+}
+
+type DocumentYoutubeTrack struct {
+	Id              string            `json:"id"`
+	Name            string            `json:"name"`
+	ChannelId       string            `json:"channelId"`
+	ChannelTitle    string            `json:"channelTitle"`
+	Description     string            `json:"description"`
+	Tags            []string          `json:"tags"`
+	CategoryId      string            `json:"categoryId"`
+	DurationSeconds int               `json:"durationSeconds"`
+	PublishedAt     string            `json:"publishedAt"`
+	PrivacyStatus   string            `json:"privacyStatus"`
+	Thumbnails      map[string]string `json:"thumbnails"`
+	AddedAt         string            `json:"addedAt"`
+	Availability    Availability      `json:"availability"`
+	// Only present for music with auto generated description
+	Music *DocumentYoutubeMusic `json:"music,omitempty"`
+}
+
+type DocumentYoutubeMusic struct {
+	Title       string `json:"title"`
+	Artist      string `json:"artist"`
+	Album       string `json:"album"`
+	Label       string `json:"label"`
+	ReleaseDate string `json:"releaseDate"`
+}
+
+// Creates document from backup data as returned by GetBackupData,
+// tracks are nested in playlists using their PlaylistId.
+func NewDocument(bp *Backup, p *[]Playlist, t *[]Track, yp *[]YoutubePlaylist, yt *[]YoutubeTrack, l *Library) *Document {
+	d := &Document{
+		Version: DocumentVersion,
+		Backup:  DocumentBackup{bp.UserId, bp.Success, bp.Started, bp.Finished, bp.Scope},
+		Spotify: DocumentSpotify{
+			Playlists: []DocumentPlaylist{},
+			Albums:    []DocumentAlbum{},
+			Artists:   []DocumentArtist{},
+			Shows:     []DocumentShow{},
+		},
+		Youtube: DocumentYoutube{Playlists: []DocumentYoutubePlaylist{}},
+	}
+
+	tracks := make(map[int64][]DocumentTrack)
+	if t != nil {
+		for _, v := range *t {
+			tracks[v.PlaylistId] = append(tracks[v.PlaylistId], NewDocumentTrack(&v))
+		}
+	}
+
+	if p != nil {
+		for _, v := range *p {
+			dt := tracks[v.Id]
+			if dt == nil {
+				dt = []DocumentTrack{}
+			}
+
+			d.Spotify.Playlists = append(d.Spotify.Playlists, DocumentPlaylist{
+				v.SpotifyId, v.SnapshotId, v.Name, v.Description, v.OwnerId, v.OwnerName,
+				v.Collaborative, v.Public, v.CoverUrl, v.CoverBlob, v.Position, dt,
+			})
+		}
+	}
+
+	ytracks := make(map[int64][]DocumentYoutubeTrack)
+	if yt != nil {
+		for _, v := range *yt {
+			ytracks[v.PlaylistId] = append(ytracks[v.PlaylistId], NewDocumentYoutubeTrack(&v))
+		}
+	}
+
+	if yp != nil {
+		for _, v := range *yp {
+			dt := ytracks[v.Id]
+			if dt == nil {
+				dt = []DocumentYoutubeTrack{}
+			}
+
+			d.Youtube.Playlists = append(d.Youtube.Playlists, DocumentYoutubePlaylist{
+				v.YoutubeId, v.Name, v.Description, v.ChannelId, v.ChannelTitle, v.PrivacyStatus,
+				v.CoverUrl, v.CoverBlob, v.Position, dt,
+			})
+		}
+	}
+
+	if l != nil {
+		for _, v := range l.Albums {
+			d.Spotify.Albums = append(d.Spotify.Albums, DocumentAlbum{v.SpotifyId, v.Name, v.Artist, v.ReleaseDate, v.AddedAt})
+		}
+
+		for _, v := range l.Artists {
+			d.Spotify.Artists = append(d.Spotify.Artists, DocumentArtist{v.SpotifyId, v.Name, v.Genres})
+		}
+
+		for _, v := range l.Shows {
+			d.Spotify.Shows = append(d.Spotify.Shows, DocumentShow{v.SpotifyId, v.Name, v.Publisher, v.AddedAt})
+		}
+	}
+
+	return d
+}
+
+func NewDocumentTrack(t *Track) DocumentTrack {
+	return DocumentTrack{
+		t.SpotifyId, t.Uri, t.Isrc, t.Name, t.Artist, t.ArtistIds, t.Album, t.AlbumArtist, t.AlbumReleaseDate,
+		t.DurationMs, t.TrackNumber, t.DiscNumber, t.Explicit, t.IsLocal, t.AddedBy, t.AddedAtToPlaylist, t.Availability,
+	}
+}
+
+func NewDocumentYoutubeTrack(t *YoutubeTrack) DocumentYoutubeTrack {
+	dt := DocumentYoutubeTrack{
+		t.YoutubeId, t.Name, t.ChannelId, t.ChannelTitle, t.Description, t.Tags, t.CategoryId,
+		t.DurationSeconds, t.PublishedAt, t.PrivacyStatus, t.Thumbnails, t.AddedAtToPlaylist, t.Availability, nil,
+	}
+
+	if t.MusicTitle != "" {
+		dt.Music = &DocumentYoutubeMusic{t.MusicTitle, t.MusicArtist, t.MusicAlbum, t.MusicLabel, t.MusicReleaseDate}
+	}
+
+	return dt
+}
+
+func ParseDocument(data []byte) (d *Document, err error) {
+	d = &Document{}
+	err = json.Unmarshal(data, d)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.Version < 1 || d.Version > DocumentVersion {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedDocument, d.Version)
+	}
+
+	if d.Backup.UserId == "" || d.Backup.Started.IsZero() {
+		return nil, errors.New("backup: document is missing backup user or start time")
+	}
+
+	switch d.Backup.Scope {
+	case "":
+		d.Backup.Scope = ScopeAll
+	case ScopeAll, ScopeSpotify, ScopeYoutube:
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownScope, d.Backup.Scope)
+	}
+
+	return
+}
+
+// Stores backup from the document as a new backup. Backup of the same user
+// started at the same time is considered the same backup and is not imported again.
+// Only backups of the authenticated user can be imported.
+func (b *backuper) Import(d *Document) (bp *Backup, err error) {
+	st, err := b.auth.GetState()
+	if err != nil {
+		return
+	}
+
+	if d.Backup.UserId != st.User {
+		return nil, fmt.Errorf("%w: %s", ErrForeignBackup, d.Backup.UserId)
+	}
+
+	existing, err := b.repo.GetBackups(d.Backup.UserId)
+	if err != nil {
+		return
+	}
+
+	for _, v := range *existing {
+		if v.Started.Equal(d.Backup.Started) {
+			return nil, fmt.Errorf("%w: backup %d started at %s", ErrBackupExists, v.Id, v.Started.Format(time.RFC3339))
+		}
+	}
+
+	bp = &Backup{UserId: d.Backup.UserId, Started: d.Backup.Started, Scope: d.Backup.Scope}
+	err = b.repo.AddBackup(bp)
+	if err != nil {
+		return
+	}
+
+	err = b.importDocument(bp, d)
+	if err != nil {
+		log.Error().Err(err).Msgf("backuper_import: failed to import backup, deleting partially imported backup %d", bp.Id)
+		b.deleteBackups(&[]Backup{*bp})
+		return nil, err
+	}
+
+	bp.Success = d.Backup.Success
+	bp.Finished = d.Backup.Finished
+	err = b.repo.UpdateBackup(bp)
+	return
+}
+
+func (b *backuper) importDocument(bp *Backup, d *Document) (err error) {
+	created := d.Backup.Started
+
+	for _, v := range d.Spotify.Playlists {
+		p := &Playlist{
+			SpotifyId:     v.Id,
+			SnapshotId:    v.SnapshotId,
+			Name:          v.Name,
+			Description:   v.Description,
+			OwnerId:       v.OwnerId,
+			OwnerName:     v.OwnerName,
+			Collaborative: v.Collaborative,
+			Public:        v.Public,
+			CoverUrl:      v.CoverUrl,
+			CoverBlob:     b.importCover(v.CoverBlob),
+			Position:      v.Position,
+			Created:       created,
+		}
+
+		t := make([]Track, 0, len(v.Tracks))
+		for _, dt := range v.Tracks {
+			t = append(t, Track{
+				SpotifyId:         dt.Id,
+				Uri:               dt.Uri,
+				Isrc:              dt.Isrc,
+				Name:              dt.Name,
+				Artist:            dt.Artist,
+				ArtistIds:         dt.ArtistIds,
+				Album:             dt.Album,
+				AlbumArtist:       dt.AlbumArtist,
+				AlbumReleaseDate:  dt.AlbumReleaseDate,
+				DurationMs:        dt.DurationMs,
+				TrackNumber:       dt.TrackNumber,
+				DiscNumber:        dt.DiscNumber,
+				Explicit:          dt.Explicit,
+				IsLocal:           dt.IsLocal,
+				AddedBy:           dt.AddedBy,
+				AddedAtToPlaylist: dt.AddedAt,
+				Availability:      dt.Availability,
+				Created:           created,
+			})
+		}
+
+		err = b.repo.AddPlaylist(bp, p, &t)
+		if err != nil {
+			return
+		}
+	}
+
+	for _, v := range d.Youtube.Playlists {
+		p := &YoutubePlaylist{
+			YoutubeId:     v.Id,
+			Name:          v.Name,
+			Description:   v.Description,
+			ChannelId:     v.ChannelId,
+			ChannelTitle:  v.ChannelTitle,
+			PrivacyStatus: v.PrivacyStatus,
+			CoverUrl:      v.CoverUrl,
+			CoverBlob:     b.importCover(v.CoverBlob),
+			Position:      v.Position,
+			Created:       created,
+		}
+
+		t := make([]YoutubeTrack, 0, len(v.Tracks))
+		for _, dt := range v.Tracks {
+			yt := YoutubeTrack{
+				YoutubeId:         dt.Id,
+				Name:              dt.Name,
+				ChannelId:         dt.ChannelId,
+				ChannelTitle:      dt.ChannelTitle,
+				Description:       dt.Description,
+				Tags:              dt.Tags,
+				CategoryId:        dt.CategoryId,
+				DurationSeconds:   dt.DurationSeconds,
+				PublishedAt:       dt.PublishedAt,
+				PrivacyStatus:     dt.PrivacyStatus,
+				Thumbnails:        dt.Thumbnails,
+				AddedAtToPlaylist: dt.AddedAt,
+				Availability:      dt.Availability,
+				Created:           created,
+			}
+
+			if dt.Music != nil {
+				yt.MusicTitle = dt.Music.Title
+				yt.MusicArtist = dt.Music.Artist
+				yt.MusicAlbum = dt.Music.Album
+				yt.MusicLabel = dt.Music.Label
+				yt.MusicReleaseDate = dt.Music.ReleaseDate
+			}
+
+			t = append(t, yt)
+		}
+
+		err = b.repo.AddYoutubePlaylist(bp, p, &t)
+		if err != nil {
+			return
+		}
+	}
+
+	if len(d.Spotify.Albums) > 0 {
+		a := make([]Album, 0, len(d.Spotify.Albums))
+		for _, v := range d.Spotify.Albums {
+			a = append(a, Album{SpotifyId: v.Id, Name: v.Name, Artist: v.Artist, ReleaseDate: v.ReleaseDate, AddedAt: v.AddedAt, Created: created})
+		}
+
+		err = b.repo.AddAlbums(bp, &a)
+		if err != nil {
+			return
+		}
+	}
+
+	if len(d.Spotify.Artists) > 0 {
+		a := make([]Artist, 0, len(d.Spotify.Artists))
+		for _, v := range d.Spotify.Artists {
+			a = append(a, Artist{SpotifyId: v.Id, Name: v.Name, Genres: v.Genres, Created: created})
+		}
+
+		err = b.repo.AddArtists(bp, &a)
+		if err != nil {
+			return
+		}
+	}
+
+	if len(d.Spotify.Shows) > 0 {
+		s := make([]Show, 0, len(d.Spotify.Shows))
+		for _, v := range d.Spotify.Shows {
+			s = append(s, Show{SpotifyId: v.Id, Name: v.Name, Publisher: v.Publisher, AddedAt: v.AddedAt, Created: created})
+		}
+
+		err = b.repo.AddShows(bp, &s)
+	}
+
+	return
+}
+
+// Covers are not part of the document, so only keys of covers which
+// are already in the blob store are kept.
+func (b *backuper) importCover(key string) string {
+	if key == "" || b.covers == nil {
+		return ""
+	}
+
+	_, err := b.covers.Get(key)
+	if err != nil {
+		log.Debug().Err(err).Msgf("backuper_import: cover %s is not stored, skipping it", key)
+		return ""
+	}
+
+	return key
+}
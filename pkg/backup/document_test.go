@@ -0,0 +1,204 @@
+package backup
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hoffs/crispy-musicular/pkg/auth"
+	"github.com/hoffs/crispy-musicular/pkg/blob"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDocument(t *testing.T) {
+	bp := &Backup{Id: 1, UserId: "user", Success: true, Started: time.Unix(0, 0).UTC(), Finished: time.Unix(60, 0).UTC(), Scope: ScopeAll}
+	p := []Playlist{{Id: 10, SpotifyId: "p1", Name: "First"}, {Id: 11, SpotifyId: "p2", Name: "Empty"}}
+	tr := []Track{{SpotifyId: "t1", Name: "One", PlaylistId: 10}, {SpotifyId: "t2", Name: "Two", PlaylistId: 10}}
+	yp := []YoutubePlaylist{{Id: 20, YoutubeId: "y1", Name: "Videos"}}
+	yt := []YoutubeTrack{{YoutubeId: "v1", Name: "Video", MusicTitle: "Song", PlaylistId: 20}, {YoutubeId: "v2", PlaylistId: 20}}
+	l := &Library{Albums: []Album{{SpotifyId: "a1", Name: "Album"}}}
+
+	d := NewDocument(bp, &p, &tr, &yp, &yt, l)
+	require.Equal(t, DocumentVersion, d.Version)
+	require.Equal(t, "user", d.Backup.UserId)
+	require.Equal(t, ScopeAll, d.Backup.Scope)
+	require.Len(t, d.Spotify.Playlists, 2)
+	require.Equal(t, []string{"t1", "t2"}, []string{d.Spotify.Playlists[0].Tracks[0].Id, d.Spotify.Playlists[0].Tracks[1].Id})
+	require.NotNil(t, d.Spotify.Playlists[1].Tracks)
+	require.Empty(t, d.Spotify.Playlists[1].Tracks)
+	require.Len(t, d.Youtube.Playlists[0].Tracks, 2)
+	require.Equal(t, "Song", d.Youtube.Playlists[0].Tracks[0].Music.Title)
+	require.Nil(t, d.Youtube.Playlists[0].Tracks[1].Music)
+	require.Len(t, d.Spotify.Albums, 1)
+	require.NotNil(t, d.Spotify.Shows)
+
+	data, err := json.Marshal(d)
+	require.NoError(t, err)
+
+	parsed, err := ParseDocument(data)
+	require.NoError(t, err)
+	require.Equal(t, d, parsed)
+}
+
+func TestParseDocumentVersion(t *testing.T) {
+	_, err := ParseDocument([]byte(`{"version":3,"backup":{"userId":"user","started":"2021-01-01T00:00:00Z"}}`))
+	require.ErrorIs(t, err, ErrUnsupportedDocument)
+
+	// version 1 documents have all platforms
+	d, err := ParseDocument([]byte(`{"version":1,"backup":{"userId":"user","started":"2021-01-01T00:00:00Z"}}`))
+	require.NoError(t, err)
+	require.Equal(t, ScopeAll, d.Backup.Scope)
+
+	_, err = ParseDocument([]byte(`{"version":2,"backup":{"userId":"user","started":"2021-01-01T00:00:00Z","scope":"other"}}`))
+	require.ErrorIs(t, err, ErrUnknownScope)
+
+	// files written before the document was versioned
+	_, err = ParseDocument([]byte(`{"Backup":{"UserId":"user"},"Playlists":[]}`))
+	require.ErrorIs(t, err, ErrUnsupportedDocument)
+
+	_, err = ParseDocument([]byte(`{"version":1,"backup":{"userId":""}}`))
+	require.Error(t, err)
+}
+
+type schemaNode struct {
+	Ref         string                 `json:"$ref"`
+	Properties  map[string]*schemaNode `json:"properties"`
+	Items       *schemaNode            `json:"items"`
+	Definitions map[string]*schemaNode `json:"definitions"`
+}
+
+// Schema has to describe every field of the document and nothing else
+func TestDocumentSchema(t *testing.T) {
+	var root schemaNode
+	require.NoError(t, json.Unmarshal(DocumentJsonSchema, &root))
+
+	var check func(path string, typ reflect.Type, n *schemaNode)
+	check = func(path string, typ reflect.Type, n *schemaNode) {
+		if n.Ref != "" {
+			n = root.Definitions[strings.TrimPrefix(n.Ref, "#/definitions/")]
+			require.NotNil(t, n, path)
+		}
+
+		switch typ.Kind() {
+		case reflect.Ptr:
+			check(path, typ.Elem(), n)
+		case reflect.Slice:
+			require.NotNil(t, n.Items, path)
+			check(path+"[]", typ.Elem(), n.Items)
+		case reflect.Struct:
+			if typ == reflect.TypeOf(time.Time{}) {
+				return
+			}
+
+			fields := make(map[string]bool)
+			for i := 0; i < typ.NumField(); i++ {
+				name := strings.Split(typ.Field(i).Tag.Get("json"), ",")[0]
+				fields[name] = true
+
+				prop, ok := n.Properties[name]
+				require.True(t, ok, "%s.%s is missing in schema", path, name)
+				check(path+"."+name, typ.Field(i).Type, prop)
+			}
+
+			for name := range n.Properties {
+				require.True(t, fields[name], "%s.%s is not in document", path, name)
+			}
+		}
+	}
+
+	check("document", reflect.TypeOf(Document{}), &root)
+}
+
+type importRepository struct {
+	Repository
+	backups   []Backup
+	playlists map[string]int
+	covers    []string
+	updated   *Backup
+}
+
+func (r *importRepository) GetBackups(userId string) (*[]Backup, error) {
+	return &r.backups, nil
+}
+
+func (r *importRepository) AddBackup(b *Backup) error {
+	b.Id = int64(len(r.backups) + 1)
+	r.backups = append(r.backups, *b)
+	return nil
+}
+
+func (r *importRepository) AddPlaylist(b *Backup, p *Playlist, t *[]Track) error {
+	r.playlists["spotify:"+p.SpotifyId] = len(*t)
+	r.covers = append(r.covers, p.CoverBlob)
+	return nil
+}
+
+func (r *importRepository) AddYoutubePlaylist(b *Backup, p *YoutubePlaylist, t *[]YoutubeTrack) error {
+	r.playlists["youtube:"+p.YoutubeId] = len(*t)
+	r.covers = append(r.covers, p.CoverBlob)
+	return nil
+}
+
+func (r *importRepository) AddShows(b *Backup, s *[]Show) error {
+	return nil
+}
+
+func (r *importRepository) UpdateBackup(b *Backup) error {
+	r.updated = b
+	return nil
+}
+
+type importAuth struct {
+	auth.Service
+	user string
+}
+
+func (a *importAuth) GetState() (auth.State, error) {
+	return auth.State{User: a.user}, nil
+}
+
+func TestImport(t *testing.T) {
+	covers, err := blob.NewFileStore(t.TempDir())
+	require.NoError(t, err)
+	stored, err := covers.Put([]byte("cover"))
+	require.NoError(t, err)
+	missing := "0000000000000000000000000000000000000000000000000000000000000000"
+
+	repo := &importRepository{playlists: make(map[string]int)}
+	b := &backuper{repo: repo, auth: &importAuth{user: "user"}, covers: covers}
+
+	started := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	d := &Document{
+		Version: DocumentVersion,
+		Backup:  DocumentBackup{"user", true, started, started.Add(time.Minute), ScopeAll},
+		Spotify: DocumentSpotify{
+			Playlists: []DocumentPlaylist{
+				{Id: "p1", CoverBlob: stored, Tracks: []DocumentTrack{{Id: "t1"}, {Id: "t2"}}},
+				{Id: "p2", CoverBlob: missing},
+			},
+			Shows: []DocumentShow{{Id: "s1"}},
+		},
+		Youtube: DocumentYoutube{Playlists: []DocumentYoutubePlaylist{{Id: "y1", CoverBlob: "../../etc/passwd", Tracks: []DocumentYoutubeTrack{{Id: "v1"}}}}},
+	}
+
+	bp, err := b.Import(d)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), bp.Id)
+	require.Equal(t, ScopeAll, bp.Scope)
+	require.Equal(t, map[string]int{"spotify:p1": 2, "spotify:p2": 0, "youtube:y1": 1}, repo.playlists)
+	// covers which are not stored locally are cleared
+	require.Equal(t, []string{stored, "", ""}, repo.covers)
+	require.True(t, repo.updated.Success)
+	require.Equal(t, started.Add(time.Minute), repo.updated.Finished)
+
+	_, err = b.Import(d)
+	require.ErrorIs(t, err, ErrBackupExists)
+
+	b.auth = &importAuth{user: "other"}
+	d.Backup.Started = started.Add(time.Hour)
+	_, err = b.Import(d)
+	require.ErrorIs(t, err, ErrForeignBackup)
+	require.Len(t, repo.backups, 1)
+}
@@ -0,0 +1,91 @@
+package backup
+
+import (
+	"strings"
+	"time"
+
+	"github.com/zmb3/spotify"
+)
+
+type Album struct {
+	Id          int64
+	SpotifyId   string
+	Name        string
+	Artist      string
+	ReleaseDate string
+	AddedAt     string
+	Created     time.Time
+}
+
+type Artist struct {
+	Id        int64
+	SpotifyId string
+	Name      string
+	Genres    string
+	Created   time.Time
+}
+
+type Show struct {
+	Id        int64
+	SpotifyId string
+	Name      string
+	Publisher string
+	AddedAt   string
+	Created   time.Time
+}
+
+// Saved Spotify collections of a backup which are not playlists
+type Library struct {
+	Albums  []Album
+	Artists []Artist
+	Shows   []Show
+}
+
+func (l *Library) IsEmpty() bool {
+	return l == nil || len(l.Albums) == 0 && len(l.Artists) == 0 && len(l.Shows) == 0
+}
+
+func (b *backuper) addSpotifyAlbums(bp *Backup, sas []spotify.SavedAlbum) (err error) {
+	a := make([]Album, 0, len(sas))
+	for _, sa := range sas {
+		a = append(a, Album{
+			SpotifyId:   string(sa.ID),
+			Name:        sa.Name,
+			Artist:      formatTrackArtists(sa.Artists),
+			ReleaseDate: sa.ReleaseDate,
+			AddedAt:     sa.AddedAt,
+			Created:     time.Now(),
+		})
+	}
+
+	return b.repo.AddAlbums(bp, &a)
+}
+
+func (b *backuper) addSpotifyArtists(bp *Backup, sas []spotify.FullArtist) (err error) {
+	a := make([]Artist, 0, len(sas))
+	for _, sa := range sas {
+		a = append(a, Artist{
+			SpotifyId: string(sa.ID),
+			Name:      sa.Name,
+			Genres:    strings.Join(sa.Genres, ", "),
+			Created:   time.Now(),
+		})
+	}
+
+	return b.repo.AddArtists(bp, &a)
+}
+
+func (b *backuper) addSpotifyShows(bp *Backup, sss []spotify.SavedShow) (err error) {
+	s := make([]Show, 0, len(sss))
+	for _, ss := range sss {
+		s = append(s, Show{
+			SpotifyId: string(ss.ID),
+			Name:      ss.Name,
+			Publisher: ss.Publisher,
+			AddedAt:   ss.AddedAt,
+			Created:   time.Now(),
+		})
+	}
+
+	return b.repo.AddShows(bp, &s)
+}
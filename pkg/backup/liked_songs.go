@@ -0,0 +1,61 @@
+package backup
+
+import (
+	"github.com/rs/zerolog/log"
+	"github.com/zmb3/spotify"
+)
+
+// Liked Songs are not a real playlist in Spotify, so they are stored as
+// a playlist with this id, similar to how Youtube uses LL for liked videos.
+const (
+	LikedSongsId   = "liked"
+	LikedSongsName = "Liked Songs"
+)
+
+func newLikedSongsPlaylist(usr *spotify.PrivateUser) *spotify.SimplePlaylist {
+	return &spotify.SimplePlaylist{
+		ID:    LikedSongsId,
+		Name:  LikedSongsName,
+		Owner: usr.User,
+	}
+}
+
+func isLikedSongs(p *spotify.SimplePlaylist) bool {
+	return p.ID == LikedSongsId
+}
+
+func (b *backuper) saveLikedSongs(st *backupState, p *Playlist) (err error) {
+	market := spotify.MarketFromToken
+	limit := 50 // Max saved tracks per page
+	tracks, err := st.spotify.CurrentUsersTracksOpt(&spotify.Options{Country: &market, Limit: &limit})
+	if err != nil {
+		log.Error().Err(err).Msg("backuper_worker: failed to get initial saved tracks")
+		return
+	}
+
+	var all []spotify.PlaylistTrack
+	for {
+		log.Debug().Msgf("backuper_worker: got saved track page, offset %d, limit %d, total %d", tracks.Offset, tracks.Limit, tracks.Total)
+
+		for _, t := range tracks.Tracks {
+			all = append(all, spotify.PlaylistTrack{AddedAt: t.AddedAt, Track: t.FullTrack})
+		}
+
+		err = st.spotify.NextPage(tracks)
+		if err == spotify.ErrNoMorePages {
+			break
+		}
+
+		if err != nil {
+			return
+		}
+	}
+
+	err = b.addSpotifyPlaylist(st.bp, p, all)
+	if err != nil {
+		log.Error().Err(err).Msg("backuper_worker: could not create playlist entry for saved tracks")
+		return
+	}
+
+	return nil
+}
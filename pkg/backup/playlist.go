@@ -3,8 +3,17 @@ package backup
 import "time"
 
 type Playlist struct {
-	Id        int64
-	SpotifyId string
-	Name      string
-	Created   time.Time
+	Id            int64
+	SpotifyId     string
+	SnapshotId    string
+	Name          string
+	Description   string
+	OwnerId       string
+	OwnerName     string
+	Collaborative bool
+	Public        bool
+	CoverUrl      string
+	CoverBlob     string // Key of cover image in blob store, empty if it wasn't downloaded
+	Position      int    // Position of playlist in user's library
+	Created       time.Time
 }
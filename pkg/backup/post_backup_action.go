@@ -3,4 +3,11 @@ package backup
 type PostBackupAction interface {
 	Do(bp *Backup, p *[]Playlist, t *[]Track) error
 	DoYoutube(bp *Backup, p *[]YoutubePlaylist, t *[]YoutubeTrack) error
+	DoLibrary(bp *Backup, l *Library) error
+}
+
+// Optional interface for actions which store the whole backup as a single
+// versioned document instead of separate Spotify, Youtube and library parts.
+type DocumentBackupAction interface {
+	DoDocument(d *Document) error
 }
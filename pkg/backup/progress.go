@@ -0,0 +1,217 @@
+package backup
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+type EventType string
+
+const (
+	EventBackupStarted     EventType = "backup_started"
+	EventBackupFinished    EventType = "backup_finished"
+	EventBackupQueued      EventType = "backup_queued"
+	EventBackupDequeued    EventType = "backup_dequeued"
+	EventBackupCancelling  EventType = "backup_cancelling"
+	EventPlaylistQueued    EventType = "playlist_queued"
+	EventPlaylistCompleted EventType = "playlist_completed"
+	EventTracksSaved       EventType = "tracks_saved"
+	EventError             EventType = "error"
+	EventActionCompleted   EventType = "action_completed"
+)
+
+// Single step of a running backup. Only fields relevant to the type are set,
+// Progress is the state of the backup after the event was applied.
+type Event struct {
+	Type       EventType `json:"type"`
+	Time       time.Time `json:"time"`
+	BackupId   int64     `json:"backupId,omitempty"`
+	Platform   string    `json:"platform,omitempty"`
+	PlaylistId string    `json:"playlistId,omitempty"`
+	Name       string    `json:"name,omitempty"`
+	Tracks     int       `json:"tracks,omitempty"`
+	Action     string    `json:"action,omitempty"`
+	Success    bool      `json:"success"`
+	Error      string    `json:"error,omitempty"`
+	Progress   Progress  `json:"progress"`
+}
+
+type Progress struct {
+	Running            bool      `json:"running"`
+	Cancelling         bool      `json:"cancelling"`
+	Queued             bool      `json:"queued"`
+	BackupId           int64     `json:"backupId,omitempty"`
+	Started            time.Time `json:"started"`
+	Finished           time.Time `json:"finished"`
+	Success            bool      `json:"success"`
+	PlaylistsQueued    int       `json:"playlistsQueued"`
+	PlaylistsCompleted int       `json:"playlistsCompleted"`
+	PlaylistsFailed    int       `json:"playlistsFailed"`
+	TracksSaved        int       `json:"tracksSaved"`
+	Errors             int       `json:"errors"`
+	ActionsCompleted   int       `json:"actionsCompleted"`
+	ActionsFailed      int       `json:"actionsFailed"`
+}
+
+// Amount of events buffered for every subscriber. Subscribers that fall behind
+// lose their oldest events, so that backup is never blocked by them, while the
+// latest event, e.g. finished backup, with up to date Progress is still delivered.
+const eventBufferSize = 256
+
+// Fans out backup events to subscribers and keeps track of the progress
+// of the latest backup, so that new subscribers don't have to replay events.
+type EventBus struct {
+	mu          sync.Mutex
+	subscribers map[chan Event]struct{}
+	progress    Progress
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{subscribers: make(map[chan Event]struct{})}
+}
+
+// Returned function has to be called once subscriber is done,
+// it closes the channel.
+func (e *EventBus) Subscribe() (events <-chan Event, unsubscribe func()) {
+	ch := make(chan Event, eventBufferSize)
+
+	e.mu.Lock()
+	e.subscribers[ch] = struct{}{}
+	e.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe = func() {
+		once.Do(func() {
+			e.mu.Lock()
+			delete(e.subscribers, ch)
+			e.mu.Unlock()
+			close(ch)
+		})
+	}
+
+	return ch, unsubscribe
+}
+
+func (e *EventBus) Publish(ev Event) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.apply(&ev)
+	ev.Progress = e.progress
+
+	for ch := range e.subscribers {
+		select {
+		case ch <- ev:
+			continue
+		default:
+		}
+
+		select {
+		case dropped := <-ch:
+			log.Debug().Msgf("backuper_events: subscriber is full, dropping '%s' event", dropped.Type)
+		default:
+		}
+
+		// only publisher sends while holding the lock, so there is room now
+		select {
+		case ch <- ev:
+		default:
+			log.Debug().Msgf("backuper_events: subscriber is full, dropping '%s' event", ev.Type)
+		}
+	}
+}
+
+func (e *EventBus) Progress() Progress {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.progress
+}
+
+// must be called with lock held
+func (e *EventBus) apply(ev *Event) {
+	if ev.Type == EventBackupStarted {
+		e.progress = Progress{Running: true, Started: ev.Time}
+	}
+
+	if ev.BackupId != 0 {
+		e.progress.BackupId = ev.BackupId
+	}
+
+	switch ev.Type {
+	case EventBackupQueued:
+		e.progress.Queued = true
+	case EventBackupDequeued:
+		e.progress.Queued = false
+	case EventBackupCancelling:
+		e.progress.Cancelling = true
+	case EventBackupFinished:
+		e.progress.Running = false
+		e.progress.Cancelling = false
+		e.progress.Finished = ev.Time
+		e.progress.Success = ev.Success
+	case EventPlaylistQueued:
+		e.progress.PlaylistsQueued++
+	case EventPlaylistCompleted:
+		e.progress.PlaylistsCompleted++
+	case EventTracksSaved:
+		e.progress.TracksSaved += ev.Tracks
+	case EventError:
+		e.progress.Errors++
+		if ev.PlaylistId != "" {
+			e.progress.PlaylistsFailed++
+		}
+	case EventActionCompleted:
+		if ev.Success {
+			e.progress.ActionsCompleted++
+		} else {
+			e.progress.ActionsFailed++
+		}
+	}
+}
+
+func (b *backuper) Events() *EventBus {
+	return b.events
+}
+
+func (b *backuper) publish(ev Event) {
+	if b.events == nil {
+		return
+	}
+
+	ev.Time = time.Now()
+	b.events.Publish(ev)
+}
+
+func (b *backuper) publishQueued(st *backupState, platform string, playlistId string, name string) {
+	b.publish(Event{Type: EventPlaylistQueued, BackupId: st.bp.Id, Platform: platform, PlaylistId: playlistId, Name: name})
+}
+
+func (b *backuper) publishError(ev Event, err error) {
+	ev.Type = EventError
+	if err != nil {
+		ev.Error = err.Error()
+	}
+
+	b.publish(ev)
+}
+
+// Actions don't have names, so it is derived from the type,
+// e.g. jsonBackupService => json
+func actionName(act PostBackupAction) string {
+	t := reflect.TypeOf(act)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	name := strings.TrimSuffix(t.Name(), "BackupService")
+	if name == "" {
+		return t.String()
+	}
+
+	return name
+}
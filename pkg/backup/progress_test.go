@@ -0,0 +1,92 @@
+package backup
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type jsonBackupService struct{}
+
+func (jsonBackupService) Do(bp *Backup, p *[]Playlist, t *[]Track) error { return nil }
+func (jsonBackupService) DoYoutube(bp *Backup, p *[]YoutubePlaylist, t *[]YoutubeTrack) error {
+	return nil
+}
+func (jsonBackupService) DoLibrary(bp *Backup, l *Library) error { return nil }
+
+func TestEventBusProgress(t *testing.T) {
+	b := &backuper{events: NewEventBus()}
+	events, unsubscribe := b.events.Subscribe()
+	defer unsubscribe()
+
+	b.publish(Event{Type: EventBackupStarted})
+	b.publish(Event{Type: EventPlaylistQueued, BackupId: 3, PlaylistId: "a"})
+	b.publish(Event{Type: EventPlaylistQueued, BackupId: 3, PlaylistId: "b"})
+	b.publish(Event{Type: EventTracksSaved, BackupId: 3, PlaylistId: "a", Tracks: 12})
+	b.publish(Event{Type: EventPlaylistCompleted, BackupId: 3, PlaylistId: "a"})
+	b.publishError(Event{BackupId: 3, PlaylistId: "b"}, errors.New("failed"))
+	b.publish(Event{Type: EventActionCompleted, BackupId: 3, Action: actionName(&jsonBackupService{}), Success: true})
+
+	p := b.events.Progress()
+	require.True(t, p.Running)
+	require.Equal(t, int64(3), p.BackupId)
+	require.Equal(t, 2, p.PlaylistsQueued)
+	require.Equal(t, 1, p.PlaylistsCompleted)
+	require.Equal(t, 1, p.PlaylistsFailed)
+	require.Equal(t, 12, p.TracksSaved)
+	require.Equal(t, 1, p.Errors)
+	require.Equal(t, 1, p.ActionsCompleted)
+
+	b.publish(Event{Type: EventBackupFinished, BackupId: 3, Success: true})
+	require.False(t, b.events.Progress().Running)
+
+	var last Event
+	for i := 0; i < 8; i++ {
+		last = <-events
+	}
+
+	require.Equal(t, EventBackupFinished, last.Type)
+	require.False(t, last.Time.IsZero())
+	require.Equal(t, 12, last.Progress.TracksSaved)
+
+	// new backup resets progress
+	b.publish(Event{Type: EventBackupStarted})
+	require.Equal(t, Progress{Running: true, Started: b.events.Progress().Started}, b.events.Progress())
+}
+
+func TestEventBusSlowSubscriber(t *testing.T) {
+	bus := NewEventBus()
+	events, unsubscribe := bus.Subscribe()
+
+	// publishing never blocks, oldest events over the buffer are dropped
+	for i := 0; i < eventBufferSize+10; i++ {
+		bus.Publish(Event{Type: EventPlaylistQueued})
+	}
+	bus.Publish(Event{Type: EventBackupFinished, Success: true})
+
+	require.Len(t, events, eventBufferSize)
+	require.Equal(t, eventBufferSize+10, bus.Progress().PlaylistsQueued)
+
+	unsubscribe()
+	unsubscribe()
+	bus.Publish(Event{Type: EventPlaylistQueued})
+
+	var received []Event
+	for ev := range events {
+		received = append(received, ev)
+	}
+	require.Len(t, received, eventBufferSize)
+
+	// newest events are kept, so finished backup is always delivered
+	last := received[len(received)-1]
+	require.Equal(t, EventBackupFinished, last.Type)
+	require.Equal(t, eventBufferSize+10, last.Progress.PlaylistsQueued)
+	// 11 oldest of the 267 published events were dropped
+	require.Equal(t, 12, received[0].Progress.PlaylistsQueued)
+}
+
+func TestActionName(t *testing.T) {
+	require.Equal(t, "json", actionName(&jsonBackupService{}))
+	require.Equal(t, "json", actionName(jsonBackupService{}))
+}
@@ -9,25 +9,45 @@ import (
 
 type Repository interface {
 	AddBackup(b *Backup) error
-	AddPlaylist(b *Backup, p *Playlist) error
-	AddTrack(b *Backup, p *Playlist, t *Track) error
+	AddPlaylist(b *Backup, p *Playlist, t *[]Track) error
+	AddPlaylistFromSnapshot(b *Backup, p *Playlist) (bool, error)
+	SetPlaylistCover(p *Playlist) error
 
-	AddYoutubePlaylist(b *Backup, p *YoutubePlaylist) error
-	AddYoutubeTrack(b *Backup, p *YoutubePlaylist, t *YoutubeTrack) error
+	AddYoutubePlaylist(b *Backup, p *YoutubePlaylist, t *[]YoutubeTrack) error
+
+	AddAlbums(b *Backup, a *[]Album) error
+	AddArtists(b *Backup, a *[]Artist) error
+	AddShows(b *Backup, s *[]Show) error
 
 	UpdateBackup(b *Backup) error
 
-	GetLastBackup(userId string) (*Backup, error)
-	GetBackupPlaylistCount(b *Backup) (int64, error)
-	GetBackupTrackCount(b *Backup) (int64, error)
+	GetBackup(id int64) (*Backup, error)
+	GetLastBackup(userId string, platform Scope) (*Backup, error)
+	GetBackupPlaylistCount(b *Backup, platform Scope) (int64, error)
+	GetBackupTrackCount(b *Backup, platform Scope) (int64, error)
 	GetBackupCount(userId string) (count int64, err error)
-	GetBackupData(b *Backup) (p *[]Playlist, t *[]Track, yp *[]YoutubePlaylist, yt *[]YoutubeTrack, err error)
+	GetBackupData(b *Backup) (p *[]Playlist, t *[]Track, yp *[]YoutubePlaylist, yt *[]YoutubeTrack, l *Library, err error)
+	GetLostTracks(b *Backup) (t *[]LostTrack, yt *[]LostYoutubeTrack, err error)
+	GetBackups(userId string) (b *[]Backup, err error)
+	GetBackupsPage(userId string, offset int, limit int) (b *[]Backup, err error)
+	GetPlaylistSummaries(b *Backup, offset int, limit int) (p *[]PlaylistSummary, err error)
+	GetPlaylistSummary(b *Backup, platform string, id string) (p *PlaylistSummary, err error)
+	GetPlaylistTracks(b *Backup, spotifyId string, offset int, limit int) (t *[]Track, err error)
+	GetYoutubePlaylistTracks(b *Backup, youtubeId string, offset int, limit int) (t *[]YoutubeTrack, err error)
+
+	DeleteBackups(b *[]Backup) (orphanedCovers []string, err error)
+	Vacuum() error
+
+	GetScheduledRun(name string) (*ScheduledRun, error)
+	GetScheduledRuns() (*[]ScheduledRun, error)
+	SetScheduledRun(s *ScheduledRun) error
 }
 
-func (b *backuper) createBackup(userId string) (bp *Backup, err error) {
+func (b *backuper) createBackup(userId string, scope Scope) (bp *Backup, err error) {
 	bp = &Backup{
 		UserId:  userId,
 		Started: time.Now(),
+		Scope:   scope,
 	}
 
 	err = b.repo.AddBackup(bp)
@@ -43,53 +63,115 @@ func (b *backuper) endBackup(bp *Backup, isOk bool) (err error) {
 	return
 }
 
-func (b *backuper) addSpotifyPlaylist(bp *Backup, sp *spotify.SimplePlaylist) (p *Playlist, err error) {
-	p = &Playlist{
-		SpotifyId: string(sp.ID),
-		Name:      sp.Name,
-		Created:   time.Now(),
+func (b *backuper) addSpotifyPlaylist(bp *Backup, p *Playlist, sts []spotify.PlaylistTrack) (err error) {
+	t := make([]Track, 0, len(sts))
+	for id := range sts {
+		t = append(t, *newSpotifyTrack(&sts[id]))
+	}
+
+	err = b.repo.AddPlaylist(bp, p, &t)
+	if err == nil {
+		b.publish(Event{Type: EventTracksSaved, BackupId: bp.Id, Platform: PlatformSpotify, PlaylistId: p.SpotifyId, Name: p.Name, Tracks: len(t)})
 	}
 
-	err = b.repo.AddPlaylist(bp, p)
 	return
 }
 
-func (b *backuper) addSpotifyTrack(bp *Backup, p *Playlist, st *spotify.PlaylistTrack) (err error) {
-	t := &Track{
-		SpotifyId:         string(st.Track.ID),
+func newSpotifyPlaylist(sp *spotify.SimplePlaylist, position int) *Playlist {
+	return &Playlist{
+		SpotifyId:     string(sp.ID),
+		SnapshotId:    sp.SnapshotID,
+		Name:          sp.Name,
+		OwnerId:       sp.Owner.ID,
+		OwnerName:     sp.Owner.DisplayName,
+		Collaborative: sp.Collaborative,
+		Public:        sp.IsPublic,
+		CoverUrl:      spotifyCoverUrl(sp.Images),
+		Position:      position,
+		Created:       time.Now(),
+	}
+}
+
+func newSpotifyTrack(st *spotify.PlaylistTrack) *Track {
+	id := st.Track.ID
+	uri := string(st.Track.URI)
+	// when tracks are requested with a market, unavailable tracks might be replaced
+	// with an available version, but the track actually in the playlist is the original
+	if st.Track.LinkedFrom != nil && st.Track.LinkedFrom.ID != "" {
+		id = st.Track.LinkedFrom.ID
+		uri = "spotify:track:" + string(id)
+	}
+
+	return &Track{
+		SpotifyId:         string(id),
+		Uri:               uri,
+		Isrc:              st.Track.ExternalIDs["isrc"],
 		Name:              st.Track.Name,
 		Artist:            formatTrackArtists(st.Track.Artists),
+		ArtistIds:         trackArtistIds(st.Track.Artists),
 		Album:             st.Track.Album.Name,
+		AlbumArtist:       formatTrackArtists(st.Track.Album.Artists),
+		AlbumReleaseDate:  st.Track.Album.ReleaseDate,
+		DurationMs:        st.Track.Duration,
+		TrackNumber:       st.Track.TrackNumber,
+		DiscNumber:        st.Track.DiscNumber,
+		Explicit:          st.Track.Explicit,
+		IsLocal:           st.IsLocal,
+		AddedBy:           st.AddedBy.ID,
 		AddedAtToPlaylist: st.AddedAt,
+		Availability:      spotifyAvailability(st),
 		Created:           time.Now(),
 	}
-
-	err = b.repo.AddTrack(bp, p, t)
-	return
 }
 
-func (b *backuper) addYoutubePlaylist(bp *Backup, sp *youtube.Playlist) (p *YoutubePlaylist, err error) {
+func (b *backuper) addYoutubePlaylist(bp *Backup, sp *youtube.Playlist, position int, sts []*youtube.PlaylistItem, videos map[string]*youtube.Video) (p *YoutubePlaylist, err error) {
 	p = &YoutubePlaylist{
-		YoutubeId: sp.Id,
-		Name:      sp.Snippet.Title,
-		Created:   time.Now(),
+		YoutubeId:    sp.Id,
+		Name:         sp.Snippet.Title,
+		Description:  sp.Snippet.Description,
+		ChannelId:    sp.Snippet.ChannelId,
+		ChannelTitle: sp.Snippet.ChannelTitle,
+		CoverUrl:     youtubeCoverUrl(sp.Snippet.Thumbnails),
+		Position:     position,
+		Created:      time.Now(),
+	}
+
+	if sp.Status != nil {
+		p.PrivacyStatus = sp.Status.PrivacyStatus
+	}
+
+	p.CoverBlob = b.storeCover(p.CoverUrl)
+
+	t := make([]YoutubeTrack, 0, len(sts))
+	for _, st := range sts {
+		t = append(t, *newYoutubeTrack(st, videos[st.ContentDetails.VideoId]))
+	}
+
+	err = b.repo.AddYoutubePlaylist(bp, p, &t)
+	if err == nil {
+		b.publish(Event{Type: EventTracksSaved, BackupId: bp.Id, Platform: PlatformYoutube, PlaylistId: p.YoutubeId, Name: p.Name, Tracks: len(t)})
 	}
 
-	err = b.repo.AddYoutubePlaylist(bp, p)
 	return
 }
 
-func (b *backuper) addYoutubeTrack(bp *Backup, p *YoutubePlaylist, st *youtube.PlaylistItem) (err error) {
+// Video is nil if it wasn't returned by Youtube
+func newYoutubeTrack(st *youtube.PlaylistItem, v *youtube.Video) *YoutubeTrack {
 	t := &YoutubeTrack{
 		YoutubeId:         st.ContentDetails.VideoId,
 		Name:              st.Snippet.Title,
+		ChannelId:         st.Snippet.VideoOwnerChannelId,
 		ChannelTitle:      st.Snippet.VideoOwnerChannelTitle,
 		AddedAtToPlaylist: st.Snippet.PublishedAt,
+		Availability:      youtubeAvailability(st, v),
 		Created:           time.Now(),
 	}
 
-	err = b.repo.AddYoutubeTrack(bp, p, t)
-	return
+	if v != nil {
+		enrichYoutubeTrack(t, v)
+	}
+
+	return t
 }
 
 func formatTrackArtists(artists []spotify.SimpleArtist) string {
@@ -105,6 +187,17 @@ func formatTrackArtists(artists []spotify.SimpleArtist) string {
 	return artist
 }
 
+// Local files don't have artist ids
+func trackArtistIds(artists []spotify.SimpleArtist) (ids []string) {
+	for _, v := range artists {
+		if v.ID != "" {
+			ids = append(ids, string(v.ID))
+		}
+	}
+
+	return
+}
+
 type BackupStats struct {
 	StartedAt     time.Time
 	FinishedAt    time.Time
@@ -114,9 +207,11 @@ type BackupStats struct {
 	TotalBackups  int64
 }
 
+// Status is of the newest backup, while counts are taken from
+// the newest backup of each platform, which may be different ones.
 func (b *backuper) GetBackupStats(userId string) (stats *BackupStats, err error) {
 	stats = &BackupStats{}
-	bp, err := b.repo.GetLastBackup(userId)
+	bp, err := b.repo.GetLastBackup(userId, ScopeAll)
 	if err != nil {
 		return
 	}
@@ -125,14 +220,24 @@ func (b *backuper) GetBackupStats(userId string) (stats *BackupStats, err error)
 	stats.FinishedAt = bp.Finished
 	stats.Successful = bp.Success
 
-	stats.PlaylistCount, err = b.repo.GetBackupPlaylistCount(bp)
-	if err != nil {
-		return
-	}
+	for _, platform := range []Scope{ScopeSpotify, ScopeYoutube} {
+		bp, err = b.repo.GetLastBackup(userId, platform)
+		if err != nil {
+			return
+		}
 
-	stats.TrackCount, err = b.repo.GetBackupTrackCount(bp)
-	if err != nil {
-		return
+		var count int64
+		count, err = b.repo.GetBackupPlaylistCount(bp, platform)
+		if err != nil {
+			return
+		}
+		stats.PlaylistCount += count
+
+		count, err = b.repo.GetBackupTrackCount(bp, platform)
+		if err != nil {
+			return
+		}
+		stats.TrackCount += count
 	}
 
 	stats.TotalBackups, err = b.repo.GetBackupCount(userId)
@@ -0,0 +1,63 @@
+package backup
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zmb3/spotify"
+)
+
+func TestNewSpotifyTrack(t *testing.T) {
+	st := spotify.PlaylistTrack{AddedAt: "now", AddedBy: spotify.User{ID: "user"}}
+	st.Track.ID = "id"
+	st.Track.URI = "spotify:track:id"
+	st.Track.Name = "Name"
+	st.Track.Artists = []spotify.SimpleArtist{{ID: "a1", Name: "Art 1"}, {ID: "a2", Name: "Art 2"}}
+	st.Track.Album.Name = "Album"
+	st.Track.Album.Artists = []spotify.SimpleArtist{{ID: "a1", Name: "Art 1"}}
+	st.Track.Album.ReleaseDate = "2020-01-01"
+	st.Track.ExternalIDs = map[string]string{"isrc": "ISRC"}
+	st.Track.Duration = 1000
+	st.Track.TrackNumber = 2
+	st.Track.DiscNumber = 1
+	st.Track.Explicit = true
+
+	tr := newSpotifyTrack(&st)
+	require.Equal(t, "id", tr.SpotifyId)
+	require.Equal(t, "spotify:track:id", tr.Uri)
+	require.Equal(t, "ISRC", tr.Isrc)
+	require.Equal(t, "Art 1, Art 2", tr.Artist)
+	require.Equal(t, []string{"a1", "a2"}, tr.ArtistIds)
+	require.Equal(t, "Art 1", tr.AlbumArtist)
+	require.Equal(t, "2020-01-01", tr.AlbumReleaseDate)
+	require.Equal(t, 1000, tr.DurationMs)
+	require.Equal(t, 2, tr.TrackNumber)
+	require.Equal(t, 1, tr.DiscNumber)
+	require.True(t, tr.Explicit)
+	require.False(t, tr.IsLocal)
+	require.Equal(t, "user", tr.AddedBy)
+}
+
+func TestNewSpotifyTrackLinked(t *testing.T) {
+	st := spotify.PlaylistTrack{}
+	st.Track.ID = "relinked"
+	st.Track.URI = "spotify:track:relinked"
+	st.Track.LinkedFrom = &spotify.LinkedFromInfo{ID: "original"}
+
+	tr := newSpotifyTrack(&st)
+	require.Equal(t, "original", tr.SpotifyId)
+	require.Equal(t, "spotify:track:original", tr.Uri)
+}
+
+func TestNewSpotifyTrackLocal(t *testing.T) {
+	st := spotify.PlaylistTrack{IsLocal: true}
+	st.Track.URI = "spotify:local:Art:Album:Name:100"
+	st.Track.Name = "Name"
+	st.Track.Artists = []spotify.SimpleArtist{{Name: "Art"}}
+
+	tr := newSpotifyTrack(&st)
+	require.True(t, tr.IsLocal)
+	require.Equal(t, "spotify:local:Art:Album:Name:100", tr.Uri)
+	require.Empty(t, tr.ArtistIds)
+	require.Equal(t, AvailabilityLocal, tr.Availability)
+}
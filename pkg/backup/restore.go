@@ -0,0 +1,292 @@
+package backup
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+	"github.com/zmb3/spotify"
+)
+
+var (
+	ErrRestoreDisabled  = errors.New("backup: restore is not enabled in config")
+	ErrPlaylistNotFound = errors.New("backup: playlist not found in backup")
+	ErrOverwriteLiked   = errors.New("backup: liked songs can only be restored to a new playlist")
+)
+
+// Spotify doesn't allow adding more than 100 tracks in a single request
+const restoreBatchSize = 100
+
+// Subset of Spotify client used by restore, so that it can be faked in tests
+type restoreClient interface {
+	CurrentUser() (*spotify.PrivateUser, error)
+	CreatePlaylistForUser(userID, playlistName, description string, public bool) (*spotify.FullPlaylist, error)
+	GetPlaylistTracksOpt(playlistID spotify.ID, opt *spotify.Options, fields string) (*spotify.PlaylistTrackPage, error)
+	ReplacePlaylistTracks(playlistID spotify.ID, trackIDs ...spotify.ID) error
+	AddTracksToPlaylist(playlistID spotify.ID, trackIDs ...spotify.ID) (snapshotID string, err error)
+	SetPlaylistImage(playlistID spotify.ID, img io.Reader) error
+}
+
+type RestoreResult struct {
+	// Spotify id of the playlist tracks were written to
+	PlaylistId string
+	Name       string
+	Overwrite  bool
+	Added      int
+	// Tracks that could not be added, local files or tracks that no longer exist
+	Failed []Track
+	// Overwritten playlist got its original tracks back after restore failed midway.
+	// If restore failed and this is false, playlist is left partially restored.
+	RolledBack bool
+}
+
+// Writes tracks of a playlist stored in backup to the authenticated Spotify account.
+// If overwrite is set tracks of the original playlist are replaced, otherwise a new
+// playlist is created with the stored public flag and cover.
+func (b *backuper) Restore(backupId int64, playlistId string, overwrite bool) (res *RestoreResult, err error) {
+	if !b.config.SpotifyRestoreEnabled {
+		return nil, ErrRestoreDisabled
+	}
+
+	// Liked Songs is not a real playlist, so there is nothing to overwrite
+	if overwrite && playlistId == LikedSongsId {
+		return nil, ErrOverwriteLiked
+	}
+
+	bp, err := b.repo.GetBackup(backupId)
+	if err != nil {
+		return
+	}
+
+	p, t, _, _, _, err := b.repo.GetBackupData(bp)
+	if err != nil {
+		return
+	}
+
+	var playlist *Playlist
+	for id := range *p {
+		if (*p)[id].SpotifyId == playlistId {
+			playlist = &(*p)[id]
+			break
+		}
+	}
+
+	if playlist == nil {
+		return nil, ErrPlaylistNotFound
+	}
+
+	st, err := b.auth.GetState()
+	if err != nil {
+		return
+	}
+
+	if !st.IsSet() {
+		return nil, errors.New("backup: user is not authenticated")
+	}
+
+	var cover []byte
+	if !overwrite && playlist.CoverBlob != "" && b.covers != nil {
+		cover, err = b.covers.Get(playlist.CoverBlob)
+		if err != nil {
+			log.Warn().Err(err).Msgf("backuper_restore: failed to read cover of '%s'", playlist.Name)
+			err = nil
+		}
+	}
+
+	client := newSpotifyClient(st.RefreshToken)
+	return restorePlaylist(&client, bp, playlist, t, cover, overwrite)
+}
+
+// Cover is only set on created playlists, nil cover is skipped
+func restorePlaylist(client restoreClient, bp *Backup, playlist *Playlist, t *[]Track, cover []byte, overwrite bool) (res *RestoreResult, err error) {
+	res = &RestoreResult{Name: playlist.Name, Overwrite: overwrite}
+
+	var tracks []Track
+	var ids []spotify.ID
+	for _, v := range *t {
+		if v.PlaylistId != playlist.Id {
+			continue
+		}
+
+		// local files only exist on the device they were added from
+		if v.SpotifyId == "" {
+			res.Failed = append(res.Failed, v)
+			continue
+		}
+
+		tracks = append(tracks, v)
+		ids = append(ids, spotify.ID(v.SpotifyId))
+	}
+
+	// Spotify has no way to replace more than a single batch at once, so tracks of
+	// overwritten playlist are kept to put them back if restore fails midway.
+	var original []spotify.ID
+	if overwrite {
+		res.PlaylistId = playlist.SpotifyId
+		original, err = playlistTrackIds(client, spotify.ID(res.PlaylistId))
+		if err != nil {
+			return
+		}
+	} else {
+		usr, err := client.CurrentUser()
+		if err != nil {
+			return nil, err
+		}
+
+		description := playlist.Description
+		if description == "" {
+			description = fmt.Sprintf("Restored from backup made at %s", bp.Started.Format("2006-01-02 15:04"))
+		}
+
+		// Spotify client doesn't support creating collaborative playlists, so they are
+		// restored as private ones, which Spotify requires for collaborative anyway.
+		public := playlist.Public && !playlist.Collaborative
+		created, err := client.CreatePlaylistForUser(usr.ID, playlist.Name, description, public)
+		if err != nil {
+			return nil, err
+		}
+
+		res.PlaylistId = string(created.ID)
+
+		// Missing cover doesn't make the restored tracks less useful, so it doesn't fail restore
+		if len(cover) > 0 {
+			err = client.SetPlaylistImage(created.ID, bytes.NewReader(cover))
+			if err != nil {
+				log.Warn().Err(err).Msgf("backuper_restore: failed to set cover of %s", created.ID)
+			}
+		}
+	}
+
+	log.Info().Msgf("backuper_restore: restoring %d tracks of '%s' from backup %d to %s", len(ids), playlist.Name, bp.Id, res.PlaylistId)
+
+	if overwrite && len(ids) == 0 {
+		err = client.ReplacePlaylistTracks(spotify.ID(res.PlaylistId))
+		if err != nil {
+			return res, err
+		}
+	}
+
+	for start := 0; start < len(ids); start += restoreBatchSize {
+		end := start + restoreBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		// First batch replaces tracks when overwriting, following ones are appended
+		failed, err := addRestoreBatch(client, spotify.ID(res.PlaylistId), ids[start:end], overwrite && start == 0)
+		if err != nil {
+			if overwrite {
+				res.RolledBack = rollbackPlaylist(client, spotify.ID(res.PlaylistId), original)
+			}
+
+			return res, err
+		}
+
+		for _, id := range failed {
+			res.Failed = append(res.Failed, tracks[start+id])
+		}
+		res.Added += end - start - len(failed)
+	}
+
+	log.Info().Msgf("backuper_restore: restored %d tracks to %s, %d failed", res.Added, res.PlaylistId, len(res.Failed))
+	return
+}
+
+// Local files can't be added through the API, so they are not included
+func playlistTrackIds(client restoreClient, playlist spotify.ID) (ids []spotify.ID, err error) {
+	limit := restoreBatchSize
+	for offset := 0; ; offset += limit {
+		page, err := client.GetPlaylistTracksOpt(playlist, &spotify.Options{Limit: &limit, Offset: &offset}, "total,items(is_local,track(id))")
+		if err != nil {
+			return nil, err
+		}
+
+		for _, v := range page.Tracks {
+			if !v.IsLocal && v.Track.ID != "" {
+				ids = append(ids, v.Track.ID)
+			}
+		}
+
+		if len(page.Tracks) == 0 || offset+len(page.Tracks) >= page.Total {
+			return ids, nil
+		}
+	}
+}
+
+// Returns whether original tracks were written back
+func rollbackPlaylist(client restoreClient, playlist spotify.ID, ids []spotify.ID) bool {
+	log.Warn().Msgf("backuper_restore: restore failed, writing back %d original tracks to %s", len(ids), playlist)
+
+	var err error
+	if len(ids) == 0 {
+		err = client.ReplacePlaylistTracks(playlist)
+	}
+
+	for start := 0; start < len(ids) && err == nil; start += restoreBatchSize {
+		end := start + restoreBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		if start == 0 {
+			err = client.ReplacePlaylistTracks(playlist, ids[start:end]...)
+		} else {
+			_, err = client.AddTracksToPlaylist(playlist, ids[start:end]...)
+		}
+	}
+
+	if err != nil {
+		log.Error().Err(err).Msgf("backuper_restore: failed to write back original tracks, playlist %s is partially restored", playlist)
+		return false
+	}
+
+	return true
+}
+
+// Spotify rejects the whole request with bad request if any of the tracks doesn't exist.
+// Other errors like rate limit or expired auth would fail for every single track as well.
+func isRejectedTrack(err error) bool {
+	var se spotify.Error
+	return errors.As(err, &se) && se.Status == http.StatusBadRequest
+}
+
+// If the whole batch is rejected because of its tracks, they are retried one by one to find
+// which of them failed. Returns indexes of tracks in batch that couldn't be added.
+func addRestoreBatch(client restoreClient, playlist spotify.ID, ids []spotify.ID, replace bool) (failed []int, err error) {
+	if replace {
+		err = client.ReplacePlaylistTracks(playlist, ids...)
+	} else {
+		_, err = client.AddTracksToPlaylist(playlist, ids...)
+	}
+
+	if err == nil || !isRejectedTrack(err) {
+		return
+	}
+
+	log.Warn().Err(err).Msg("backuper_restore: failed to add batch, retrying tracks one by one")
+	if replace {
+		// clear playlist, so that remaining tracks can be appended
+		err = client.ReplacePlaylistTracks(playlist)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for id, v := range ids {
+		_, err := client.AddTracksToPlaylist(playlist, v)
+		if isRejectedTrack(err) {
+			log.Warn().Err(err).Msgf("backuper_restore: failed to add track %s", v)
+			failed = append(failed, id)
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return failed, nil
+}
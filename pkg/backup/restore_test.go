@@ -0,0 +1,243 @@
+package backup
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/hoffs/crispy-musicular/pkg/config"
+	"github.com/stretchr/testify/require"
+	"github.com/zmb3/spotify"
+)
+
+// Keeps playlists in memory, requests containing rejected tracks fail with bad request
+type fakeRestoreClient struct {
+	playlists map[spotify.ID][]spotify.ID
+	rejected  map[spotify.ID]bool
+	// Add requests succeed this many times before failing with rate limit, -1 never fails
+	addsBeforeFailure int
+	adds              int
+	public            bool
+	cover             []byte
+}
+
+func newFakeRestoreClient(original ...spotify.ID) *fakeRestoreClient {
+	return &fakeRestoreClient{
+		playlists:         map[spotify.ID][]spotify.ID{"original": original},
+		rejected:          map[spotify.ID]bool{},
+		addsBeforeFailure: -1,
+	}
+}
+
+func (c *fakeRestoreClient) CurrentUser() (*spotify.PrivateUser, error) {
+	return &spotify.PrivateUser{User: spotify.User{ID: "user"}}, nil
+}
+
+func (c *fakeRestoreClient) CreatePlaylistForUser(userID, playlistName, description string, public bool) (*spotify.FullPlaylist, error) {
+	c.playlists["created"] = nil
+	c.public = public
+	p := &spotify.FullPlaylist{}
+	p.ID = "created"
+	return p, nil
+}
+
+func (c *fakeRestoreClient) GetPlaylistTracksOpt(playlistID spotify.ID, opt *spotify.Options, fields string) (*spotify.PlaylistTrackPage, error) {
+	ids := c.playlists[playlistID]
+	page := &spotify.PlaylistTrackPage{}
+	page.Total = len(ids)
+	for i := *opt.Offset; i < len(ids) && i < *opt.Offset+*opt.Limit; i++ {
+		var t spotify.PlaylistTrack
+		t.Track.ID = ids[i]
+		page.Tracks = append(page.Tracks, t)
+	}
+
+	return page, nil
+}
+
+func (c *fakeRestoreClient) SetPlaylistImage(playlistID spotify.ID, img io.Reader) (err error) {
+	c.cover, err = ioutil.ReadAll(img)
+	return
+}
+
+func (c *fakeRestoreClient) check(ids []spotify.ID) error {
+	if len(ids) > restoreBatchSize {
+		return spotify.Error{Message: "too many tracks", Status: http.StatusBadRequest}
+	}
+
+	for _, id := range ids {
+		if c.rejected[id] {
+			return spotify.Error{Message: "invalid track uri", Status: http.StatusBadRequest}
+		}
+	}
+
+	return nil
+}
+
+func (c *fakeRestoreClient) ReplacePlaylistTracks(playlistID spotify.ID, trackIDs ...spotify.ID) error {
+	err := c.check(trackIDs)
+	if err != nil {
+		return err
+	}
+
+	c.playlists[playlistID] = append([]spotify.ID{}, trackIDs...)
+	return nil
+}
+
+func (c *fakeRestoreClient) AddTracksToPlaylist(playlistID spotify.ID, trackIDs ...spotify.ID) (string, error) {
+	if c.addsBeforeFailure >= 0 && c.adds >= c.addsBeforeFailure {
+		return "", spotify.Error{Message: "rate limit", Status: http.StatusTooManyRequests}
+	}
+	c.adds++
+
+	err := c.check(trackIDs)
+	if err != nil {
+		return "", err
+	}
+
+	c.playlists[playlistID] = append(c.playlists[playlistID], trackIDs...)
+	return "", nil
+}
+
+func restoreTracks(n int) (ids []spotify.ID, t []Track) {
+	for i := 0; i < n; i++ {
+		id := fmt.Sprintf("t%d", i)
+		ids = append(ids, spotify.ID(id))
+		t = append(t, Track{PlaylistId: 1, SpotifyId: id, Name: id})
+	}
+	return
+}
+
+func TestRestorePlaylist(t *testing.T) {
+	ids, tracks := restoreTracks(250)
+	local := Track{PlaylistId: 1, Name: "local", IsLocal: true}
+	other := Track{PlaylistId: 2, SpotifyId: "other"}
+	all := append([]Track{local, other}, tracks...)
+
+	original := []spotify.ID{"o1", "o2"}
+	playlist := &Playlist{Id: 1, SpotifyId: "original", Name: "Name"}
+
+	for _, tc := range []struct {
+		name      string
+		overwrite bool
+		rejected  []int
+		// Adds succeeding before rate limit, -1 never fails
+		addsBeforeFailure int
+		err               bool
+		expected          []spotify.ID
+		failed            []string
+		rolledBack        bool
+		// Successful add requests, checked if set
+		adds int
+	}{
+		{name: "new playlist", addsBeforeFailure: -1, expected: ids},
+		{name: "overwrite", overwrite: true, addsBeforeFailure: -1, expected: ids},
+		{
+			name: "rejected tracks are retried one by one", addsBeforeFailure: -1,
+			rejected: []int{5, 120, 249},
+			failed:   []string{"t5", "t120", "t249"},
+		},
+		{
+			name: "rejected tracks in replaced batch", overwrite: true, addsBeforeFailure: -1,
+			rejected: []int{0, 99, 100},
+			failed:   []string{"t0", "t99", "t100"},
+		},
+		{
+			name: "rate limit is not retried", overwrite: true, addsBeforeFailure: 1,
+			err: true, expected: original, rolledBack: true, adds: 1,
+		},
+		{
+			name: "rate limit while retrying rejected tracks", overwrite: true, addsBeforeFailure: 10,
+			rejected: []int{3},
+			err:      true, expected: original, rolledBack: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := newFakeRestoreClient(original...)
+			c.addsBeforeFailure = tc.addsBeforeFailure
+			for _, i := range tc.rejected {
+				c.rejected[ids[i]] = true
+			}
+
+			res, err := restorePlaylist(c, &Backup{Id: 1}, playlist, &all, nil, tc.overwrite)
+			if tc.err {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			require.Equal(t, tc.rolledBack, res.RolledBack)
+			if tc.adds > 0 {
+				require.Equal(t, tc.adds, c.adds)
+			}
+
+			target := spotify.ID("created")
+			if tc.overwrite {
+				target = "original"
+			} else {
+				require.False(t, c.public)
+				require.Equal(t, original, c.playlists["original"])
+			}
+
+			expected := tc.expected
+			if expected == nil {
+				for i, id := range ids {
+					if !c.rejected[id] {
+						expected = append(expected, ids[i])
+					}
+				}
+			}
+			require.Equal(t, expected, c.playlists[target])
+
+			if tc.err {
+				return
+			}
+
+			require.Equal(t, len(expected), res.Added)
+			// local file is always reported first, followed by rejected tracks in order
+			failed := []string{"local"}
+			failed = append(failed, tc.failed...)
+			var names []string
+			for _, v := range res.Failed {
+				names = append(names, v.Name)
+			}
+			require.Equal(t, failed, names)
+		})
+	}
+}
+
+func TestRestoreOverwriteEmpty(t *testing.T) {
+	c := newFakeRestoreClient("o1")
+	res, err := restorePlaylist(c, &Backup{Id: 1}, &Playlist{Id: 1, SpotifyId: "original"}, &[]Track{}, nil, true)
+	require.NoError(t, err)
+	require.Equal(t, 0, res.Added)
+	require.Empty(t, c.playlists["original"])
+}
+
+func TestRestorePlaylistMetadata(t *testing.T) {
+	c := newFakeRestoreClient()
+	p := &Playlist{Id: 1, Name: "Name", Public: true}
+	_, err := restorePlaylist(c, &Backup{Id: 1}, p, &[]Track{}, []byte("cover"), false)
+	require.NoError(t, err)
+	require.True(t, c.public)
+	require.Equal(t, []byte("cover"), c.cover)
+
+	// collaborative playlists can't be public
+	c = newFakeRestoreClient()
+	p.Collaborative = true
+	_, err = restorePlaylist(c, &Backup{Id: 1}, p, &[]Track{}, nil, false)
+	require.NoError(t, err)
+	require.False(t, c.public)
+	require.Nil(t, c.cover)
+}
+
+func TestRestoreOverwriteLiked(t *testing.T) {
+	b := &backuper{config: &config.AppConfig{SpotifyRestoreEnabled: true}}
+	_, err := b.Restore(1, LikedSongsId, true)
+	require.ErrorIs(t, err, ErrOverwriteLiked)
+
+	b.config.SpotifyRestoreEnabled = false
+	_, err = b.Restore(1, LikedSongsId, false)
+	require.ErrorIs(t, err, ErrRestoreDisabled)
+}
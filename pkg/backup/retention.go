@@ -0,0 +1,177 @@
+package backup
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hoffs/crispy-musicular/pkg/config"
+	"github.com/rs/zerolog/log"
+)
+
+type RetentionPolicy struct {
+	KeepLast        int  // Newest successful backups to keep, at least 1 is always kept
+	KeepDailyDays   int  // Keep newest successful backup of each day for this many days
+	KeepWeeklyWeeks int  // Keep newest successful backup of each week for this many weeks
+	KeepMonthly     bool // Keep newest successful backup of each month forever
+	KeepFailedDays  int  // Keep failed backups for this many days
+}
+
+func newRetentionPolicy(c *config.AppConfig) *RetentionPolicy {
+	return &RetentionPolicy{
+		KeepLast:        c.RetentionKeepLast,
+		KeepDailyDays:   c.RetentionKeepDailyDays,
+		KeepWeeklyWeeks: c.RetentionKeepWeeklyWeeks,
+		KeepMonthly:     c.RetentionKeepMonthly,
+		KeepFailedDays:  c.RetentionKeepFailedDays,
+	}
+}
+
+// Backups which haven't finished this long ago could still be running
+const unfinishedBackupGrace = 24 * time.Hour
+
+// Returns backups which are not kept by any rule of the policy. Rules are applied
+// to backups of each platform separately, so that partial backups of one platform
+// don't push out backups of the other one. Backups have to be sorted newest first.
+func (p *RetentionPolicy) expired(backups []Backup, now time.Time) (expired []Backup) {
+	failedFrom := now.AddDate(0, 0, -p.KeepFailedDays)
+
+	for _, b := range backups {
+		if b.Success {
+			continue
+		}
+
+		unfinished := b.Finished.IsZero() && b.Started.After(now.Add(-unfinishedBackupGrace))
+		if !unfinished && b.Started.Before(failedFrom) {
+			expired = append(expired, b)
+		}
+	}
+
+	kept := make([]bool, len(backups))
+	for _, platform := range []Scope{ScopeSpotify, ScopeYoutube} {
+		var successful []int
+		var started []time.Time
+		for id, b := range backups {
+			if b.Success && b.Covers(platform) {
+				successful = append(successful, id)
+				started = append(started, b.Started)
+			}
+		}
+
+		for id, keep := range p.Kept(started, now) {
+			if keep {
+				kept[successful[id]] = true
+			}
+		}
+	}
+
+	for id, b := range backups {
+		if b.Success && !kept[id] {
+			expired = append(expired, b)
+		}
+	}
+
+	sort.SliceStable(expired, func(i, j int) bool {
+		return expired[i].Started.After(expired[j].Started)
+	})
+
+	return
+}
+
+// Returns whether each of the times is kept by keep last, daily, weekly
+// and monthly rules. Times have to be sorted newest first.
+func (p *RetentionPolicy) Kept(times []time.Time, now time.Time) (kept []bool) {
+	keepLast := p.KeepLast
+	if keepLast < 1 {
+		keepLast = 1
+	}
+
+	dailyFrom := now.AddDate(0, 0, -p.KeepDailyDays)
+	weeklyFrom := now.AddDate(0, 0, -7*p.KeepWeeklyWeeks)
+
+	days := make(map[string]bool)
+	weeks := make(map[string]bool)
+	months := make(map[string]bool)
+
+	kept = make([]bool, len(times))
+	for id, t := range times {
+		keep := id < keepLast
+
+		day := t.Format("2006-01-02")
+		if t.After(dailyFrom) && !days[day] {
+			days[day] = true
+			keep = true
+		}
+
+		year, week := t.ISOWeek()
+		weekKey := fmt.Sprintf("%d-%d", year, week)
+		if t.After(weeklyFrom) && !weeks[weekKey] {
+			weeks[weekKey] = true
+			keep = true
+		}
+
+		month := t.Format("2006-01")
+		if p.KeepMonthly && !months[month] {
+			months[month] = true
+			keep = true
+		}
+
+		kept[id] = keep
+	}
+
+	return
+}
+
+// Deletes backups of the user which are not kept by the configured retention policy.
+// In dry run mode nothing is deleted, only backups that would be deleted are returned.
+func (b *backuper) Prune(userId string, dryRun bool) (expired *[]Backup, err error) {
+	backups, err := b.repo.GetBackups(userId)
+	if err != nil {
+		log.Error().Err(err).Msg("backuper_retention: failed to get backups")
+		return
+	}
+
+	e := newRetentionPolicy(b.config).expired(*backups, time.Now())
+	expired = &e
+
+	for _, v := range e {
+		log.Info().Msgf("backuper_retention: backup %d started at %s (success: %t) is expired, dry run: %t", v.Id, v.Started.Format(time.RFC3339), v.Success, dryRun)
+	}
+
+	if dryRun || len(e) == 0 {
+		return
+	}
+
+	err = b.deleteBackups(expired)
+	if err != nil {
+		log.Error().Err(err).Msg("backuper_retention: failed to delete backups")
+		return
+	}
+
+	err = b.repo.Vacuum()
+	if err != nil {
+		log.Error().Err(err).Msg("backuper_retention: failed to vacuum database")
+		return
+	}
+
+	log.Info().Msgf("backuper_retention: deleted %d backups", len(e))
+	return
+}
+
+// Deletes backups and covers which are no longer used by any backup. Cover
+// that fails to be deleted is only left behind, so errors are just logged.
+func (b *backuper) deleteBackups(backups *[]Backup) (err error) {
+	orphaned, err := b.repo.DeleteBackups(backups)
+	if err != nil || b.covers == nil {
+		return
+	}
+
+	for _, key := range orphaned {
+		cerr := b.covers.Delete(key)
+		if cerr != nil {
+			log.Warn().Err(cerr).Msgf("backuper_retention: failed to delete cover %s", key)
+		}
+	}
+
+	return
+}
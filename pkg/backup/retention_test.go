@@ -0,0 +1,153 @@
+package backup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hoffs/crispy-musicular/pkg/blob"
+	"github.com/hoffs/crispy-musicular/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func retentionIds(b []Backup) (ids []int64) {
+	for _, v := range b {
+		ids = append(ids, v.Id)
+	}
+	return
+}
+
+func TestRetentionKeepLast(t *testing.T) {
+	now := time.Date(2021, 6, 30, 12, 0, 0, 0, time.UTC)
+	var backups []Backup
+	for i := 0; i < 5; i++ {
+		started := now.Add(-time.Duration(i) * time.Hour)
+		backups = append(backups, Backup{Id: int64(5 - i), Success: true, Started: started, Finished: started})
+	}
+
+	p := &RetentionPolicy{KeepLast: 2}
+	require.Equal(t, []int64{3, 2, 1}, retentionIds(p.expired(backups, now)))
+
+	// latest successful backup is always kept
+	p = &RetentionPolicy{}
+	require.Equal(t, []int64{4, 3, 2, 1}, retentionIds(p.expired(backups, now)))
+}
+
+func TestRetentionCalendar(t *testing.T) {
+	now := time.Date(2021, 6, 30, 12, 0, 0, 0, time.UTC)
+	var backups []Backup
+	// two backups a day for 90 days
+	for i := 0; i < 180; i++ {
+		started := now.Add(-time.Duration(i) * 12 * time.Hour)
+		backups = append(backups, Backup{Id: int64(180 - i), Success: true, Started: started, Finished: started})
+	}
+
+	p := &RetentionPolicy{KeepLast: 1, KeepDailyDays: 7}
+	expired := p.expired(backups, now)
+	require.Len(t, expired, 180-7)
+
+	p = &RetentionPolicy{KeepLast: 1, KeepDailyDays: 7, KeepWeeklyWeeks: 4}
+	kept := 180 - len(p.expired(backups, now))
+	// 7 days and 3 more weeks
+	require.Equal(t, 10, kept)
+
+	p = &RetentionPolicy{KeepLast: 1, KeepMonthly: true}
+	expired = p.expired(backups, now)
+	// June, May and April
+	require.Equal(t, 180-3, len(expired))
+	require.NotContains(t, retentionIds(expired), int64(180))
+}
+
+func TestRetentionFailed(t *testing.T) {
+	now := time.Date(2021, 6, 30, 12, 0, 0, 0, time.UTC)
+	backups := []Backup{
+		{Id: 5, Started: now.Add(-time.Hour)}, // still running
+		{Id: 4, Success: true, Started: now.AddDate(0, 0, -1), Finished: now.AddDate(0, 0, -1)},
+		{Id: 3, Started: now.AddDate(0, 0, -2), Finished: now.AddDate(0, 0, -2)},
+		{Id: 2, Started: now.AddDate(0, 0, -3)}, // never finished
+		{Id: 1, Started: now.AddDate(0, 0, -10), Finished: now.AddDate(0, 0, -10)},
+	}
+
+	p := &RetentionPolicy{KeepLast: 1, KeepFailedDays: 7}
+	require.Equal(t, []int64{1}, retentionIds(p.expired(backups, now)))
+
+	p = &RetentionPolicy{KeepLast: 1}
+	require.Equal(t, []int64{3, 2, 1}, retentionIds(p.expired(backups, now)))
+}
+
+func TestRetentionPartialBackups(t *testing.T) {
+	now := time.Date(2021, 6, 30, 12, 0, 0, 0, time.UTC)
+	backups := []Backup{
+		{Id: 6, Scope: ScopeSpotify},
+		{Id: 5, Scope: ScopeSpotify},
+		{Id: 4, Scope: ScopeYoutube},
+		{Id: 3, Scope: ScopeAll},
+		{Id: 2, Scope: ScopeSpotify},
+		{Id: 1, Scope: ScopeAll},
+	}
+	for i := range backups {
+		started := now.Add(-time.Duration(i) * time.Hour)
+		backups[i].Success, backups[i].Started, backups[i].Finished = true, started, started
+	}
+
+	// newest Youtube backup is kept even though newer Spotify backups exist
+	p := &RetentionPolicy{KeepLast: 1}
+	require.Equal(t, []int64{5, 3, 2, 1}, retentionIds(p.expired(backups, now)))
+
+	// complete backup is kept if it is kept for any of the platforms
+	p = &RetentionPolicy{KeepLast: 2}
+	require.Equal(t, []int64{2, 1}, retentionIds(p.expired(backups, now)))
+}
+
+type pruneRepository struct {
+	Repository
+	backups  []Backup
+	deleted  []Backup
+	orphaned []string
+}
+
+func (r *pruneRepository) GetBackups(userId string) (*[]Backup, error) {
+	return &r.backups, nil
+}
+
+func (r *pruneRepository) DeleteBackups(b *[]Backup) ([]string, error) {
+	r.deleted = append(r.deleted, *b...)
+	return r.orphaned, nil
+}
+
+func (r *pruneRepository) Vacuum() error {
+	return nil
+}
+
+func TestPruneDeletesOrphanedCovers(t *testing.T) {
+	covers, err := blob.NewFileStore(t.TempDir())
+	require.NoError(t, err)
+	orphaned, err := covers.Put([]byte("orphaned"))
+	require.NoError(t, err)
+	used, err := covers.Put([]byte("used"))
+	require.NoError(t, err)
+
+	now := time.Now()
+	repo := &pruneRepository{
+		backups: []Backup{
+			{Id: 2, Success: true, Started: now, Finished: now},
+			{Id: 1, Success: true, Started: now.AddDate(-1, 0, 0), Finished: now.AddDate(-1, 0, 0)},
+		},
+		orphaned: []string{orphaned},
+	}
+	b := &backuper{config: &config.AppConfig{RetentionKeepLast: 1}, repo: repo, covers: covers}
+
+	// dry run doesn't delete anything
+	expired, err := b.Prune("user", true)
+	require.NoError(t, err)
+	require.Equal(t, []int64{1}, retentionIds(*expired))
+	require.Empty(t, repo.deleted)
+
+	_, err = b.Prune("user", false)
+	require.NoError(t, err)
+	require.Equal(t, []int64{1}, retentionIds(repo.deleted))
+
+	_, err = covers.Get(orphaned)
+	require.ErrorIs(t, err, blob.ErrNotFound)
+	_, err = covers.Get(used)
+	require.NoError(t, err)
+}
@@ -0,0 +1,257 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hoffs/crispy-musicular/pkg/rand"
+	"github.com/hoffs/crispy-musicular/pkg/schedule"
+	"github.com/rs/zerolog/log"
+)
+
+var ErrScheduleNotFound = errors.New("backup: schedule not found")
+
+const (
+	ScheduleSpotify = "spotify"
+	ScheduleYoutube = "youtube"
+
+	// Config can be reloaded, so schedules are re-read at least this often
+	scheduleRecheck = time.Minute
+)
+
+// Next activation of a schedule which is persisted, so that runs missed
+// while the app was down are noticed. Spec describes the configuration it was computed with.
+type ScheduledRun struct {
+	Name string    `json:"name"`
+	Spec string    `json:"spec"`
+	Next time.Time `json:"next"`
+}
+
+type scheduleEntry struct {
+	name     string
+	spec     string
+	scope    Scope
+	schedule schedule.Schedule
+}
+
+type schedulePlan struct {
+	entries []scheduleEntry
+	loc     *time.Location
+	quiet   schedule.Windows
+	jitter  time.Duration
+}
+
+// Spotify schedule falls back to the run interval. When Youtube has its own
+// schedule, Spotify schedule only backs up Spotify.
+func (b *backuper) schedulePlan() (p schedulePlan, err error) {
+	p.loc, err = b.config.ScheduleLocation()
+	if err != nil {
+		return
+	}
+
+	p.quiet, err = schedule.ParseWindows(b.config.QuietHours)
+	if err != nil {
+		return
+	}
+
+	p.jitter = time.Duration(b.config.ScheduleJitterSeconds) * time.Second
+
+	spotifyScope := ScopeAll
+	if b.config.YoutubeSchedule != "" {
+		spotifyScope = ScopeSpotify
+	}
+
+	// run interval isn't parsed, since @every doesn't accept intervals under a minute
+	if b.config.SpotifySchedule == "" {
+		interval := time.Duration(b.config.RunIntervalSeconds) * time.Second
+		p.addSchedule(ScheduleSpotify, fmt.Sprintf("@every %ds", b.config.RunIntervalSeconds), spotifyScope, schedule.Every(interval))
+	} else {
+		err = p.add(ScheduleSpotify, b.config.SpotifySchedule, spotifyScope)
+	}
+
+	if err != nil || b.config.YoutubeSchedule == "" {
+		return
+	}
+
+	err = p.add(ScheduleYoutube, b.config.YoutubeSchedule, ScopeYoutube)
+	return
+}
+
+func (p *schedulePlan) add(name string, expr string, scope Scope) error {
+	s, err := schedule.Parse(expr, p.loc)
+	if err != nil {
+		return err
+	}
+
+	p.addSchedule(name, expr, scope, s)
+	return nil
+}
+
+func (p *schedulePlan) addSchedule(name string, expr string, scope Scope, s schedule.Schedule) {
+	p.entries = append(p.entries, scheduleEntry{name: name, spec: p.spec(expr), scope: scope, schedule: s})
+}
+
+func (p *schedulePlan) spec(expr string) string {
+	quiet := make([]string, 0, len(p.quiet))
+	for _, w := range p.quiet {
+		quiet = append(quiet, w.String())
+	}
+
+	return fmt.Sprintf("%s; tz=%s; jitter=%s; quiet=%s", expr, p.loc, p.jitter, strings.Join(quiet, ","))
+}
+
+// Zero time means that schedule is never activated
+func (p *schedulePlan) next(e *scheduleEntry, from time.Time) (next time.Time, err error) {
+	next = e.schedule.Next(from)
+	if next.IsZero() {
+		return
+	}
+
+	jitter, err := rand.Duration(p.jitter)
+	if err != nil {
+		return
+	}
+
+	next = p.quiet.Defer(next.Add(jitter), p.loc)
+	return
+}
+
+// Returns persisted next run, which is recomputed when schedule configuration has changed
+func (b *backuper) scheduledRun(p *schedulePlan, e *scheduleEntry, now time.Time) (s *ScheduledRun, err error) {
+	s, err = b.repo.GetScheduledRun(e.name)
+	if err != nil && !errors.Is(err, ErrScheduleNotFound) {
+		return
+	}
+
+	if err == nil && s.Spec == e.spec {
+		return
+	}
+
+	return b.reschedule(p, e, now)
+}
+
+func (b *backuper) reschedule(p *schedulePlan, e *scheduleEntry, from time.Time) (s *ScheduledRun, err error) {
+	s = &ScheduledRun{Name: e.name, Spec: e.spec}
+	s.Next, err = p.next(e, from)
+	if err != nil {
+		return
+	}
+
+	err = b.repo.SetScheduledRun(s)
+	return
+}
+
+// Runs schedules which are due, at most once even if multiple activations were missed.
+// Returns how long to wait until schedules should be checked again.
+func (b *backuper) runDueSchedules(ctx context.Context) (wait time.Duration) {
+	wait = scheduleRecheck
+
+	p, err := b.schedulePlan()
+	if err != nil {
+		log.Error().Err(err).Msg("backuper_periodic: invalid schedule configuration")
+		return
+	}
+
+	for i := range p.entries {
+		e := &p.entries[i]
+		s, err := b.scheduledRun(&p, e, time.Now())
+		if err != nil {
+			log.Error().Err(err).Str("schedule", e.name).Msg("backuper_periodic: failed to get next run")
+			continue
+		}
+
+		if s.Next.IsZero() {
+			log.Warn().Str("schedule", e.name).Msg("backuper_periodic: schedule is never activated")
+			continue
+		}
+
+		if !s.Next.After(time.Now()) {
+			log.Info().Str("schedule", e.name).Time("scheduled", s.Next).Msg("backuper_periodic: starting scheduled backup")
+			b.runScheduled(ctx, e.scope)
+			if ctx.Err() != nil {
+				return
+			}
+
+			s, err = b.reschedule(&p, e, time.Now())
+			if err != nil {
+				log.Error().Err(err).Str("schedule", e.name).Msg("backuper_periodic: failed to save next run")
+				continue
+			}
+
+			if s.Next.IsZero() {
+				continue
+			}
+		}
+
+		if until := time.Until(s.Next); until < wait {
+			wait = until
+		}
+	}
+
+	if wait < 0 {
+		wait = 0
+	}
+
+	return
+}
+
+// Waits for the run to finish, run is cancelled if ctx is done
+func (b *backuper) runScheduled(ctx context.Context, scope Scope) {
+	r, joined := b.runs.start(TriggerScheduled, scope, false)
+	if joined {
+		log.Info().Msg("backuper_periodic: backup is already running, waiting for it instead")
+	}
+
+	select {
+	case <-r.done:
+	case <-ctx.Done():
+		log.Info().Msg("backuper_periodic: context finished, cancelling running backup")
+		b.CancelBackup(b.runs.info(r).Id)
+	}
+
+	err := r.wait()
+	if err != nil {
+		log.Error().Err(err).Msg("backuper_periodic: backup finished with errors")
+	}
+}
+
+// should be started as goroutine
+func (b *backuper) RunPeriodically(ctx context.Context) {
+	log.Info().Msg("backuper_periodic: started")
+
+	for {
+		select {
+		case <-time.After(b.runDueSchedules(ctx)):
+		case <-ctx.Done():
+			log.Debug().Msg("backuper_periodic: context finished, stopping backups")
+			return
+		}
+	}
+}
+
+// Returns next runs of currently configured schedules
+func (b *backuper) GetScheduledRuns() (runs *[]ScheduledRun, err error) {
+	p, err := b.schedulePlan()
+	if err != nil {
+		return
+	}
+
+	saved, err := b.repo.GetScheduledRuns()
+	if err != nil {
+		return
+	}
+
+	r := []ScheduledRun{}
+	for _, e := range p.entries {
+		for _, s := range *saved {
+			if s.Name == e.name && s.Spec == e.spec {
+				r = append(r, s)
+			}
+		}
+	}
+
+	return &r, nil
+}
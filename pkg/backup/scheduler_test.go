@@ -0,0 +1,181 @@
+package backup
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hoffs/crispy-musicular/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+type scheduleRepository struct {
+	Repository
+	runs map[string]ScheduledRun
+}
+
+func (r *scheduleRepository) GetScheduledRun(name string) (*ScheduledRun, error) {
+	s, ok := r.runs[name]
+	if !ok {
+		return nil, ErrScheduleNotFound
+	}
+
+	return &s, nil
+}
+
+func (r *scheduleRepository) GetScheduledRuns() (*[]ScheduledRun, error) {
+	runs := []ScheduledRun{}
+	for _, s := range r.runs {
+		runs = append(runs, s)
+	}
+
+	return &runs, nil
+}
+
+func (r *scheduleRepository) SetScheduledRun(s *ScheduledRun) error {
+	r.runs[s.Name] = *s
+	return nil
+}
+
+type scopeRuns struct {
+	scopes []Scope
+}
+
+func (sr *scopeRuns) execute(ctx context.Context, scope Scope) error {
+	sr.scopes = append(sr.scopes, scope)
+	return nil
+}
+
+func newScheduleBackuper(c *config.AppConfig) (*backuper, *scheduleRepository, *scopeRuns) {
+	repo := &scheduleRepository{runs: map[string]ScheduledRun{}}
+	sr := &scopeRuns{}
+	b := &backuper{config: c, repo: repo, events: NewEventBus()}
+	b.runs = newCoordinator(sr.execute)
+	return b, repo, sr
+}
+
+func TestSchedulePlan(t *testing.T) {
+	b, _, _ := newScheduleBackuper(&config.AppConfig{RunIntervalSeconds: 3600})
+
+	p, err := b.schedulePlan()
+	require.NoError(t, err)
+	require.Len(t, p.entries, 1)
+	require.Equal(t, ScheduleSpotify, p.entries[0].name)
+	require.Equal(t, ScopeAll, p.entries[0].scope)
+
+	b.config.SpotifySchedule = "0 3 * * *"
+	b.config.YoutubeSchedule = "@weekly"
+	b.config.ScheduleTimezone = "UTC"
+	p, err = b.schedulePlan()
+	require.NoError(t, err)
+	require.Len(t, p.entries, 2)
+	require.Equal(t, ScopeSpotify, p.entries[0].scope)
+	require.Equal(t, ScopeYoutube, p.entries[1].scope)
+
+	now := time.Date(2021, 6, 30, 12, 0, 0, 0, time.UTC)
+	next, err := p.next(&p.entries[0], now)
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2021, 7, 1, 3, 0, 0, 0, time.UTC), next)
+}
+
+func TestSchedulePlanShortInterval(t *testing.T) {
+	b, _, _ := newScheduleBackuper(&config.AppConfig{RunIntervalSeconds: 30})
+
+	p, err := b.schedulePlan()
+	require.NoError(t, err)
+	require.Len(t, p.entries, 1)
+
+	now := time.Date(2021, 6, 30, 12, 0, 0, 0, time.UTC)
+	next, err := p.next(&p.entries[0], now)
+	require.NoError(t, err)
+	require.Equal(t, now.Add(30*time.Second), next)
+
+	// cron schedules still have to be valid
+	b.config.SpotifySchedule = "@every 30s"
+	_, err = b.schedulePlan()
+	require.Error(t, err)
+}
+
+func TestScheduleNextQuietHoursAndJitter(t *testing.T) {
+	b, _, _ := newScheduleBackuper(&config.AppConfig{
+		SpotifySchedule:       "0 23 * * *",
+		ScheduleTimezone:      "UTC",
+		ScheduleJitterSeconds: 600,
+		QuietHours:            []string{"22:00-07:00"},
+	})
+
+	p, err := b.schedulePlan()
+	require.NoError(t, err)
+
+	now := time.Date(2021, 6, 30, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 10; i++ {
+		next, err := p.next(&p.entries[0], now)
+		require.NoError(t, err)
+		require.Equal(t, time.Date(2021, 7, 1, 7, 0, 0, 0, time.UTC), next)
+	}
+
+	b.config.QuietHours = nil
+	p, err = b.schedulePlan()
+	require.NoError(t, err)
+
+	scheduled := time.Date(2021, 6, 30, 23, 0, 0, 0, time.UTC)
+	next, err := p.next(&p.entries[0], now)
+	require.NoError(t, err)
+	require.False(t, next.Before(scheduled))
+	require.True(t, next.Before(scheduled.Add(10*time.Minute)))
+}
+
+func TestScheduledRunPersisted(t *testing.T) {
+	b, repo, _ := newScheduleBackuper(&config.AppConfig{SpotifySchedule: "0 3 * * *", ScheduleTimezone: "UTC"})
+	p, err := b.schedulePlan()
+	require.NoError(t, err)
+
+	now := time.Date(2021, 6, 30, 12, 0, 0, 0, time.UTC)
+	s, err := b.scheduledRun(&p, &p.entries[0], now)
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2021, 7, 1, 3, 0, 0, 0, time.UTC), s.Next)
+	require.Equal(t, *s, repo.runs[ScheduleSpotify])
+
+	// persisted run is kept even if it is in the past
+	s, err = b.scheduledRun(&p, &p.entries[0], now.AddDate(0, 0, 5))
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2021, 7, 1, 3, 0, 0, 0, time.UTC), s.Next)
+
+	// changed configuration is rescheduled
+	b.config.SpotifySchedule = "0 4 * * *"
+	p, err = b.schedulePlan()
+	require.NoError(t, err)
+	s, err = b.scheduledRun(&p, &p.entries[0], now)
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2021, 7, 1, 4, 0, 0, 0, time.UTC), s.Next)
+}
+
+func TestRunDueSchedules(t *testing.T) {
+	b, repo, sr := newScheduleBackuper(&config.AppConfig{
+		SpotifySchedule: "@hourly",
+		YoutubeSchedule: "@daily",
+	})
+	p, err := b.schedulePlan()
+	require.NoError(t, err)
+
+	// Spotify run was missed while app was down
+	missed := time.Now().Add(-3 * time.Hour)
+	repo.runs[ScheduleSpotify] = ScheduledRun{Name: ScheduleSpotify, Spec: p.entries[0].spec, Next: missed}
+
+	wait := b.runDueSchedules(context.Background())
+	require.Equal(t, []Scope{ScopeSpotify}, sr.scopes)
+	require.True(t, wait <= time.Hour)
+	require.True(t, repo.runs[ScheduleSpotify].Next.After(time.Now()))
+	require.True(t, repo.runs[ScheduleYoutube].Next.After(time.Now()))
+
+	runs, err := b.GetScheduledRuns()
+	require.NoError(t, err)
+	require.Len(t, *runs, 2)
+
+	// schedules that are no longer configured are not returned
+	b.config.YoutubeSchedule = ""
+	runs, err = b.GetScheduledRuns()
+	require.NoError(t, err)
+	require.Len(t, *runs, 1)
+	require.Equal(t, ScheduleSpotify, (*runs)[0].Name)
+}
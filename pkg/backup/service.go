@@ -3,10 +3,10 @@ package backup
 import (
 	"context"
 	"errors"
-	"sync"
 	"time"
 
 	"github.com/hoffs/crispy-musicular/pkg/auth"
+	"github.com/hoffs/crispy-musicular/pkg/blob"
 	"github.com/hoffs/crispy-musicular/pkg/config"
 	"github.com/rs/zerolog/log"
 	"github.com/zmb3/spotify"
@@ -18,12 +18,28 @@ type backuper struct {
 	auth    auth.Service
 	repo    Repository
 	actions []PostBackupAction
+	covers  blob.Store
+	events  *EventBus
+	runs    *coordinator
 }
 
 type Service interface {
 	Backup() (err error)
+	StartBackup(trigger Trigger, queue bool) (run RunInfo, joined bool)
+	CancelBackup(runId int64) (run RunInfo, err error)
+	RunStatus() RunStatus
 	RunPeriodically(ctx context.Context)
+	GetScheduledRuns() (runs *[]ScheduledRun, err error)
 	GetBackupStats(userId string) (stats *BackupStats, err error)
+	DiffBackups(fromId int64, toId int64) (d *BackupDiff, err error)
+	Restore(backupId int64, playlistId string, overwrite bool) (res *RestoreResult, err error)
+	GetLostTracks(userId string) (t *[]LostTrack, yt *[]LostYoutubeTrack, err error)
+	Prune(userId string, dryRun bool) (expired *[]Backup, err error)
+	Import(d *Document) (bp *Backup, err error)
+	ListBackups(userId string, offset int, limit int) (page *BackupPage, err error)
+	GetBackupPlaylists(backupId int64, offset int, limit int) (bp *Backup, page *PlaylistPage, err error)
+	GetPlaylistTracks(backupId int64, platform string, playlistId string, offset int, limit int) (t *PlaylistTracks, err error)
+	Events() *EventBus
 }
 
 func NewBackuper(c *config.AppConfig, s auth.Service, r Repository, actions ...PostBackupAction) (b Service, err error) {
@@ -37,95 +53,225 @@ func NewBackuper(c *config.AppConfig, s auth.Service, r Repository, actions ...P
 		return
 	}
 
-	b = &backuper{
+	bk := &backuper{
 		config:  c,
 		auth:    s,
 		repo:    r,
 		actions: actions,
+		events:  NewEventBus(),
 	}
+	bk.runs = newCoordinator(bk.backup)
+
+	if c.CoverImagesEnabled {
+		bk.covers, err = blob.NewFileStore(c.BlobDir)
+		if err != nil {
+			return
+		}
+	}
+
+	b = bk
 	return
 }
 
 type backupState struct {
 	ctx     context.Context
-	wg      sync.WaitGroup
 	spotify spotify.Client
 	youtube *gyoutube.Service
 	bp      *Backup
+	scope   Scope
 }
 
+// Starts a backup or joins the one that is already running and waits for it to finish
 func (b *backuper) Backup() (err error) {
+	r, _ := b.runs.start(TriggerManual, ScopeAll, false)
+	return r.wait()
+}
+
+// Doesn't wait for the backup, with queue a new backup is started after the running one
+// instead of joining it.
+func (b *backuper) StartBackup(trigger Trigger, queue bool) (run RunInfo, joined bool) {
+	r, joined := b.runs.start(trigger, ScopeAll, queue)
+	run = b.runs.info(r)
+	if run.State == RunQueued && !joined {
+		b.publish(Event{Type: EventBackupQueued})
+	}
+
+	return
+}
+
+// Cancels running or queued backup, id 0 means the running one
+func (b *backuper) CancelBackup(runId int64) (run RunInfo, err error) {
+	run, err = b.runs.cancel(runId)
+	if err != nil {
+		return
+	}
+
+	if run.State == RunCancelling {
+		b.publish(Event{Type: EventBackupCancelling})
+	} else {
+		b.publish(Event{Type: EventBackupDequeued})
+	}
+
+	return
+}
+
+func (b *backuper) RunStatus() RunStatus {
+	return b.runs.status()
+}
+
+// Should only be called by the coordinator, ctx is cancelled when the run is cancelled
+func (b *backuper) backup(runCtx context.Context, scope Scope) (err error) {
 	var state backupState
 
 	ctx, cancel := context.WithTimeout(
-		context.Background(),
+		runCtx,
 		time.Duration(b.config.WorkerTimeoutSeconds)*time.Second)
 
 	state.ctx = ctx
+	state.scope = scope
 	defer cancel()
 
+	b.publish(Event{Type: EventBackupStarted})
+
 	st, err := b.auth.GetState()
 	if err != nil || !st.IsSet() {
+		b.publishError(Event{}, errors.New("backuper: user is not authenticated"))
+		b.publish(Event{Type: EventBackupFinished})
+		return
+	}
+
+	if scope == ScopeYoutube && st.YoutubeRefreshToken == "" {
+		log.Info().Msg("backuper: youtube account is not configured, skipping youtube backup")
+		b.publish(Event{Type: EventBackupFinished})
 		return
 	}
 
 	// Backup database entry is created inside backupSpotify which is not great.
 	// That means that Spotify part has to always run first and not continue if
-	// it fails.
+	// it fails. Youtube only backups are created for the authenticated Spotify user.
 	backupOk := true
-	err = b.backupSpotify(ctx, &state, &st)
-	if err != nil {
-		backupOk = false
+	if scope.covers(ScopeSpotify) {
+		err = b.backupSpotify(ctx, &state, &st)
+		if err != nil {
+			backupOk = false
+		}
+
+		if backupOk {
+			err = b.backupLibrary(ctx, &state)
+			if err != nil {
+				backupOk = false
+			}
+		}
+	} else {
+		var bp *Backup
+		bp, err = b.createBackup(st.User, scope)
+		if err != nil {
+			log.Error().Err(err).Msg("backuper: could not create backup entry")
+			backupOk = false
+		} else {
+			state.bp = bp
+		}
 	}
 
-	if backupOk {
+	if backupOk && scope.covers(ScopeYoutube) {
 		err = b.backupYoutube(ctx, &state, &st)
 		if err != nil {
 			backupOk = false
 		}
 	}
 
+	// Workers exit without errors once context is done, cancelled backup
+	// is incomplete, so it must not be treated as successful.
+	if runCtx.Err() != nil {
+		log.Warn().Msg("backuper: backup was cancelled")
+		backupOk = false
+		err = ErrBackupCancelled
+	}
+
+	finished := Event{Type: EventBackupFinished, Success: backupOk}
+	if err != nil {
+		finished.Error = err.Error()
+	}
+
+	// Backup entry is not created if Spotify user can't be loaded
+	if state.bp == nil {
+		b.publish(finished)
+		return
+	}
+
 	log.Info().Msgf("backuper: finished, is ok: %t", backupOk)
 	b.endBackup(state.bp, backupOk)
 
 	if backupOk {
 		// run actions on backup
-		p, t, yp, yt, err := b.repo.GetBackupData(state.bp)
+		p, t, yp, yt, l, err := b.repo.GetBackupData(state.bp)
 		if err != nil {
 			log.Error().Err(err).Msg("backuper: failed to get backup data")
+			b.publishError(Event{BackupId: state.bp.Id}, err)
 		} else {
+			d := NewDocument(state.bp, p, t, yp, yt, l)
 			for _, act := range b.actions {
-				err := act.Do(state.bp, p, t)
-				if err != nil {
-					log.Error().Err(err).Msg("backuper: failed to run post backup action")
-				}
-
-				err = act.DoYoutube(state.bp, yp, yt)
-				if err != nil {
-					log.Error().Err(err).Msg("backuper: failed to run post backup action for youtube")
+				actErr := b.runAction(act, state.bp, p, t, yp, yt, l, d)
+				ev := Event{Type: EventActionCompleted, BackupId: state.bp.Id, Action: actionName(act), Success: actErr == nil}
+				if actErr != nil {
+					ev.Error = actErr.Error()
 				}
+				b.publish(ev)
 			}
 		}
 	}
 
+	// failed backups are pruned as well, so that diagnostics don't pile up
+	if b.config.RetentionEnabled {
+		_, err := b.Prune(state.bp.UserId, b.config.RetentionDryRun)
+		if err != nil {
+			log.Error().Err(err).Msg("backuper: failed to apply retention policy")
+		}
+	}
+
+	// Published last so that action results are part of the backup
+	finished.BackupId = state.bp.Id
+	b.publish(finished)
+
 	return
 }
 
-// should be started as goroutine
-func (b *backuper) RunPeriodically(ctx context.Context) {
-	log.Info().Msg("backuper_periodic: started")
+// Only platforms covered by the backup are passed to the action, otherwise actions
+// would treat missing playlists of the other platform as deleted ones.
+// Returns the last error, failure of one part doesn't stop the others.
+func (b *backuper) runAction(act PostBackupAction, bp *Backup, p *[]Playlist, t *[]Track, yp *[]YoutubePlaylist, yt *[]YoutubeTrack, l *Library, d *Document) (actErr error) {
+	if bp.Covers(ScopeSpotify) {
+		err := act.Do(bp, p, t)
+		if err != nil {
+			actErr = err
+			log.Error().Err(err).Msg("backuper: failed to run post backup action")
+		}
+	}
 
-	for {
-		duration := time.Duration(b.config.RunIntervalSeconds) * time.Second
-		select {
-		case <-time.After(duration):
-			err := b.Backup()
-			if err != nil {
-				log.Error().Err(err).Msg("backuper_periodic: backup finished with errors")
-			}
-		case <-ctx.Done():
-			log.Debug().Msg("backuper_periodic: context finished, stopping backups")
-			return
+	if bp.Covers(ScopeYoutube) {
+		err := act.DoYoutube(bp, yp, yt)
+		if err != nil {
+			actErr = err
+			log.Error().Err(err).Msg("backuper: failed to run post backup action for youtube")
+		}
+	}
+
+	if bp.Covers(ScopeSpotify) {
+		err := act.DoLibrary(bp, l)
+		if err != nil {
+			actErr = err
+			log.Error().Err(err).Msg("backuper: failed to run post backup action for library")
 		}
 	}
+
+	// document has the scope, so it is stored for partial backups as well
+	if da, ok := act.(DocumentBackupAction); ok {
+		err := da.DoDocument(d)
+		if err != nil {
+			actErr = err
+			log.Error().Err(err).Msg("backuper: failed to run post backup action for document")
+		}
+	}
+
+	return
 }
@@ -0,0 +1,50 @@
+package backup
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Records which parts of a backup were passed to the action
+type recordingAction struct {
+	calls []string
+}
+
+func (a *recordingAction) Do(bp *Backup, p *[]Playlist, t *[]Track) error {
+	a.calls = append(a.calls, "spotify")
+	return nil
+}
+
+func (a *recordingAction) DoYoutube(bp *Backup, p *[]YoutubePlaylist, t *[]YoutubeTrack) error {
+	a.calls = append(a.calls, "youtube")
+	return nil
+}
+
+func (a *recordingAction) DoLibrary(bp *Backup, l *Library) error {
+	a.calls = append(a.calls, "library")
+	return nil
+}
+
+func (a *recordingAction) DoDocument(d *Document) error {
+	a.calls = append(a.calls, "document")
+	return nil
+}
+
+func TestRunActionScope(t *testing.T) {
+	b := &backuper{}
+	for _, tc := range []struct {
+		scope    Scope
+		expected []string
+	}{
+		{"", []string{"spotify", "youtube", "library", "document"}},
+		{ScopeAll, []string{"spotify", "youtube", "library", "document"}},
+		{ScopeSpotify, []string{"spotify", "library", "document"}},
+		{ScopeYoutube, []string{"youtube", "document"}},
+	} {
+		a := &recordingAction{}
+		err := b.runAction(a, &Backup{Scope: tc.scope}, &[]Playlist{}, &[]Track{}, &[]YoutubePlaylist{}, &[]YoutubeTrack{}, &Library{}, &Document{})
+		require.NoError(t, err)
+		require.Equal(t, tc.expected, a.calls, tc.scope)
+	}
+}
@@ -5,10 +5,22 @@ import "time"
 type Track struct {
 	Id                int64
 	SpotifyId         string
+	Uri               string
+	Isrc              string
 	Name              string
 	Artist            string
+	ArtistIds         []string
 	Album             string
+	AlbumArtist       string
+	AlbumReleaseDate  string
+	DurationMs        int
+	TrackNumber       int
+	DiscNumber        int
+	Explicit          bool
+	IsLocal           bool
+	AddedBy           string
 	AddedAtToPlaylist string // This might not exist (in Spotify)
+	Availability      Availability
 	Created           time.Time
 
 	// required when json format backup is written to create correlation
@@ -3,8 +3,15 @@ package backup
 import "time"
 
 type YoutubePlaylist struct {
-	Id        int64
-	YoutubeId string
-	Name      string
-	Created   time.Time
+	Id            int64
+	YoutubeId     string
+	Name          string
+	Description   string
+	ChannelId     string
+	ChannelTitle  string
+	PrivacyStatus string
+	CoverUrl      string
+	CoverBlob     string // Key of cover image in blob store, empty if it wasn't downloaded
+	Position      int    // Position of playlist in configured playlist ids
+	Created       time.Time
 }
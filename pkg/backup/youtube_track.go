@@ -6,10 +6,26 @@ type YoutubeTrack struct {
 	Id                int64
 	YoutubeId         string
 	Name              string
+	ChannelId         string
 	ChannelTitle      string
+	Description       string
+	Tags              []string
+	CategoryId        string
+	DurationSeconds   int
+	PublishedAt       string // When video was published, not when it was added to playlist
+	PrivacyStatus     string
+	Thumbnails        map[string]string
 	AddedAtToPlaylist string
+	Availability      Availability
 	Created           time.Time
 
+	// Only present for music with auto generated description
+	MusicTitle       string
+	MusicArtist      string
+	MusicAlbum       string
+	MusicLabel       string
+	MusicReleaseDate string
+
 	// required when json format backup is written to create correlation
 	PlaylistId int64
 }
@@ -0,0 +1,181 @@
+package backup
+
+import (
+	"strings"
+
+	gyoutube "google.golang.org/api/youtube/v3"
+)
+
+// Youtube doesn't allow requesting more videos in a single request
+const videoBatchSize = 50
+
+var youtubeVideoParts = []string{"snippet", "contentDetails", "status"}
+
+// Loads videos of playlist items in batches. Videos that are deleted or private
+// are not returned, so they are missing from the result.
+func (b *backuper) getYoutubeVideos(st *backupState, items []*gyoutube.PlaylistItem) (videos map[string]*gyoutube.Video, err error) {
+	videos = make(map[string]*gyoutube.Video)
+
+	var ids []string
+	for _, v := range items {
+		if v.ContentDetails != nil && v.ContentDetails.VideoId != "" {
+			ids = append(ids, v.ContentDetails.VideoId)
+		}
+	}
+
+	for start := 0; start < len(ids); start += videoBatchSize {
+		end := start + videoBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		resp, err := st.youtube.Videos.List(youtubeVideoParts).Id(ids[start:end]...).MaxResults(videoBatchSize).Do()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, v := range resp.Items {
+			videos[v.Id] = v
+		}
+	}
+
+	return
+}
+
+func youtubeThumbnails(t *gyoutube.ThumbnailDetails) (thumbnails map[string]string) {
+	if t == nil {
+		return
+	}
+
+	for name, v := range map[string]*gyoutube.Thumbnail{
+		"default":  t.Default,
+		"medium":   t.Medium,
+		"high":     t.High,
+		"standard": t.Standard,
+		"maxres":   t.Maxres,
+	} {
+		if v == nil || v.Url == "" {
+			continue
+		}
+
+		if thumbnails == nil {
+			thumbnails = make(map[string]string)
+		}
+		thumbnails[name] = v.Url
+	}
+
+	return
+}
+
+// Parses ISO 8601 duration as returned by Youtube, e.g. PT1H2M3S.
+// Videos never have years or months, so M is always minutes.
+func parseYoutubeDuration(d string) (seconds int) {
+	n := 0
+	for _, c := range d {
+		switch {
+		case c >= '0' && c <= '9':
+			n = n*10 + int(c-'0')
+		case c == 'D':
+			seconds += n * 24 * 60 * 60
+			n = 0
+		case c == 'H':
+			seconds += n * 60 * 60
+			n = 0
+		case c == 'M':
+			seconds += n * 60
+			n = 0
+		case c == 'S':
+			seconds += n
+			n = 0
+		default:
+			n = 0
+		}
+	}
+
+	return
+}
+
+type youtubeMusic struct {
+	Title       string
+	Artist      string
+	Album       string
+	Label       string
+	ReleaseDate string
+}
+
+const musicDescriptionPrefix = "Provided to YouTube by "
+
+// Music uploaded by labels has auto generated description:
+//
+//	Provided to YouTube by Label
+//
+//	Title · Artist · Other Artist
+//
+//	Album
+//
+//	...
+//
+//	Released on: 2020-01-01
+//
+// which is the only place where this metadata is available.
+func parseYoutubeMusic(description string) (m youtubeMusic, ok bool) {
+	if !strings.HasPrefix(description, musicDescriptionPrefix) {
+		return
+	}
+
+	parts := strings.Split(strings.ReplaceAll(description, "\r\n", "\n"), "\n\n")
+	if len(parts) < 3 {
+		return
+	}
+
+	m.Label = strings.TrimSpace(strings.TrimPrefix(parts[0], musicDescriptionPrefix))
+
+	names := strings.Split(strings.TrimSpace(parts[1]), " · ")
+	m.Title = names[0]
+	m.Artist = strings.Join(names[1:], ", ")
+	m.Album = strings.TrimSpace(parts[2])
+
+	for _, p := range parts[3:] {
+		p = strings.TrimSpace(p)
+		if strings.HasPrefix(p, "Released on: ") {
+			m.ReleaseDate = strings.TrimPrefix(p, "Released on: ")
+		}
+	}
+
+	return m, true
+}
+
+// Fills in values that are only available on the video
+func enrichYoutubeTrack(t *YoutubeTrack, v *gyoutube.Video) {
+	if v.Snippet != nil {
+		if t.ChannelId == "" {
+			t.ChannelId = v.Snippet.ChannelId
+		}
+
+		if t.ChannelTitle == "" {
+			t.ChannelTitle = v.Snippet.ChannelTitle
+		}
+
+		t.Description = v.Snippet.Description
+		t.Tags = v.Snippet.Tags
+		t.CategoryId = v.Snippet.CategoryId
+		t.PublishedAt = v.Snippet.PublishedAt
+		t.Thumbnails = youtubeThumbnails(v.Snippet.Thumbnails)
+
+		if m, ok := parseYoutubeMusic(v.Snippet.Description); ok {
+			t.MusicTitle = m.Title
+			t.MusicArtist = m.Artist
+			t.MusicAlbum = m.Album
+			t.MusicLabel = m.Label
+			t.MusicReleaseDate = m.ReleaseDate
+		}
+	}
+
+	if v.ContentDetails != nil {
+		t.DurationSeconds = parseYoutubeDuration(v.ContentDetails.Duration)
+	}
+
+	if v.Status != nil {
+		t.PrivacyStatus = v.Status.PrivacyStatus
+	}
+}
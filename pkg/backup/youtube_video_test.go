@@ -0,0 +1,78 @@
+package backup
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	gyoutube "google.golang.org/api/youtube/v3"
+)
+
+func TestParseYoutubeDuration(t *testing.T) {
+	require.Equal(t, 0, parseYoutubeDuration(""))
+	require.Equal(t, 0, parseYoutubeDuration("P0D"))
+	require.Equal(t, 45, parseYoutubeDuration("PT45S"))
+	require.Equal(t, 4*60+13, parseYoutubeDuration("PT4M13S"))
+	require.Equal(t, 60*60+2*60+3, parseYoutubeDuration("PT1H2M3S"))
+	require.Equal(t, 24*60*60+60*60, parseYoutubeDuration("P1DT1H"))
+}
+
+func TestParseYoutubeMusic(t *testing.T) {
+	_, ok := parseYoutubeMusic("Official video of a song")
+	require.False(t, ok)
+
+	description := "Provided to YouTube by Label Music\n\nSong · Artist · Other Artist\n\nAlbum\n\n℗ 2020 Label Music\n\nReleased on: 2020-01-02\n\nAuto-generated by YouTube."
+	m, ok := parseYoutubeMusic(description)
+	require.True(t, ok)
+	require.Equal(t, youtubeMusic{
+		Title:       "Song",
+		Artist:      "Artist, Other Artist",
+		Album:       "Album",
+		Label:       "Label Music",
+		ReleaseDate: "2020-01-02",
+	}, m)
+}
+
+func TestNewYoutubeTrackEnriched(t *testing.T) {
+	item := &gyoutube.PlaylistItem{
+		Snippet: &gyoutube.PlaylistItemSnippet{
+			Title:                  "Song",
+			PublishedAt:            "added",
+			VideoOwnerChannelId:    "C",
+			VideoOwnerChannelTitle: "Channel",
+		},
+		ContentDetails: &gyoutube.PlaylistItemContentDetails{VideoId: "V"},
+	}
+
+	video := &gyoutube.Video{
+		Id: "V",
+		Snippet: &gyoutube.VideoSnippet{
+			ChannelId:   "C",
+			Description: "Provided to YouTube by Label\n\nSong · Artist\n\nAlbum",
+			Tags:        []string{"a", "b"},
+			CategoryId:  "10",
+			PublishedAt: "published",
+			Thumbnails:  &gyoutube.ThumbnailDetails{High: &gyoutube.Thumbnail{Url: "high"}},
+		},
+		ContentDetails: &gyoutube.VideoContentDetails{Duration: "PT3M"},
+		Status:         &gyoutube.VideoStatus{PrivacyStatus: "public"},
+	}
+
+	tr := newYoutubeTrack(item, video)
+	require.Equal(t, "V", tr.YoutubeId)
+	require.Equal(t, "C", tr.ChannelId)
+	require.Equal(t, "Channel", tr.ChannelTitle)
+	require.Equal(t, "added", tr.AddedAtToPlaylist)
+	require.Equal(t, "published", tr.PublishedAt)
+	require.Equal(t, 180, tr.DurationSeconds)
+	require.Equal(t, []string{"a", "b"}, tr.Tags)
+	require.Equal(t, "10", tr.CategoryId)
+	require.Equal(t, "public", tr.PrivacyStatus)
+	require.Equal(t, map[string]string{"high": "high"}, tr.Thumbnails)
+	require.Equal(t, "Artist", tr.MusicArtist)
+	require.Equal(t, "Album", tr.MusicAlbum)
+	require.Equal(t, AvailabilityPlayable, tr.Availability)
+
+	tr = newYoutubeTrack(item, nil)
+	require.Equal(t, AvailabilityDeleted, tr.Availability)
+	require.Equal(t, 0, tr.DurationSeconds)
+}
@@ -0,0 +1,115 @@
+package blob
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path"
+)
+
+var (
+	ErrNotFound   = errors.New("blob: blob not found")
+	ErrInvalidKey = errors.New("blob: invalid key")
+)
+
+// Store keeps binary data addressed by sha256 of its content,
+// so the same data is only stored once.
+type Store interface {
+	Put(data []byte) (key string, err error)
+	Get(key string) ([]byte, error)
+	// Deleting missing blob is not an error
+	Delete(key string) error
+}
+
+type fileStore struct {
+	dir string
+}
+
+func NewFileStore(dir string) (Store, error) {
+	err := os.MkdirAll(dir, os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileStore{dir}, nil
+}
+
+func (s *fileStore) Put(data []byte) (key string, err error) {
+	sum := sha256.Sum256(data)
+	key = hex.EncodeToString(sum[:])
+	fpath := s.path(key)
+
+	_, err = os.Stat(fpath)
+	if err == nil {
+		return key, nil
+	}
+
+	err = os.MkdirAll(path.Dir(fpath), os.ModePerm)
+	if err != nil {
+		return
+	}
+
+	// written to temporary file first, so that a partially
+	// written blob never exists under its key
+	f, err := os.CreateTemp(path.Dir(fpath), "tempblob")
+	if err != nil {
+		return
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(data)
+	if err != nil {
+		f.Close()
+		return
+	}
+
+	err = f.Close()
+	if err != nil {
+		return
+	}
+
+	err = os.Rename(f.Name(), fpath)
+	return
+}
+
+func (s *fileStore) Get(key string) (data []byte, err error) {
+	if !isValidKey(key) {
+		return nil, ErrInvalidKey
+	}
+
+	data, err = os.ReadFile(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+
+	return
+}
+
+func (s *fileStore) Delete(key string) (err error) {
+	if !isValidKey(key) {
+		return ErrInvalidKey
+	}
+
+	err = os.Remove(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	return
+}
+
+// blobs are split into directories by first 2 characters of key,
+// to avoid having too many files in a single directory
+func (s *fileStore) path(key string) string {
+	return path.Join(s.dir, key[:2], key)
+}
+
+func isValidKey(key string) bool {
+	if len(key) != sha256.Size*2 {
+		return false
+	}
+
+	_, err := hex.DecodeString(key)
+	return err == nil
+}
@@ -0,0 +1,68 @@
+package blob
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPutGet(t *testing.T) {
+	s, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+
+	key, err := s.Put([]byte("data"))
+	require.NoError(t, err)
+	require.Len(t, key, 64)
+
+	data, err := s.Get(key)
+	require.NoError(t, err)
+	require.Equal(t, []byte("data"), data)
+}
+
+func TestPutDeduplicates(t *testing.T) {
+	s, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+
+	k1, err := s.Put([]byte("data"))
+	require.NoError(t, err)
+
+	k2, err := s.Put([]byte("data"))
+	require.NoError(t, err)
+	require.Equal(t, k1, k2)
+
+	k3, err := s.Put([]byte("other"))
+	require.NoError(t, err)
+	require.NotEqual(t, k1, k3)
+}
+
+func TestGetMissing(t *testing.T) {
+	s, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+
+	_, err = s.Get("0000000000000000000000000000000000000000000000000000000000000000")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	_, err = s.Get("../../etc/passwd")
+	require.ErrorIs(t, err, ErrInvalidKey)
+}
+
+func TestDelete(t *testing.T) {
+	s, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+
+	key, err := s.Put([]byte("data"))
+	require.NoError(t, err)
+
+	err = s.Delete(key)
+	require.NoError(t, err)
+
+	_, err = s.Get(key)
+	require.ErrorIs(t, err, ErrNotFound)
+
+	// already deleted
+	err = s.Delete(key)
+	require.NoError(t, err)
+
+	err = s.Delete("../../etc/passwd")
+	require.ErrorIs(t, err, ErrInvalidKey)
+}
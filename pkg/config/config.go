@@ -7,42 +7,131 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
+	"time"
 
+	"github.com/hoffs/crispy-musicular/pkg/schedule"
 	"gopkg.in/yaml.v3"
 )
 
 type AppConfig struct {
-	RunIntervalSeconds      uint64   `yaml:"runIntervalSeconds"`
-	Port                    uint32   `yaml:"port"`
-	SpotifyCallback         string   `yaml:"spotifyCallback"`
-	WorkerCount             uint8    `yaml:"workerCount"`
-	WorkerTimeoutSeconds    uint32   `yaml:"workerTimeoutSeconds"`
-	SavedPlaylistIds        []string `yaml:"savedPlaylistIds"`
-	IgnoredPlaylistIds      []string `yaml:"ignoredPlaylistIds"`
-	IgnoreNotOwnedPlaylists bool     `yaml:"ignoreNotOwnedPlaylists"`
-	IgnoreOwnedPlaylists    bool     `yaml:"ignoreOwnedPlaylists"`
-	DbPath                  string   `yaml:"dbPath"`
-	SpotifyId               string   `yaml:"-"`
-	SpotifySecret           string   `yaml:"-"`
-	path                    string   `yaml:"-"`
-	JsonActionEnabled       bool     `yaml:"jsonActionEnabled"`
-	JsonDir                 string   `yaml:"jsonDir"`
-	DriveActionEnabled      bool     `yaml:"driveActionEnabled"`
-	DriveCallback           string   `yaml:"driveCallback"`
-	DriveId                 string   `yaml:"-"`
-	DriveSecret             string   `yaml:"-"`
-	DriveDir                string   `yaml:"driveDir"`
-	YoutubeSavedPlaylistIds []string `yaml:"youtubeSavedPlaylistIds"`
-	YoutubeCallback         string   `yaml:"youtubeCallback"`
-	YoutubeId               string   `yaml:"-"`
-	YoutubeSecret           string   `yaml:"-"`
+	RunIntervalSeconds             uint64   `yaml:"runIntervalSeconds"`
+	SpotifySchedule                string   `yaml:"spotifySchedule"`
+	YoutubeSchedule                string   `yaml:"youtubeSchedule"`
+	ScheduleTimezone               string   `yaml:"scheduleTimezone"`
+	ScheduleJitterSeconds          uint32   `yaml:"scheduleJitterSeconds"`
+	QuietHours                     []string `yaml:"quietHours"`
+	Port                           uint32   `yaml:"port"`
+	SpotifyCallback                string   `yaml:"spotifyCallback"`
+	WorkerCount                    uint8    `yaml:"workerCount"`
+	WorkerTimeoutSeconds           uint32   `yaml:"workerTimeoutSeconds"`
+	SavedPlaylistIds               []string `yaml:"savedPlaylistIds"`
+	IgnoredPlaylistIds             []string `yaml:"ignoredPlaylistIds"`
+	IgnoreNotOwnedPlaylists        bool     `yaml:"ignoreNotOwnedPlaylists"`
+	IgnoreOwnedPlaylists           bool     `yaml:"ignoreOwnedPlaylists"`
+	DbPath                         string   `yaml:"dbPath"`
+	SpotifyId                      string   `yaml:"-"`
+	SpotifySecret                  string   `yaml:"-"`
+	path                           string   `yaml:"-"`
+	JsonActionEnabled              bool     `yaml:"jsonActionEnabled"`
+	JsonDir                        string   `yaml:"jsonDir"`
+	JsonLegacyFormat               bool     `yaml:"jsonLegacyFormat"`
+	CsvActionEnabled               bool     `yaml:"csvActionEnabled"`
+	CsvDir                         string   `yaml:"csvDir"`
+	PlaylistFileActionEnabled      bool     `yaml:"playlistFileActionEnabled"`
+	PlaylistFileM3uEnabled         bool     `yaml:"playlistFileM3uEnabled"`
+	PlaylistFileDir                string   `yaml:"playlistFileDir"`
+	S3ActionEnabled                bool     `yaml:"s3ActionEnabled"`
+	S3Endpoint                     string   `yaml:"s3Endpoint"`
+	S3Region                       string   `yaml:"s3Region"`
+	S3Bucket                       string   `yaml:"s3Bucket"`
+	S3Prefix                       string   `yaml:"s3Prefix"`
+	S3PathStyle                    bool     `yaml:"s3PathStyle"`
+	S3AccessKeyId                  string   `yaml:"-"`
+	S3SecretAccessKey              string   `yaml:"-"`
+	WebdavActionEnabled            bool     `yaml:"webdavActionEnabled"`
+	WebdavUrl                      string   `yaml:"webdavUrl"`
+	WebdavDir                      string   `yaml:"webdavDir"`
+	WebdavUser                     string   `yaml:"-"`
+	WebdavPassword                 string   `yaml:"-"`
+	SftpActionEnabled              bool     `yaml:"sftpActionEnabled"`
+	SftpAddress                    string   `yaml:"sftpAddress"`
+	SftpHostKey                    string   `yaml:"sftpHostKey"`
+	SftpDir                        string   `yaml:"sftpDir"`
+	SftpUser                       string   `yaml:"-"`
+	SftpPassword                   string   `yaml:"-"`
+	SftpPrivateKeyPath             string   `yaml:"-"`
+	GitActionEnabled               bool     `yaml:"gitActionEnabled"`
+	GitDir                         string   `yaml:"gitDir"`
+	GitRemoteUrl                   string   `yaml:"gitRemoteUrl"`
+	GitUser                        string   `yaml:"-"`
+	GitPassword                    string   `yaml:"-"`
+	DriveActionEnabled             bool     `yaml:"driveActionEnabled"`
+	DriveCallback                  string   `yaml:"driveCallback"`
+	DriveId                        string   `yaml:"-"`
+	DriveSecret                    string   `yaml:"-"`
+	DriveDir                       string   `yaml:"driveDir"`
+	DriveSkipUnchanged             bool     `yaml:"driveSkipUnchanged"`
+	DriveRetentionEnabled          bool     `yaml:"driveRetentionEnabled"`
+	DriveRetentionKeepLast         int      `yaml:"driveRetentionKeepLast"`
+	DriveRetentionKeepDailyDays    int      `yaml:"driveRetentionKeepDailyDays"`
+	DriveRetentionKeepWeeklyWeeks  int      `yaml:"driveRetentionKeepWeeklyWeeks"`
+	DriveRetentionKeepMonthly      bool     `yaml:"driveRetentionKeepMonthly"`
+	YoutubeSavedPlaylistIds        []string `yaml:"youtubeSavedPlaylistIds"`
+	YoutubeIgnoredPlaylistIds      []string `yaml:"youtubeIgnoredPlaylistIds"`
+	YoutubeMinePlaylistsEnabled    bool     `yaml:"youtubeMinePlaylistsEnabled"`
+	YoutubeIgnoreNotOwnedPlaylists bool     `yaml:"youtubeIgnoreNotOwnedPlaylists"`
+	YoutubeIgnoreOwnedPlaylists    bool     `yaml:"youtubeIgnoreOwnedPlaylists"`
+	YoutubeCallback                string   `yaml:"youtubeCallback"`
+	YoutubeId                      string   `yaml:"-"`
+	YoutubeSecret                  string   `yaml:"-"`
+	SpotifyRestoreEnabled          bool     `yaml:"spotifyRestoreEnabled"`
+	ReuseSpotifySnapshots          bool     `yaml:"reuseSpotifySnapshots"`
+	SpotifyLikedSongsEnabled       bool     `yaml:"spotifyLikedSongsEnabled"`
+	SpotifySavedAlbumsEnabled      bool     `yaml:"spotifySavedAlbumsEnabled"`
+	SpotifyFollowedArtistsEnabled  bool     `yaml:"spotifyFollowedArtistsEnabled"`
+	SpotifySavedShowsEnabled       bool     `yaml:"spotifySavedShowsEnabled"`
+	CoverImagesEnabled             bool     `yaml:"coverImagesEnabled"`
+	ArtifactCompression            string   `yaml:"artifactCompression"`
+	ArtifactAgeRecipients          []string `yaml:"artifactAgeRecipients"`
+	ArtifactPassphrase             string   `yaml:"-"`
+	ArtifactIdentityFile           string   `yaml:"-"`
+	RetentionEnabled               bool     `yaml:"retentionEnabled"`
+	RetentionDryRun                bool     `yaml:"retentionDryRun"`
+	RetentionKeepLast              int      `yaml:"retentionKeepLast"`
+	RetentionKeepDailyDays         int      `yaml:"retentionKeepDailyDays"`
+	RetentionKeepWeeklyWeeks       int      `yaml:"retentionKeepWeeklyWeeks"`
+	RetentionKeepMonthly           bool     `yaml:"retentionKeepMonthly"`
+	RetentionKeepFailedDays        int      `yaml:"retentionKeepFailedDays"`
+	BlobDir                        string   `yaml:"blobDir"`
 }
 
 func (c *AppConfig) validate() error {
-	if c.RunIntervalSeconds == 0 {
+	// interval is only used when there is no schedule
+	if c.RunIntervalSeconds == 0 && c.SpotifySchedule == "" {
 		return errors.New("appconfig: RunIntervalSeconds must be configured and more than 0")
 	}
 
+	loc, err := c.ScheduleLocation()
+	if err != nil {
+		return fmt.Errorf("appconfig: ScheduleTimezone is invalid: %w", err)
+	}
+
+	for _, expr := range []string{c.SpotifySchedule, c.YoutubeSchedule} {
+		if expr == "" {
+			continue
+		}
+
+		_, err = schedule.Parse(expr, loc)
+		if err != nil {
+			return fmt.Errorf("appconfig: invalid schedule: %w", err)
+		}
+	}
+
+	_, err = schedule.ParseWindows(c.QuietHours)
+	if err != nil {
+		return fmt.Errorf("appconfig: invalid QuietHours: %w", err)
+	}
+
 	if c.Port == 0 {
 		return errors.New("appconfig: Port must be configured")
 	}
@@ -70,6 +159,15 @@ func (c *AppConfig) validate() error {
 	return nil
 }
 
+// Empty timezone means local time
+func (c *AppConfig) ScheduleLocation() (*time.Location, error) {
+	if c.ScheduleTimezone == "" {
+		return time.Local, nil
+	}
+
+	return time.LoadLocation(c.ScheduleTimezone)
+}
+
 type ConfigLoadError struct {
 	Path string
 	Err  error
@@ -81,13 +179,31 @@ func (e *ConfigLoadError) Error() string {
 
 func Load(path string) (*AppConfig, error) {
 	c := &AppConfig{
-		IgnoreNotOwnedPlaylists: true,
-		path:                    path,
-		JsonDir:                 "json/",
-		DbPath:                  "db/data.db",
-		DriveDir:                "crispy_spotify_backups",
-		JsonActionEnabled:       false,
-		DriveActionEnabled:      false,
+		IgnoreNotOwnedPlaylists:       true,
+		path:                          path,
+		JsonDir:                       "json/",
+		CsvDir:                        "csv/",
+		PlaylistFileDir:               "playlists/",
+		DbPath:                        "db/data.db",
+		DriveDir:                      "crispy_spotify_backups",
+		S3Region:                      "us-east-1",
+		S3Prefix:                      "crispy_spotify_backups",
+		WebdavDir:                     "crispy_spotify_backups",
+		SftpDir:                       "crispy_spotify_backups",
+		GitDir:                        "git/",
+		RetentionKeepLast:             10,
+		RetentionKeepDailyDays:        14,
+		RetentionKeepWeeklyWeeks:      8,
+		RetentionKeepMonthly:          true,
+		RetentionKeepFailedDays:       7,
+		DriveRetentionKeepLast:        10,
+		DriveRetentionKeepDailyDays:   14,
+		DriveRetentionKeepWeeklyWeeks: 8,
+		DriveRetentionKeepMonthly:     true,
+		BlobDir:                       "db/blobs",
+		JsonActionEnabled:             false,
+		CsvActionEnabled:              false,
+		DriveActionEnabled:            false,
 	}
 
 	err := loadYaml(c)
@@ -130,6 +246,17 @@ func loadEnv(c *AppConfig) {
 	c.DriveSecret = os.Getenv("DRIVE_SECRET")
 	c.YoutubeId = os.Getenv("YOUTUBE_ID")
 	c.YoutubeSecret = os.Getenv("YOUTUBE_SECRET")
+	c.S3AccessKeyId = os.Getenv("S3_ACCESS_KEY_ID")
+	c.S3SecretAccessKey = os.Getenv("S3_SECRET_ACCESS_KEY")
+	c.WebdavUser = os.Getenv("WEBDAV_USER")
+	c.WebdavPassword = os.Getenv("WEBDAV_PASSWORD")
+	c.SftpUser = os.Getenv("SFTP_USER")
+	c.SftpPassword = os.Getenv("SFTP_PASSWORD")
+	c.SftpPrivateKeyPath = os.Getenv("SFTP_PRIVATE_KEY_PATH")
+	c.GitUser = os.Getenv("GIT_USER")
+	c.GitPassword = os.Getenv("GIT_PASSWORD")
+	c.ArtifactPassphrase = os.Getenv("ARTIFACT_PASSPHRASE")
+	c.ArtifactIdentityFile = os.Getenv("ARTIFACT_IDENTITY_FILE")
 }
 
 // doesn't reload ENV based config values
@@ -175,6 +302,11 @@ func (c *AppConfig) Update(newConf *AppConfig) (err error) {
 
 func applyChangeableValues(from *AppConfig, to *AppConfig) {
 	to.RunIntervalSeconds = from.RunIntervalSeconds
+	to.SpotifySchedule = from.SpotifySchedule
+	to.YoutubeSchedule = from.YoutubeSchedule
+	to.ScheduleTimezone = from.ScheduleTimezone
+	to.ScheduleJitterSeconds = from.ScheduleJitterSeconds
+	to.QuietHours = from.QuietHours
 	to.WorkerCount = from.WorkerCount
 	to.WorkerTimeoutSeconds = from.WorkerTimeoutSeconds
 	to.SavedPlaylistIds = from.SavedPlaylistIds
@@ -182,6 +314,15 @@ func applyChangeableValues(from *AppConfig, to *AppConfig) {
 	to.IgnoreNotOwnedPlaylists = from.IgnoreNotOwnedPlaylists
 	to.IgnoreOwnedPlaylists = from.IgnoreOwnedPlaylists
 	to.YoutubeSavedPlaylistIds = from.YoutubeSavedPlaylistIds
+	to.YoutubeIgnoredPlaylistIds = from.YoutubeIgnoredPlaylistIds
+	to.YoutubeMinePlaylistsEnabled = from.YoutubeMinePlaylistsEnabled
+	to.YoutubeIgnoreNotOwnedPlaylists = from.YoutubeIgnoreNotOwnedPlaylists
+	to.YoutubeIgnoreOwnedPlaylists = from.YoutubeIgnoreOwnedPlaylists
+	to.ReuseSpotifySnapshots = from.ReuseSpotifySnapshots
+	to.SpotifyLikedSongsEnabled = from.SpotifyLikedSongsEnabled
+	to.SpotifySavedAlbumsEnabled = from.SpotifySavedAlbumsEnabled
+	to.SpotifyFollowedArtistsEnabled = from.SpotifyFollowedArtistsEnabled
+	to.SpotifySavedShowsEnabled = from.SpotifySavedShowsEnabled
 }
 
 // persists config on disk in multiple stages
@@ -62,6 +62,47 @@ func TestLoadConfigInvalidValues(t *testing.T) {
 	require.Contains(t, err.Error(), "appconfig: RunIntervalSeconds must be configured and more than 0")
 }
 
+var config_file_schedule = `
+port: 1337
+spotifyCallback: http://localhost:1337
+workerCount: 12
+workerTimeoutSeconds: 500
+spotifySchedule: "0 3 * * *"
+`
+
+func TestLoadConfigSchedule(t *testing.T) {
+	f, err := ioutil.TempFile("", "testconf")
+	require.NoError(t, err)
+
+	defer os.Remove(f.Name())
+	ioutil.WriteFile(f.Name(), []byte(config_file_schedule+`
+youtubeSchedule: "@weekly"
+scheduleTimezone: Europe/Vilnius
+quietHours: ["22:00-07:00"]
+`), fs.ModeAppend)
+
+	os.Setenv("SPOTIFY_ID", "AA")
+	os.Setenv("SPOTIFY_SECRET", "BB")
+	config, err := Load(f.Name())
+
+	// interval is not required with a schedule
+	require.NoError(t, err)
+	loc, err := config.ScheduleLocation()
+	require.NoError(t, err)
+	require.Equal(t, "Europe/Vilnius", loc.String())
+
+	for value, msg := range map[string]string{
+		"youtubeSchedule: \"0 25 * * *\"": "appconfig: invalid schedule",
+		"scheduleTimezone: Mars/Olympus":  "appconfig: ScheduleTimezone is invalid",
+		"quietHours: [\"22:00\"]":         "appconfig: invalid QuietHours",
+	} {
+		ioutil.WriteFile(f.Name(), []byte(config_file_schedule+value+"\n"), fs.ModeAppend)
+		_, err = Load(f.Name())
+		require.Error(t, err)
+		require.Contains(t, err.Error(), msg)
+	}
+}
+
 var config_file_updated = `
 runIntervalSeconds: 50
 port: 2337
@@ -17,7 +17,7 @@ import (
 func RegisterHandlers(c *config.AppConfig, auth auth.Service, b backup.Service) error {
 	h := &httpHandler{
 		auth:        auth,
-		spotAuth:    spotify.NewAuthenticator(c.SpotifyCallback, spotify.ScopePlaylistReadPrivate),
+		spotAuth:    spotify.NewAuthenticator(c.SpotifyCallback, spotifyScopes(c)...),
 		driveAuth:   drive.NewAuthenticator(c.DriveId, c.DriveSecret, c.DriveCallback),
 		youtubeAuth: youtube.NewAuthenticator(c.YoutubeId, c.YoutubeSecret, c.YoutubeCallback),
 		backuper:    b,
@@ -33,12 +33,22 @@ func RegisterHandlers(c *config.AppConfig, auth auth.Service, b backup.Service)
 
 	http.HandleFunc("/home", methodGuard(http.MethodGet, h.authGuard(h.homeHandler)))
 	http.HandleFunc("/backup/start", methodGuard(http.MethodPost, h.authGuard(h.backupStartHandler)))
+	http.HandleFunc("/backup/restore", methodGuard(http.MethodPost, h.authGuard(h.backupRestoreHandler)))
+	http.HandleFunc("/backup/import", methodGuard(http.MethodPost, h.authGuard(h.backupImportHandler)))
+	http.HandleFunc("/backup/cancel", methodGuard(http.MethodPost, h.authGuard(h.backupCancelHandler)))
+	http.HandleFunc("/backup/events", methodGuard(http.MethodGet, h.authGuard(h.backupEventsHandler)))
+
+	http.HandleFunc("/backups", methodGuard(http.MethodGet, h.authGuard(h.backupsHandler)))
+	http.HandleFunc("/backups/backup", methodGuard(http.MethodGet, h.authGuard(h.backupHandler)))
+	http.HandleFunc("/backups/playlist", methodGuard(http.MethodGet, h.authGuard(h.playlistHandler)))
 
 	http.HandleFunc("/config", methodGuard(http.MethodGet, h.authGuard(h.configHandler)))
 	http.HandleFunc("/config/edit", methodGuard(http.MethodGet, h.authGuard(h.editConfigHandler)))
 	http.HandleFunc("/config/edit/save", methodGuard(http.MethodPost, h.authGuard(h.saveConfigHandler)))
 	http.HandleFunc("/config/reload", methodGuard(http.MethodPost, h.authGuard(h.reloadConfigHandler)))
 
+	http.HandleFunc(apiPrefix, h.apiGuard(h.apiHandler))
+
 	http.HandleFunc("/drive/auth", methodGuard(http.MethodGet, h.authGuard(h.driveAuthHandler)))
 	http.HandleFunc("/drive/callback", methodGuard(http.MethodGet, h.authGuard(h.driveCallbackHandler)))
 
@@ -48,6 +58,19 @@ func RegisterHandlers(c *config.AppConfig, auth auth.Service, b backup.Service)
 	return http.ListenAndServe(fmt.Sprintf(":%d", c.Port), nil)
 }
 
+// Write access is only requested if restoring is explicitly enabled,
+// already authenticated user has to authenticate again after enabling it.
+func spotifyScopes(c *config.AppConfig) []string {
+	// Library scopes are always requested, because saving library can be enabled
+	// without restarting, but authorization scopes can't be changed afterwards.
+	scopes := []string{spotify.ScopePlaylistReadPrivate, spotify.ScopeUserLibraryRead, spotify.ScopeUserFollowRead}
+	if c.SpotifyRestoreEnabled {
+		scopes = append(scopes, spotify.ScopePlaylistModifyPrivate, spotify.ScopePlaylistModifyPublic, spotify.ScopeImageUpload)
+	}
+
+	return scopes
+}
+
 type httpHandler struct {
 	auth         auth.Service
 	spotAuth     spotify.Authenticator
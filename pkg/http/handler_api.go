@@ -0,0 +1,446 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hoffs/crispy-musicular/pkg/auth"
+	"github.com/hoffs/crispy-musicular/pkg/backup"
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+)
+
+// JSON API for scripts and dashboards. Instead of the browser cookie it is
+// authenticated by api tokens, which are managed with the command line.
+const apiPrefix = "/api/v1/"
+
+const (
+	apiDefaultPerPage = 50
+	apiMaxPerPage     = 500
+)
+
+type apiRoute struct {
+	method string
+	// "*" matches any single path segment, which is passed to the handler
+	pattern []string
+	handler func(w http.ResponseWriter, r *http.Request, params []string)
+}
+
+func (h *httpHandler) apiRoutes() []apiRoute {
+	return []apiRoute{
+		{http.MethodGet, []string{"backups"}, h.apiListBackupsHandler},
+		{http.MethodPost, []string{"backups"}, h.apiStartBackupHandler},
+		{http.MethodGet, []string{"backups", "*"}, h.apiBackupHandler},
+		{http.MethodGet, []string{"backups", "*", "playlists", "*", "*"}, h.apiPlaylistHandler},
+		{http.MethodGet, []string{"runs"}, h.apiRunsHandler},
+		{http.MethodDelete, []string{"runs", "*"}, h.apiCancelRunHandler},
+		{http.MethodGet, []string{"stats"}, h.apiStatsHandler},
+		{http.MethodGet, []string{"config"}, h.apiConfigHandler},
+	}
+}
+
+func (h *httpHandler) apiHandler(w http.ResponseWriter, r *http.Request) {
+	path := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, apiPrefix), "/"), "/")
+
+	methodMismatch := false
+	for _, route := range h.apiRoutes() {
+		params, ok := matchApiRoute(route.pattern, path)
+		if !ok {
+			continue
+		}
+
+		if route.method != r.Method {
+			methodMismatch = true
+			continue
+		}
+
+		route.handler(w, r, params)
+		return
+	}
+
+	if methodMismatch {
+		writeApiError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	writeApiError(w, http.StatusNotFound, "not found")
+}
+
+func matchApiRoute(pattern []string, path []string) (params []string, ok bool) {
+	if len(pattern) != len(path) {
+		return nil, false
+	}
+
+	for id, p := range pattern {
+		if p == "*" {
+			params = append(params, path[id])
+		} else if p != path[id] {
+			return nil, false
+		}
+	}
+
+	return params, true
+}
+
+func (h *httpHandler) apiGuard(handler func(http.ResponseWriter, *http.Request)) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if !strings.HasPrefix(header, "Bearer ") {
+			writeApiError(w, http.StatusUnauthorized, "api token must be provided in Authorization header as a Bearer token")
+			return
+		}
+
+		_, err := h.auth.VerifyApiToken(strings.TrimPrefix(header, "Bearer "))
+		if errors.Is(err, auth.ErrInvalidApiToken) {
+			log.Debug().Msg("http_api: received request with invalid api token")
+			writeApiError(w, http.StatusUnauthorized, "invalid api token")
+			return
+		}
+
+		if err != nil {
+			log.Error().Err(err).Msg("http_api: failed to verify api token")
+			writeApiError(w, http.StatusInternalServerError, "failed to verify api token")
+			return
+		}
+
+		handler(w, r)
+	}
+}
+
+func writeApiJson(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		log.Error().Err(err).Msg("http_api: failed to write response")
+	}
+}
+
+func writeApiError(w http.ResponseWriter, status int, msg string) {
+	writeApiJson(w, status, &struct {
+		Error string `json:"error"`
+	}{msg})
+}
+
+// Writes response for errors returned by services
+func writeApiServiceError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, backup.ErrBackupNotFound):
+		writeApiError(w, http.StatusNotFound, "backup not found")
+	case errors.Is(err, backup.ErrPlaylistNotFound):
+		writeApiError(w, http.StatusNotFound, "playlist not found")
+	case errors.Is(err, backup.ErrUnknownPlatform):
+		writeApiError(w, http.StatusBadRequest, "platform must be spotify or youtube")
+	default:
+		log.Error().Err(err).Msg("http_api: request failed")
+		writeApiError(w, http.StatusInternalServerError, "internal error")
+	}
+}
+
+type apiPagination struct {
+	Page    int   `json:"page"`
+	PerPage int   `json:"perPage"`
+	Total   int64 `json:"total"`
+}
+
+// Page numbers start at 1
+func parseApiPage(r *http.Request) (p apiPagination, err error) {
+	p = apiPagination{Page: 1, PerPage: apiDefaultPerPage}
+	if v := r.URL.Query().Get("page"); v != "" {
+		p.Page, err = strconv.Atoi(v)
+		if err != nil || p.Page < 1 {
+			return p, errors.New("page must be a positive number")
+		}
+	}
+
+	if v := r.URL.Query().Get("perPage"); v != "" {
+		p.PerPage, err = strconv.Atoi(v)
+		if err != nil || p.PerPage < 1 || p.PerPage > apiMaxPerPage {
+			return p, errors.New("perPage must be between 1 and " + strconv.Itoa(apiMaxPerPage))
+		}
+	}
+
+	return
+}
+
+func (p apiPagination) offset() int {
+	return (p.Page - 1) * p.PerPage
+}
+
+// Uses authenticated Spotify user unless user is provided in the query
+func (h *httpHandler) apiUser(r *http.Request) (string, error) {
+	if v := r.URL.Query().Get("user"); v != "" {
+		return v, nil
+	}
+
+	st, err := h.auth.GetState()
+	return st.User, err
+}
+
+type apiBackup struct {
+	Id       int64        `json:"id"`
+	UserId   string       `json:"userId"`
+	Scope    backup.Scope `json:"scope"`
+	Success  bool         `json:"success"`
+	Started  time.Time    `json:"started"`
+	Finished *time.Time   `json:"finished"`
+}
+
+func newApiBackup(b *backup.Backup) apiBackup {
+	v := apiBackup{Id: b.Id, UserId: b.UserId, Scope: b.Scope, Success: b.Success, Started: b.Started}
+	if !b.Finished.IsZero() {
+		v.Finished = &b.Finished
+	}
+
+	return v
+}
+
+type apiBackupSummary struct {
+	apiBackup
+	PlaylistCount int64 `json:"playlistCount"`
+	TrackCount    int64 `json:"trackCount"`
+}
+
+type apiPlaylist struct {
+	Platform    string `json:"platform"`
+	Id          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Owner       string `json:"owner"`
+	CoverUrl    string `json:"coverUrl"`
+	Position    int    `json:"position"`
+	TrackCount  int64  `json:"trackCount"`
+}
+
+func newApiPlaylist(p *backup.PlaylistSummary) apiPlaylist {
+	return apiPlaylist{p.Platform, p.Id, p.Name, p.Description, p.Owner, p.CoverUrl, p.Position, p.TrackCount}
+}
+
+func (h *httpHandler) apiListBackupsHandler(w http.ResponseWriter, r *http.Request, params []string) {
+	page, err := parseApiPage(r)
+	if err != nil {
+		writeApiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	user, err := h.apiUser(r)
+	if err != nil {
+		writeApiServiceError(w, err)
+		return
+	}
+
+	res, err := h.backuper.ListBackups(user, page.offset(), page.PerPage)
+	if err != nil {
+		writeApiServiceError(w, err)
+		return
+	}
+
+	page.Total = res.Total
+	backups := make([]apiBackupSummary, 0, len(res.Backups))
+	for _, v := range res.Backups {
+		backups = append(backups, apiBackupSummary{newApiBackup(&v.Backup), v.PlaylistCount, v.TrackCount})
+	}
+
+	writeApiJson(w, http.StatusOK, &struct {
+		apiPagination
+		Backups []apiBackupSummary `json:"backups"`
+	}{page, backups})
+}
+
+// Joins the running backup unless queue=true is provided
+func (h *httpHandler) apiStartBackupHandler(w http.ResponseWriter, r *http.Request, params []string) {
+	run, joined := h.backuper.StartBackup(backup.TriggerApi, r.URL.Query().Get("queue") == "true")
+
+	status := "started"
+	if run.State == backup.RunQueued {
+		status = "queued"
+	} else if joined {
+		status = "joined"
+	}
+
+	writeApiJson(w, http.StatusAccepted, &struct {
+		Status string         `json:"status"`
+		Run    backup.RunInfo `json:"run"`
+	}{status, run})
+}
+
+func (h *httpHandler) apiRunsHandler(w http.ResponseWriter, r *http.Request, params []string) {
+	schedules, err := h.backuper.GetScheduledRuns()
+	if err != nil {
+		writeApiServiceError(w, err)
+		return
+	}
+
+	writeApiJson(w, http.StatusOK, &struct {
+		backup.RunStatus
+		Progress  backup.Progress        `json:"progress"`
+		Scheduled *[]backup.ScheduledRun `json:"scheduled"`
+	}{h.backuper.RunStatus(), h.backuper.Events().Progress(), schedules})
+}
+
+// Run id can be "current" to cancel the running backup
+func (h *httpHandler) apiCancelRunHandler(w http.ResponseWriter, r *http.Request, params []string) {
+	var id int64
+	if params[0] != "current" {
+		var err error
+		id, err = strconv.ParseInt(params[0], 10, 64)
+		if err != nil || id < 1 {
+			writeApiError(w, http.StatusBadRequest, "run id must be a positive number or current")
+			return
+		}
+	}
+
+	run, err := h.backuper.CancelBackup(id)
+	if errors.Is(err, backup.ErrRunNotFound) {
+		writeApiError(w, http.StatusNotFound, "run is not active or queued")
+		return
+	}
+
+	if err != nil {
+		writeApiServiceError(w, err)
+		return
+	}
+
+	writeApiJson(w, http.StatusAccepted, &run)
+}
+
+func (h *httpHandler) apiBackupHandler(w http.ResponseWriter, r *http.Request, params []string) {
+	id, err := strconv.ParseInt(params[0], 10, 64)
+	if err != nil {
+		writeApiError(w, http.StatusBadRequest, "backup id must be a number")
+		return
+	}
+
+	page, err := parseApiPage(r)
+	if err != nil {
+		writeApiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	b, res, err := h.backuper.GetBackupPlaylists(id, page.offset(), page.PerPage)
+	if err != nil {
+		writeApiServiceError(w, err)
+		return
+	}
+
+	page.Total = res.Total
+	playlists := make([]apiPlaylist, 0, len(res.Playlists))
+	for _, v := range res.Playlists {
+		playlists = append(playlists, newApiPlaylist(&v))
+	}
+
+	writeApiJson(w, http.StatusOK, &struct {
+		apiPagination
+		Backup    apiBackup     `json:"backup"`
+		Playlists []apiPlaylist `json:"playlists"`
+	}{page, newApiBackup(b), playlists})
+}
+
+// Tracks use the same format as backup documents
+func (h *httpHandler) apiPlaylistHandler(w http.ResponseWriter, r *http.Request, params []string) {
+	id, err := strconv.ParseInt(params[0], 10, 64)
+	if err != nil {
+		writeApiError(w, http.StatusBadRequest, "backup id must be a number")
+		return
+	}
+
+	page, err := parseApiPage(r)
+	if err != nil {
+		writeApiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res, err := h.backuper.GetPlaylistTracks(id, params[1], params[2], page.offset(), page.PerPage)
+	if err != nil {
+		writeApiServiceError(w, err)
+		return
+	}
+
+	page.Total = res.Playlist.TrackCount
+	var tracks interface{}
+	if res.Tracks != nil {
+		t := make([]backup.DocumentTrack, 0, len(*res.Tracks))
+		for _, v := range *res.Tracks {
+			t = append(t, backup.NewDocumentTrack(&v))
+		}
+		tracks = t
+	} else {
+		t := make([]backup.DocumentYoutubeTrack, 0, len(*res.YoutubeTracks))
+		for _, v := range *res.YoutubeTracks {
+			t = append(t, backup.NewDocumentYoutubeTrack(&v))
+		}
+		tracks = t
+	}
+
+	writeApiJson(w, http.StatusOK, &struct {
+		apiPagination
+		Backup   apiBackup   `json:"backup"`
+		Playlist apiPlaylist `json:"playlist"`
+		Tracks   interface{} `json:"tracks"`
+	}{page, newApiBackup(res.Backup), newApiPlaylist(&res.Playlist), tracks})
+}
+
+// Same values as shown on the home page
+func (h *httpHandler) apiStatsHandler(w http.ResponseWriter, r *http.Request, params []string) {
+	user, err := h.apiUser(r)
+	if err != nil {
+		writeApiServiceError(w, err)
+		return
+	}
+
+	stats, err := h.backuper.GetBackupStats(user)
+	if err != nil {
+		writeApiServiceError(w, err)
+		return
+	}
+
+	v := struct {
+		UserId            string     `json:"userId"`
+		LastStarted       *time.Time `json:"lastStarted"`
+		LastFinished      *time.Time `json:"lastFinished"`
+		LastSuccessful    bool       `json:"lastSuccessful"`
+		LastPlaylistCount int64      `json:"lastPlaylistCount"`
+		LastTrackCount    int64      `json:"lastTrackCount"`
+		TotalBackups      int64      `json:"totalBackups"`
+	}{
+		UserId:            user,
+		LastSuccessful:    stats.Successful,
+		LastPlaylistCount: stats.PlaylistCount,
+		LastTrackCount:    stats.TrackCount,
+		TotalBackups:      stats.TotalBackups,
+	}
+
+	if !stats.StartedAt.IsZero() {
+		v.LastStarted = &stats.StartedAt
+	}
+
+	if !stats.FinishedAt.IsZero() {
+		v.LastFinished = &stats.FinishedAt
+	}
+
+	writeApiJson(w, http.StatusOK, &v)
+}
+
+// Config is returned with the same keys as in the config file,
+// secrets from env variables are not part of it.
+func (h *httpHandler) apiConfigHandler(w http.ResponseWriter, r *http.Request, params []string) {
+	data, err := yaml.Marshal(h.config)
+	if err != nil {
+		writeApiServiceError(w, err)
+		return
+	}
+
+	var v map[string]interface{}
+	err = yaml.Unmarshal(data, &v)
+	if err != nil {
+		writeApiServiceError(w, err)
+		return
+	}
+
+	writeApiJson(w, http.StatusOK, v)
+}
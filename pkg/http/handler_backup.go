@@ -1,13 +1,175 @@
 package http
 
 import (
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/hoffs/crispy-musicular/pkg/artifact"
+	"github.com/hoffs/crispy-musicular/pkg/backup"
+	"github.com/rs/zerolog/log"
 )
 
+// Joins the running backup unless queue=true is provided
 func (h *httpHandler) backupStartHandler(w http.ResponseWriter, r *http.Request) {
-	go h.backuper.Backup()
+	run, joined := h.backuper.StartBackup(backup.TriggerManual, r.URL.Query().Get("queue") == "true")
 
 	w.WriteHeader(http.StatusAccepted)
-	fmt.Fprint(w, "Backup started")
+	switch {
+	case run.State == backup.RunQueued:
+		fmt.Fprint(w, "Backup queued after the running one")
+	case joined:
+		fmt.Fprint(w, "Backup is already running")
+	default:
+		fmt.Fprint(w, "Backup started")
+	}
+}
+
+func (h *httpHandler) backupCancelHandler(w http.ResponseWriter, r *http.Request) {
+	_, err := h.backuper.CancelBackup(0)
+	if errors.Is(err, backup.ErrRunNotFound) {
+		http.Error(w, "No backup is running", http.StatusConflict)
+		return
+	}
+
+	if err != nil {
+		log.Error().Err(err).Msg("failed to cancel backup")
+		http.Error(w, "Failed to cancel backup", 500)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprint(w, "Backup is being cancelled")
+}
+
+func (h *httpHandler) backupRestoreHandler(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		log.Error().Err(err).Msg("failed to parse form data")
+		http.Error(w, "Failed to parse form data", 500)
+		return
+	}
+
+	backupId, err := strconv.ParseInt(r.PostForm.Get("backup"), 10, 64)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to parse backup")
+		http.Error(w, "Incorrect values", 400)
+		return
+	}
+
+	playlistId := r.PostForm.Get("playlist")
+	if playlistId == "" {
+		http.Error(w, "Incorrect values", 400)
+		return
+	}
+
+	var overwrite bool
+	if v := r.PostForm.Get("overwrite"); v != "" {
+		overwrite, err = strconv.ParseBool(v)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to parse overwrite")
+			http.Error(w, "Incorrect values", 400)
+			return
+		}
+	}
+
+	res, err := h.backuper.Restore(backupId, playlistId, overwrite)
+	if errors.Is(err, backup.ErrRestoreDisabled) {
+		http.Error(w, "Restore is not enabled", http.StatusForbidden)
+		return
+	}
+
+	if errors.Is(err, backup.ErrOverwriteLiked) {
+		http.Error(w, "Liked Songs can't be overwritten", http.StatusBadRequest)
+		return
+	}
+
+	if errors.Is(err, backup.ErrBackupNotFound) || errors.Is(err, backup.ErrPlaylistNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+
+	if err != nil {
+		log.Error().Err(err).Msg("handler_backup: failed to restore playlist")
+		msg := "Failed to restore playlist"
+		if res != nil && res.Overwrite && !res.RolledBack {
+			msg += ", playlist was left partially restored"
+		}
+		http.Error(w, msg, 500)
+		return
+	}
+
+	fmt.Fprintf(w, "Restored %d tracks to '%s' (spotify:playlist:%s)\n", res.Added, res.Name, res.PlaylistId)
+	if len(res.Failed) > 0 {
+		fmt.Fprintf(w, "%d tracks could not be added:\n", len(res.Failed))
+		for _, t := range res.Failed {
+			fmt.Fprintf(w, "%s - %s (%s)\n", t.Artist, t.Name, t.Album)
+		}
+	}
+}
+
+// Documents of big libraries are tens of megabytes, so these leave plenty of room
+const (
+	maxImportBodySize     = 64 << 20
+	maxImportDocumentSize = 256 << 20
+)
+
+// Body is a backup document as written by JSON action, it can be compressed
+// or encrypted as long as artifact identity or passphrase is configured.
+func (h *httpHandler) backupImportHandler(w http.ResponseWriter, r *http.Request) {
+	data, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxImportBodySize))
+	if err != nil {
+		log.Error().Err(err).Msg("handler_backup: failed to read import body")
+		http.Error(w, fmt.Sprintf("Failed to read backup, it can be at most %d MB", maxImportBodySize>>20), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	opts, err := artifact.NewDecodeOptionsFromConfig(h.config)
+	if err != nil {
+		log.Error().Err(err).Msg("handler_backup: failed to create artifact decode options")
+		http.Error(w, "Failed to import backup", 500)
+		return
+	}
+
+	opts.MaxSize = maxImportDocumentSize
+	data, err = artifact.Decode(data, opts)
+	if errors.Is(err, artifact.ErrTooLarge) {
+		http.Error(w, fmt.Sprintf("Backup can be at most %d MB after decoding", maxImportDocumentSize>>20), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	if err != nil {
+		log.Error().Err(err).Msg("handler_backup: failed to decode imported backup")
+		http.Error(w, fmt.Sprintf("Failed to decode backup: %s", err), 400)
+		return
+	}
+
+	d, err := backup.ParseDocument(data)
+	if err != nil {
+		log.Error().Err(err).Msg("handler_backup: failed to parse imported backup")
+		http.Error(w, fmt.Sprintf("Failed to parse backup: %s", err), 400)
+		return
+	}
+
+	bp, err := h.backuper.Import(d)
+	if errors.Is(err, backup.ErrBackupExists) {
+		http.Error(w, "Backup is already imported", http.StatusConflict)
+		return
+	}
+
+	if errors.Is(err, backup.ErrForeignBackup) {
+		http.Error(w, "Backup belongs to another user", http.StatusForbidden)
+		return
+	}
+
+	if err != nil {
+		log.Error().Err(err).Msg("handler_backup: failed to import backup")
+		http.Error(w, "Failed to import backup", 500)
+		return
+	}
+
+	fmt.Fprintf(w, "Imported backup %d of %s started at %s\n", bp.Id, bp.UserId, bp.Started.Format(time.RFC3339))
 }
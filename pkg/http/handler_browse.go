@@ -0,0 +1,324 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/hoffs/crispy-musicular/pkg/backup"
+)
+
+const (
+	browseBackupsPerPage   = 25
+	browsePlaylistsPerPage = 50
+	browseTracksPerPage    = 100
+)
+
+type pagination struct {
+	Page    int
+	Pages   int
+	PrevUrl string
+	NextUrl string
+}
+
+// Keeps other query parameters of the request so that links only change the page
+func newPagination(u *url.URL, page int, perPage int, total int64) pagination {
+	p := pagination{Page: page, Pages: int(math.Ceil(float64(total) / float64(perPage)))}
+	if p.Pages == 0 {
+		p.Pages = 1
+	}
+
+	pageUrl := func(n int) string {
+		q := u.Query()
+		q.Set("page", strconv.Itoa(n))
+		return u.Path + "?" + q.Encode()
+	}
+
+	if page > 1 {
+		p.PrevUrl = pageUrl(page - 1)
+	}
+
+	if page < p.Pages {
+		p.NextUrl = pageUrl(page + 1)
+	}
+
+	return p
+}
+
+// Invalid or missing page is treated as the first page
+func parsePage(r *http.Request) int {
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		return 1
+	}
+
+	return page
+}
+
+type formattedDuration struct {
+	started  time.Time
+	finished time.Time
+}
+
+func (fd formattedDuration) String() string {
+	if fd.finished.IsZero() || fd.finished.Before(fd.started) {
+		return ""
+	}
+	return fd.finished.Sub(fd.started).Round(time.Second).String()
+}
+
+type backupsPageData struct {
+	User       string
+	Backups    []backupsPageItem
+	Total      int64
+	Pagination pagination
+}
+
+type backupsPageItem struct {
+	Id            int64
+	Scope         backup.Scope
+	Started       formattedTime
+	Duration      formattedDuration
+	Finished      bool
+	Success       bool
+	PlaylistCount int64
+	TrackCount    int64
+}
+
+func (h *httpHandler) backupsHandler(w http.ResponseWriter, r *http.Request) {
+	st, err := h.auth.GetState()
+	if err != nil {
+		h.renderError(w, "No state found", err)
+		return
+	}
+
+	page := parsePage(r)
+	res, err := h.backuper.ListBackups(st.User, (page-1)*browseBackupsPerPage, browseBackupsPerPage)
+	if err != nil {
+		h.renderError(w, "Could not get backups", err)
+		return
+	}
+
+	d := backupsPageData{
+		User:       st.User,
+		Backups:    make([]backupsPageItem, 0, len(res.Backups)),
+		Total:      res.Total,
+		Pagination: newPagination(r.URL, page, browseBackupsPerPage, res.Total),
+	}
+
+	for _, v := range res.Backups {
+		d.Backups = append(d.Backups, backupsPageItem{
+			Id:            v.Backup.Id,
+			Scope:         v.Backup.Scope,
+			Started:       formattedTime{v.Backup.Started},
+			Duration:      formattedDuration{v.Backup.Started, v.Backup.Finished},
+			Finished:      !v.Backup.Finished.IsZero(),
+			Success:       v.Backup.Success,
+			PlaylistCount: v.PlaylistCount,
+			TrackCount:    v.TrackCount,
+		})
+	}
+
+	h.t.renderTemplate(w, "backups.tmpl", &d)
+}
+
+type backupPageData struct {
+	Id         int64
+	Scope      backup.Scope
+	Started    formattedTime
+	Finished   formattedTime
+	Duration   formattedDuration
+	Success    bool
+	Total      int64
+	Playlists  []backupPagePlaylist
+	Pagination pagination
+}
+
+type backupPagePlaylist struct {
+	Platform   string
+	Name       string
+	Owner      string
+	TrackCount int64
+	Url        string
+	ExternUrl  string
+}
+
+func (h *httpHandler) backupHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Backup id must be a number", http.StatusBadRequest)
+		return
+	}
+
+	page := parsePage(r)
+	bp, res, err := h.backuper.GetBackupPlaylists(id, (page-1)*browsePlaylistsPerPage, browsePlaylistsPerPage)
+	if errors.Is(err, backup.ErrBackupNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+
+	if err != nil {
+		h.renderError(w, "Could not get backup playlists", err)
+		return
+	}
+
+	d := backupPageData{
+		Id:         bp.Id,
+		Scope:      bp.Scope,
+		Started:    formattedTime{bp.Started},
+		Finished:   formattedTime{bp.Finished},
+		Duration:   formattedDuration{bp.Started, bp.Finished},
+		Success:    bp.Success,
+		Total:      res.Total,
+		Playlists:  make([]backupPagePlaylist, 0, len(res.Playlists)),
+		Pagination: newPagination(r.URL, page, browsePlaylistsPerPage, res.Total),
+	}
+
+	for _, v := range res.Playlists {
+		q := url.Values{}
+		q.Set("backup", strconv.FormatInt(bp.Id, 10))
+		q.Set("platform", v.Platform)
+		q.Set("id", v.Id)
+
+		d.Playlists = append(d.Playlists, backupPagePlaylist{
+			Platform:   v.Platform,
+			Name:       v.Name,
+			Owner:      v.Owner,
+			TrackCount: v.TrackCount,
+			Url:        "/backups/playlist?" + q.Encode(),
+			ExternUrl:  playlistUrl(v.Platform, v.Id),
+		})
+	}
+
+	h.t.renderTemplate(w, "backup.tmpl", &d)
+}
+
+type playlistPageData struct {
+	BackupId   int64
+	BackupUrl  string
+	Started    formattedTime
+	Platform   string
+	Name       string
+	Owner      string
+	ExternUrl  string
+	TrackCount int64
+	Tracks     []playlistPageTrack
+	Pagination pagination
+}
+
+type playlistPageTrack struct {
+	Position  int
+	Name      string
+	Artist    string
+	Album     string
+	Duration  string
+	Status    string
+	ExternUrl string
+}
+
+func (h *httpHandler) playlistHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	id, err := strconv.ParseInt(q.Get("backup"), 10, 64)
+	if err != nil {
+		http.Error(w, "Backup id must be a number", http.StatusBadRequest)
+		return
+	}
+
+	page := parsePage(r)
+	offset := (page - 1) * browseTracksPerPage
+	res, err := h.backuper.GetPlaylistTracks(id, q.Get("platform"), q.Get("id"), offset, browseTracksPerPage)
+	switch {
+	case errors.Is(err, backup.ErrUnknownPlatform):
+		http.Error(w, "Platform must be spotify or youtube", http.StatusBadRequest)
+		return
+	case errors.Is(err, backup.ErrBackupNotFound), errors.Is(err, backup.ErrPlaylistNotFound):
+		http.NotFound(w, r)
+		return
+	case err != nil:
+		h.renderError(w, "Could not get playlist tracks", err)
+		return
+	}
+
+	d := playlistPageData{
+		BackupId:   res.Backup.Id,
+		BackupUrl:  "/backups/backup?id=" + strconv.FormatInt(res.Backup.Id, 10),
+		Started:    formattedTime{res.Backup.Started},
+		Platform:   res.Playlist.Platform,
+		Name:       res.Playlist.Name,
+		Owner:      res.Playlist.Owner,
+		ExternUrl:  playlistUrl(res.Playlist.Platform, res.Playlist.Id),
+		TrackCount: res.Playlist.TrackCount,
+		Pagination: newPagination(r.URL, page, browseTracksPerPage, res.Playlist.TrackCount),
+	}
+
+	if res.Tracks != nil {
+		for i, v := range *res.Tracks {
+			t := playlistPageTrack{
+				Position: offset + i + 1,
+				Name:     v.Name,
+				Artist:   v.Artist,
+				Album:    v.Album,
+				Duration: formatTrackDuration(time.Duration(v.DurationMs) * time.Millisecond),
+			}
+
+			if v.IsLocal {
+				t.Status = "local"
+			} else if v.SpotifyId != "" {
+				t.ExternUrl = "https://open.spotify.com/track/" + v.SpotifyId
+			}
+
+			d.Tracks = append(d.Tracks, t)
+		}
+	} else {
+		for i, v := range *res.YoutubeTracks {
+			t := playlistPageTrack{
+				Position: offset + i + 1,
+				Name:     v.Name,
+				Artist:   v.ChannelTitle,
+				Duration: formatTrackDuration(time.Duration(v.DurationSeconds) * time.Second),
+				Status:   string(v.Availability),
+			}
+
+			if v.Availability == backup.AvailabilityPlayable {
+				t.Status = ""
+			}
+
+			if v.YoutubeId != "" {
+				t.ExternUrl = "https://www.youtube.com/watch?v=" + v.YoutubeId
+			}
+
+			d.Tracks = append(d.Tracks, t)
+		}
+	}
+
+	h.t.renderTemplate(w, "playlist.tmpl", &d)
+}
+
+// Liked songs have no public playlist page, so the library is linked instead
+func playlistUrl(platform string, id string) string {
+	switch {
+	case platform == backup.PlatformYoutube:
+		return "https://www.youtube.com/playlist?list=" + url.QueryEscape(id)
+	case id == backup.LikedSongsId:
+		return "https://open.spotify.com/collection/tracks"
+	default:
+		return "https://open.spotify.com/playlist/" + url.PathEscape(id)
+	}
+}
+
+func formatTrackDuration(d time.Duration) string {
+	if d <= 0 {
+		return ""
+	}
+
+	s := int(d.Round(time.Second) / time.Second)
+	if s >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+	}
+
+	return fmt.Sprintf("%d:%02d", s/60, s%60)
+}
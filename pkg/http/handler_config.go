@@ -27,9 +27,18 @@ type configPageConfig struct {
 type configPagePlaylistConfig struct {
 	IgnoreNotOwned  bool
 	IgnoreOwned     bool
+	LikedSongs      bool
+	SavedAlbums     bool
+	FollowedArtists bool
+	SavedShows      bool
 	SavedIds        []string
 	IgnoredIds      []string
 	YoutubeSavedIds []string
+
+	YoutubeMine           bool
+	YoutubeIgnoreNotOwned bool
+	YoutubeIgnoreOwned    bool
+	YoutubeIgnoredIds     []string
 }
 
 func (h *httpHandler) configHandler(w http.ResponseWriter, r *http.Request) {
@@ -48,9 +57,18 @@ func (h *httpHandler) configHandler(w http.ResponseWriter, r *http.Request) {
 		PlaylistConfig: configPagePlaylistConfig{
 			IgnoreNotOwned:  h.config.IgnoreNotOwnedPlaylists,
 			IgnoreOwned:     h.config.IgnoreOwnedPlaylists,
+			LikedSongs:      h.config.SpotifyLikedSongsEnabled,
+			SavedAlbums:     h.config.SpotifySavedAlbumsEnabled,
+			FollowedArtists: h.config.SpotifyFollowedArtistsEnabled,
+			SavedShows:      h.config.SpotifySavedShowsEnabled,
 			SavedIds:        h.config.SavedPlaylistIds,
 			IgnoredIds:      h.config.IgnoredPlaylistIds,
 			YoutubeSavedIds: h.config.YoutubeSavedPlaylistIds,
+
+			YoutubeMine:           h.config.YoutubeMinePlaylistsEnabled,
+			YoutubeIgnoreNotOwned: h.config.YoutubeIgnoreNotOwnedPlaylists,
+			YoutubeIgnoreOwned:    h.config.YoutubeIgnoreOwnedPlaylists,
+			YoutubeIgnoredIds:     h.config.YoutubeIgnoredPlaylistIds,
 		},
 	}
 	h.t.renderTemplate(w, "config.tmpl", &d)
@@ -91,9 +109,18 @@ func (h *httpHandler) editConfigHandler(w http.ResponseWriter, r *http.Request)
 		PlaylistConfig: configPagePlaylistConfig{
 			IgnoreNotOwned:  h.config.IgnoreNotOwnedPlaylists,
 			IgnoreOwned:     h.config.IgnoreOwnedPlaylists,
+			LikedSongs:      h.config.SpotifyLikedSongsEnabled,
+			SavedAlbums:     h.config.SpotifySavedAlbumsEnabled,
+			FollowedArtists: h.config.SpotifyFollowedArtistsEnabled,
+			SavedShows:      h.config.SpotifySavedShowsEnabled,
 			SavedIds:        h.config.SavedPlaylistIds,
 			IgnoredIds:      h.config.IgnoredPlaylistIds,
 			YoutubeSavedIds: h.config.YoutubeSavedPlaylistIds,
+
+			YoutubeMine:           h.config.YoutubeMinePlaylistsEnabled,
+			YoutubeIgnoreNotOwned: h.config.YoutubeIgnoreNotOwnedPlaylists,
+			YoutubeIgnoreOwned:    h.config.YoutubeIgnoreOwnedPlaylists,
+			YoutubeIgnoredIds:     h.config.YoutubeIgnoredPlaylistIds,
 		},
 		Playlists: p,
 	}
@@ -187,9 +214,59 @@ func (h *httpHandler) saveConfigHandler(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
+	likedSongs, err := parseCheckbox(r.PostForm.Get("liked_songs"))
+	if err != nil {
+		log.Error().Err(err).Msg("failed to parse liked_songs")
+		http.Error(w, "Incorrect values", 400)
+		return
+	}
+
+	savedAlbums, err := parseCheckbox(r.PostForm.Get("saved_albums"))
+	if err != nil {
+		log.Error().Err(err).Msg("failed to parse saved_albums")
+		http.Error(w, "Incorrect values", 400)
+		return
+	}
+
+	followedArtists, err := parseCheckbox(r.PostForm.Get("followed_artists"))
+	if err != nil {
+		log.Error().Err(err).Msg("failed to parse followed_artists")
+		http.Error(w, "Incorrect values", 400)
+		return
+	}
+
+	savedShows, err := parseCheckbox(r.PostForm.Get("saved_shows"))
+	if err != nil {
+		log.Error().Err(err).Msg("failed to parse saved_shows")
+		http.Error(w, "Incorrect values", 400)
+		return
+	}
+
+	youtubeMine, err := parseCheckbox(r.PostForm.Get("youtube_mine"))
+	if err != nil {
+		log.Error().Err(err).Msg("failed to parse youtube_mine")
+		http.Error(w, "Incorrect values", 400)
+		return
+	}
+
+	youtubeIgnoreNotOwned, err := parseCheckbox(r.PostForm.Get("youtube_ignore_not_owned"))
+	if err != nil {
+		log.Error().Err(err).Msg("failed to parse youtube_ignore_not_owned")
+		http.Error(w, "Incorrect values", 400)
+		return
+	}
+
+	youtubeIgnoreOwned, err := parseCheckbox(r.PostForm.Get("youtube_ignore_owned"))
+	if err != nil {
+		log.Error().Err(err).Msg("failed to parse youtube_ignore_owned")
+		http.Error(w, "Incorrect values", 400)
+		return
+	}
+
 	savedIds := parseUriList(r.PostForm.Get("saved"))
 	ignoredIds := parseUriList(r.PostForm.Get("ignored"))
 	youtubeSavedIds := parseYoutubeList(r.PostForm.Get("youtube_saved"))
+	youtubeIgnoredIds := parseYoutubeList(r.PostForm.Get("youtube_ignored"))
 
 	cCopy := (*h.config)
 	cCopy.RunIntervalSeconds = interval
@@ -199,7 +276,15 @@ func (h *httpHandler) saveConfigHandler(w http.ResponseWriter, r *http.Request)
 	cCopy.IgnoredPlaylistIds = ignoredIds
 	cCopy.IgnoreNotOwnedPlaylists = ignoreNotOwned
 	cCopy.IgnoreOwnedPlaylists = ignoreOwned
+	cCopy.SpotifyLikedSongsEnabled = likedSongs
+	cCopy.SpotifySavedAlbumsEnabled = savedAlbums
+	cCopy.SpotifyFollowedArtistsEnabled = followedArtists
+	cCopy.SpotifySavedShowsEnabled = savedShows
 	cCopy.YoutubeSavedPlaylistIds = youtubeSavedIds
+	cCopy.YoutubeIgnoredPlaylistIds = youtubeIgnoredIds
+	cCopy.YoutubeMinePlaylistsEnabled = youtubeMine
+	cCopy.YoutubeIgnoreNotOwnedPlaylists = youtubeIgnoreNotOwned
+	cCopy.YoutubeIgnoreOwnedPlaylists = youtubeIgnoreOwned
 
 	err = h.config.Update(&cCopy)
 	if err != nil {
@@ -211,6 +296,15 @@ func (h *httpHandler) saveConfigHandler(w http.ResponseWriter, r *http.Request)
 	http.Redirect(w, r, "/config", http.StatusFound)
 }
 
+// unchecked checkboxes are not sent at all
+func parseCheckbox(in string) (bool, error) {
+	if in == "" {
+		return false, nil
+	}
+
+	return strconv.ParseBool(in)
+}
+
 func parseUriList(in string) (s []string) {
 	uris := strings.Fields(in)
 	for _, v := range uris {
@@ -0,0 +1,75 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Proxies tend to close connections without traffic, so current progress is sent periodically
+const eventsKeepAlive = 30 * time.Second
+
+// Streams backup progress as Server-Sent Events. Current progress is sent first
+// as "progress" event, afterwards every backup event is sent with its type as the name.
+// Progress is also sent on keep-alive, so clients catch up if events were dropped.
+func (h *httpHandler) backupEventsHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	bus := h.backuper.Events()
+	events, unsubscribe := bus.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	err := writeEvent(w, "progress", bus.Progress())
+	if err != nil {
+		return
+	}
+	flusher.Flush()
+
+	ticker := time.NewTicker(eventsKeepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+
+			err = writeEvent(w, string(ev.Type), ev)
+		case <-ticker.C:
+			err = writeEvent(w, "progress", bus.Progress())
+		case <-r.Context().Done():
+			log.Debug().Msg("http_events: client disconnected")
+			return
+		}
+
+		if err != nil {
+			log.Debug().Err(err).Msg("http_events: failed to write event")
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+func writeEvent(w http.ResponseWriter, name string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, data)
+	return err
+}
@@ -3,11 +3,19 @@ package http
 import (
 	"net/http"
 	"time"
+
+	"github.com/hoffs/crispy-musicular/pkg/backup"
 )
 
 type homePageData struct {
-	User  string
-	Stats homePageStats
+	User      string
+	Stats     homePageStats
+	Schedules []homePageSchedule
+}
+
+type homePageSchedule struct {
+	Name string
+	Next formattedTime
 }
 
 type homePageStats struct {
@@ -53,5 +61,21 @@ func (h *httpHandler) homeHandler(w http.ResponseWriter, r *http.Request) {
 			TotalBackups:   backupStats.TotalBackups,
 		},
 	}
+
+	schedules, err := h.backuper.GetScheduledRuns()
+	if err != nil {
+		h.renderError(w, "Could not get scheduled backups", err)
+		return
+	}
+
+	for _, s := range *schedules {
+		name := "Spotify"
+		if s.Name == backup.ScheduleYoutube {
+			name = "Youtube"
+		}
+
+		d.Schedules = append(d.Schedules, homePageSchedule{Name: name, Next: formattedTime{s.Next}})
+	}
+
 	h.t.renderTemplate(w, "home.tmpl", &d)
 }
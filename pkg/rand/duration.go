@@ -0,0 +1,21 @@
+package rand
+
+import (
+	"crypto/rand"
+	"math/big"
+	"time"
+)
+
+// Returns random duration in [0, max)
+func Duration(max time.Duration) (time.Duration, error) {
+	if max <= 0 {
+		return 0, nil
+	}
+
+	r, err := rand.Int(rand.Reader, big.NewInt(int64(max)))
+	if err != nil {
+		return 0, err
+	}
+
+	return time.Duration(r.Int64()), nil
+}
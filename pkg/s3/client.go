@@ -0,0 +1,217 @@
+package s3
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Client is a minimal S3 client which only supports what backups need,
+// it works with AWS and S3-compatible servers like MinIO or Backblaze B2.
+type Client interface {
+	PutObject(bucket string, key string, contentType string, data []byte) error
+}
+
+type Options struct {
+	// Endpoint with scheme, e.g. https://s3.eu-central-1.amazonaws.com or http://localhost:9000
+	Endpoint        string
+	Region          string
+	AccessKeyId     string
+	SecretAccessKey string
+	// PathStyle uses endpoint/bucket/key instead of bucket.endpoint/key, MinIO usually requires it.
+	PathStyle bool
+}
+
+type client struct {
+	endpoint *url.URL
+	opts     Options
+	http     *http.Client
+	now      func() time.Time
+}
+
+type ResponseError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("s3: request failed with status %d: %s", e.StatusCode, e.Body)
+}
+
+func NewClient(opts Options) (c Client, err error) {
+	if opts.Endpoint == "" {
+		return nil, errors.New("s3: endpoint must be configured")
+	}
+
+	if opts.Region == "" {
+		opts.Region = "us-east-1"
+	}
+
+	endpoint, err := url.Parse(opts.Endpoint)
+	if err != nil {
+		return
+	}
+
+	if endpoint.Scheme != "http" && endpoint.Scheme != "https" {
+		return nil, fmt.Errorf("s3: endpoint %s must start with http:// or https://", opts.Endpoint)
+	}
+
+	c = &client{endpoint, opts, &http.Client{Timeout: 5 * time.Minute}, time.Now}
+	return
+}
+
+func (c *client) PutObject(bucket string, key string, contentType string, data []byte) (err error) {
+	req, err := http.NewRequest(http.MethodPut, c.objectUrl(bucket, key), bytes.NewReader(data))
+	if err != nil {
+		return
+	}
+
+	req.Header.Set("Content-Type", contentType)
+
+	hash := sha256.Sum256(data)
+	c.sign(req, hex.EncodeToString(hash[:]), c.now())
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		body, _ := ioutil.ReadAll(res.Body)
+		return &ResponseError{res.StatusCode, string(body)}
+	}
+
+	return
+}
+
+func (c *client) objectUrl(bucket string, key string) string {
+	u := *c.endpoint
+	base := strings.TrimSuffix(u.Path, "/")
+	if c.opts.PathStyle {
+		u.Path = base + "/" + bucket + "/" + key
+	} else {
+		u.Host = bucket + "." + u.Host
+		u.Path = base + "/" + key
+	}
+
+	u.RawPath = encodePath(u.Path)
+	return u.String()
+}
+
+// sign adds AWS Signature Version 4 headers to the request, every header
+// that is already set is signed.
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html
+func (c *client) sign(req *http.Request, payloadHash string, t time.Time) {
+	t = t.UTC()
+	amzDate := t.Format("20060102T150405Z")
+	date := t.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+
+	headers := map[string]string{"host": host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		encodePath(req.URL.Path),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + c.opts.Region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSha256([]byte("AWS4"+c.opts.SecretAccessKey), date)
+	key = hmacSha256(key, c.opts.Region)
+	key = hmacSha256(key, "s3")
+	key = hmacSha256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSha256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.opts.AccessKeyId, scope, signedHeaders, signature))
+}
+
+func hmacSha256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		values := q[k]
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, encode(k, true)+"="+encode(v, true))
+		}
+	}
+
+	return strings.Join(parts, "&")
+}
+
+func encodePath(p string) string {
+	if p == "" {
+		return "/"
+	}
+
+	return encode(p, false)
+}
+
+// encode escapes everything except unreserved characters, as required by
+// the signature, slashes are kept when encoding paths.
+func encode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for _, c := range []byte(s) {
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,244 @@
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Returns the first activation time strictly after t
+type Schedule interface {
+	Next(t time.Time) time.Time
+}
+
+var ErrInvalidSchedule = errors.New("schedule: invalid schedule")
+
+// Schedules which are not activated in this time are treated as never activated
+const maxSearchYears = 5
+
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var (
+	monthNames = map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}
+	dayNames = map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}
+)
+
+type field struct {
+	name  string
+	min   int
+	max   int
+	names map[string]int
+}
+
+var (
+	minuteField = field{"minute", 0, 59, nil}
+	hourField   = field{"hour", 0, 23, nil}
+	domField    = field{"day of month", 1, 31, nil}
+	monthField  = field{"month", 1, 12, monthNames}
+	// 7 is Sunday as well
+	dowField = field{"day of week", 0, 7, dayNames}
+)
+
+// Standard cron expression with 5 fields (minute, hour, day of month, month, day of week)
+// evaluated in the given location.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// Day matches if either of days matches, unless one of them is "*"
+	domStar, dowStar bool
+	loc              *time.Location
+}
+
+// Activates every duration after the time it is asked for
+type everySchedule struct {
+	every time.Duration
+}
+
+func (s *everySchedule) Next(t time.Time) time.Time {
+	return t.Add(s.every)
+}
+
+// Same as @every, but without the 1m minimum, so that intervals
+// configured in seconds keep working. Duration has to be positive.
+func Every(d time.Duration) Schedule {
+	return &everySchedule{d}
+}
+
+// Parses cron expression, one of the macros like @daily or @every <duration>.
+// Nil location means local time.
+func Parse(expr string, loc *time.Location) (Schedule, error) {
+	if loc == nil {
+		loc = time.Local
+	}
+
+	expr = strings.TrimSpace(expr)
+	if strings.HasPrefix(expr, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(expr, "@every ")))
+		if err != nil || d < time.Minute {
+			return nil, fmt.Errorf("%w: @every needs a duration of at least 1m, got '%s'", ErrInvalidSchedule, expr)
+		}
+
+		return &everySchedule{d}, nil
+	}
+
+	if m, ok := macros[strings.ToLower(expr)]; ok {
+		expr = m
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("%w: expected 5 fields, got %d in '%s'", ErrInvalidSchedule, len(fields), expr)
+	}
+
+	s := &cronSchedule{loc: loc}
+	var err error
+	for i, v := range []struct {
+		f    field
+		bits *uint64
+	}{{minuteField, &s.minute}, {hourField, &s.hour}, {domField, &s.dom}, {monthField, &s.month}, {dowField, &s.dow}} {
+		*v.bits, err = parseField(fields[i], v.f)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Sunday can be written as 0 or 7
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+
+	s.domStar = strings.HasPrefix(fields[2], "*")
+	s.dowStar = strings.HasPrefix(fields[4], "*")
+	return s, nil
+}
+
+// Supports lists, ranges, steps and names, e.g. "1-5", "*/15", "mon,wed", "10-40/10"
+func parseField(value string, f field) (bits uint64, err error) {
+	for _, part := range strings.Split(value, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step < 1 {
+				return 0, fmt.Errorf("%w: invalid step in %s '%s'", ErrInvalidSchedule, f.name, value)
+			}
+			part = part[:i]
+		}
+
+		start, end := f.min, f.max
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			start, err = parseValue(bounds[0], f)
+			if err != nil {
+				return
+			}
+
+			end, err = parseValue(bounds[1], f)
+			if err != nil {
+				return
+			}
+		default:
+			start, err = parseValue(part, f)
+			if err != nil {
+				return
+			}
+
+			// "5/10" means starting from 5 until the end
+			if step == 1 {
+				end = start
+			}
+		}
+
+		if start > end {
+			return 0, fmt.Errorf("%w: range start is after end in %s '%s'", ErrInvalidSchedule, f.name, value)
+		}
+
+		for v := start; v <= end; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return
+}
+
+func parseValue(value string, f field) (int, error) {
+	if v, ok := f.names[strings.ToLower(value)]; ok {
+		return v, nil
+	}
+
+	v, err := strconv.Atoi(value)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("%w: %s must be between %d and %d, got '%s'", ErrInvalidSchedule, f.name, f.min, f.max, value)
+	}
+
+	return v, nil
+}
+
+func has(bits uint64, v int) bool {
+	return bits&(1<<uint(v)) != 0
+}
+
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	dom := has(s.dom, t.Day())
+	dow := has(s.dow, int(t.Weekday()))
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+
+	return dom || dow
+}
+
+// Returns zero time if schedule is never activated, e.g. for 30th of February
+func (s *cronSchedule) Next(t time.Time) time.Time {
+	origLoc := t.Location()
+	t = t.In(s.loc)
+	// time.Date is not used, because it is ambiguous when clocks are moved back
+	t = t.Add(-time.Duration(t.Second())*time.Second - time.Duration(t.Nanosecond())).Add(time.Minute)
+	limit := t.AddDate(maxSearchYears, 0, 0)
+
+	for t.Before(limit) {
+		if !has(s.month, int(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, s.loc)
+			continue
+		}
+
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, s.loc)
+			continue
+		}
+
+		if !has(s.hour, t.Hour()) {
+			next := time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, s.loc)
+			// DST end repeats an hour, adding duration makes sure time moves forward
+			if !next.After(t) {
+				next = t.Add(time.Hour).Truncate(time.Hour)
+			}
+			t = next
+			continue
+		}
+
+		if !has(s.minute, t.Minute()) {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t.In(origLoc)
+	}
+
+	return time.Time{}
+}
@@ -0,0 +1,83 @@
+package schedule
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func mustParse(t *testing.T, expr string, loc *time.Location) Schedule {
+	s, err := Parse(expr, loc)
+	require.NoError(t, err)
+	return s
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"* * * foo *",
+		"@every 10s",
+		"@every soon",
+	} {
+		_, err := Parse(expr, time.UTC)
+		require.True(t, errors.Is(err, ErrInvalidSchedule), expr)
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	from := time.Date(2021, 6, 30, 12, 34, 56, 0, time.UTC) // Wednesday
+
+	for expr, expected := range map[string]time.Time{
+		"* * * * *":            time.Date(2021, 6, 30, 12, 35, 0, 0, time.UTC),
+		"0 3 * * *":            time.Date(2021, 7, 1, 3, 0, 0, 0, time.UTC),
+		"*/15 * * * *":         time.Date(2021, 6, 30, 12, 45, 0, 0, time.UTC),
+		"30 2 * * sun":         time.Date(2021, 7, 4, 2, 30, 0, 0, time.UTC),
+		"30 2 * * 7":           time.Date(2021, 7, 4, 2, 30, 0, 0, time.UTC),
+		"0 0 1 jan *":          time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+		"0 9-17/4 * * mon-fri": time.Date(2021, 6, 30, 13, 0, 0, 0, time.UTC),
+		"0 0 29 2 *":           time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC),
+		"@daily":               time.Date(2021, 7, 1, 0, 0, 0, 0, time.UTC),
+		"@hourly":              time.Date(2021, 6, 30, 13, 0, 0, 0, time.UTC),
+		"@every 1h30m":         from.Add(90 * time.Minute),
+		// day of month or day of week when both are restricted
+		"0 0 1 * fri": time.Date(2021, 7, 1, 0, 0, 0, 0, time.UTC),
+		"0 0 5 * fri": time.Date(2021, 7, 2, 0, 0, 0, 0, time.UTC),
+		"0 0 30 2 *":  {},
+	} {
+		require.Equal(t, expected, mustParse(t, expr, time.UTC).Next(from), expr)
+	}
+}
+
+func TestCronNextTimezone(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	s := mustParse(t, "0 3 * * *", loc)
+	next := s.Next(time.Date(2021, 6, 30, 12, 0, 0, 0, time.UTC))
+	require.Equal(t, time.Date(2021, 7, 1, 7, 0, 0, 0, time.UTC), next)
+	require.Equal(t, time.UTC, next.Location())
+
+	// 2:30 doesn't exist when clocks are moved forward
+	s = mustParse(t, "30 2 * * *", loc)
+	next = s.Next(time.Date(2021, 3, 14, 0, 0, 0, 0, loc))
+	require.True(t, next.After(time.Date(2021, 3, 14, 0, 0, 0, 0, loc)))
+	require.True(t, next.Before(time.Date(2021, 3, 15, 3, 0, 0, 0, loc)))
+
+	// hour is repeated when clocks are moved back, schedule still moves forward
+	s = mustParse(t, "0 * * * *", loc)
+	first := s.Next(time.Date(2021, 11, 7, 0, 30, 0, 0, loc))
+	second := s.Next(first)
+	third := s.Next(second)
+	require.True(t, second.After(first))
+	require.True(t, third.After(second))
+}
@@ -0,0 +1,135 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const minutesPerDay = 24 * 60
+
+// Daily time window, e.g. 22:00-07:00, which can span midnight
+type Window struct {
+	from int // minutes since midnight
+	to   int
+}
+
+// Parses window in HH:MM-HH:MM format
+func ParseWindow(value string) (w Window, err error) {
+	bounds := strings.Split(strings.TrimSpace(value), "-")
+	if len(bounds) != 2 {
+		return w, fmt.Errorf("%w: window must be in HH:MM-HH:MM format, got '%s'", ErrInvalidSchedule, value)
+	}
+
+	w.from, err = parseClock(bounds[0])
+	if err != nil {
+		return
+	}
+
+	w.to, err = parseClock(bounds[1])
+	if err != nil {
+		return
+	}
+
+	if w.from == w.to {
+		return w, fmt.Errorf("%w: window '%s' is empty", ErrInvalidSchedule, value)
+	}
+
+	return
+}
+
+func parseClock(value string) (int, error) {
+	parts := strings.Split(strings.TrimSpace(value), ":")
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("%w: time must be in HH:MM format, got '%s'", ErrInvalidSchedule, value)
+	}
+
+	h, err := strconv.Atoi(parts[0])
+	if err != nil || h < 0 || h > 24 {
+		return 0, fmt.Errorf("%w: invalid hour in '%s'", ErrInvalidSchedule, value)
+	}
+
+	m, err := strconv.Atoi(parts[1])
+	if err != nil || m < 0 || m > 59 || (h == 24 && m != 0) {
+		return 0, fmt.Errorf("%w: invalid minute in '%s'", ErrInvalidSchedule, value)
+	}
+
+	return (h*60 + m) % minutesPerDay, nil
+}
+
+func (w Window) String() string {
+	return fmt.Sprintf("%02d:%02d-%02d:%02d", w.from/60, w.from%60, w.to/60, w.to%60)
+}
+
+// Returns the end of the window if t falls into it. Time of day is taken in location of t.
+func (w Window) end(t time.Time) (end time.Time, ok bool) {
+	minute := t.Hour()*60 + t.Minute()
+	// time.Date is used instead of adding to midnight, so that DST changes are respected
+	at := func(days int) (time.Time, bool) {
+		end := time.Date(t.Year(), t.Month(), t.Day()+days, w.to/60, w.to%60, 0, 0, t.Location())
+		// end in the repeated hour can resolve to before t, window is treated as over then
+		return end, end.After(t)
+	}
+
+	if w.from < w.to {
+		if minute >= w.from && minute < w.to {
+			return at(0)
+		}
+		return
+	}
+
+	// spans midnight
+	if minute >= w.from {
+		return at(1)
+	}
+
+	if minute < w.to {
+		return at(0)
+	}
+
+	return
+}
+
+type Windows []Window
+
+func ParseWindows(values []string) (w Windows, err error) {
+	for _, v := range values {
+		window, err := ParseWindow(v)
+		if err != nil {
+			return nil, err
+		}
+
+		w = append(w, window)
+	}
+
+	return
+}
+
+// Moves t to the end of quiet window it falls into, windows evaluated in loc.
+// Adjacent or overlapping windows are skipped together.
+func (ws Windows) Defer(t time.Time, loc *time.Location) time.Time {
+	if loc == nil {
+		loc = time.Local
+	}
+
+	origLoc := t.Location()
+	t = t.In(loc)
+
+	// every window can move time at most once, otherwise windows cover the whole day
+	for i := 0; i <= len(ws); i++ {
+		moved := false
+		for _, w := range ws {
+			if end, ok := w.end(t); ok {
+				t = end
+				moved = true
+			}
+		}
+
+		if !moved {
+			break
+		}
+	}
+
+	return t.In(origLoc)
+}
@@ -0,0 +1,66 @@
+package schedule
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseWindow(t *testing.T) {
+	w, err := ParseWindow(" 22:00-07:30 ")
+	require.NoError(t, err)
+	require.Equal(t, "22:00-07:30", w.String())
+
+	w, err = ParseWindow("0:00-24:00")
+	require.Error(t, err)
+
+	for _, v := range []string{"22:00", "22-07", "25:00-07:00", "22:60-07:00", "10:00-10:00"} {
+		_, err = ParseWindow(v)
+		require.True(t, errors.Is(err, ErrInvalidSchedule), v)
+	}
+}
+
+func TestWindowsDefer(t *testing.T) {
+	ws, err := ParseWindows([]string{"22:00-07:00", "12:00-13:00", "07:00-08:00"})
+	require.NoError(t, err)
+
+	day := func(h, m int) time.Time {
+		return time.Date(2021, 6, 30, h, m, 0, 0, time.UTC)
+	}
+
+	require.Equal(t, day(10, 0), ws.Defer(day(10, 0), time.UTC))
+	require.Equal(t, day(13, 0), ws.Defer(day(12, 30), time.UTC))
+	// adjacent windows are skipped together
+	require.Equal(t, day(8, 0), ws.Defer(day(3, 0), time.UTC))
+	require.Equal(t, day(8, 0).AddDate(0, 0, 1), ws.Defer(day(23, 0), time.UTC))
+	require.Equal(t, day(22, 0).Add(-time.Minute), ws.Defer(day(22, 0).Add(-time.Minute), time.UTC))
+
+	// windows are in location of the schedule
+	loc := time.FixedZone("UTC+2", 2*60*60)
+	require.Equal(t, day(6, 0), ws.Defer(day(1, 0), loc))
+	require.Equal(t, time.UTC, ws.Defer(day(1, 0), loc).Location())
+}
+
+func TestWindowsDeferDST(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Vilnius")
+	require.NoError(t, err)
+
+	ws, err := ParseWindows([]string{"22:00-07:00"})
+	require.NoError(t, err)
+
+	// clocks are moved back at 04:00 on 2021-10-31, window still ends at 07:00 local time
+	end := ws.Defer(time.Date(2021, 10, 31, 0, 0, 0, 0, loc), loc)
+	require.Equal(t, time.Date(2021, 10, 31, 5, 0, 0, 0, time.UTC), end.UTC())
+
+	// end in the repeated hour never moves time backwards
+	ws, err = ParseWindows([]string{"03:00-03:30"})
+	require.NoError(t, err)
+	for _, repeated := range []time.Time{
+		time.Date(2021, 10, 31, 0, 10, 0, 0, time.UTC),
+		time.Date(2021, 10, 31, 1, 10, 0, 0, time.UTC),
+	} {
+		require.False(t, ws.Defer(repeated, loc).Before(repeated))
+	}
+}
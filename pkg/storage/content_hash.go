@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	bp "github.com/hoffs/crispy-musicular/pkg/backup"
+)
+
+// Content hash identifies a list of tracks regardless of which backup or
+// playlist it belongs to. Only values received from the platform are hashed,
+// so ids and creation times assigned locally don't affect it.
+//
+// Hashes are not stable across versions: when fields are added, contents stored
+// before aren't re-hashed, so the first backup after upgrading stores a new copy
+// of unchanged playlists instead of reusing the old one.
+func hashTracks(t []bp.Track) string {
+	h := sha256.New()
+	for _, v := range t {
+		writeFields(h, v.SpotifyId, v.Uri, v.Isrc, v.Name, v.Artist, strings.Join(v.ArtistIds, ","),
+			v.Album, v.AlbumArtist, v.AlbumReleaseDate,
+			strconv.Itoa(v.DurationMs), strconv.Itoa(v.TrackNumber), strconv.Itoa(v.DiscNumber),
+			strconv.FormatBool(v.Explicit), strconv.FormatBool(v.IsLocal),
+			v.AddedBy, v.AddedAtToPlaylist, string(v.Availability))
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func hashYoutubeTracks(t []bp.YoutubeTrack) string {
+	h := sha256.New()
+	for _, v := range t {
+		writeFields(h, v.YoutubeId, v.Name, v.ChannelId, v.ChannelTitle, v.Description, strings.Join(v.Tags, "\x1d"), v.CategoryId,
+			strconv.Itoa(v.DurationSeconds), v.PublishedAt, v.PrivacyStatus, formatThumbnails(v.Thumbnails),
+			v.MusicTitle, v.MusicArtist, v.MusicAlbum, v.MusicLabel, v.MusicReleaseDate,
+			v.AddedAtToPlaylist, string(v.Availability))
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// map is formatted in order of keys, so that hash doesn't depend on iteration order
+func formatThumbnails(t map[string]string) string {
+	keys := make([]string, 0, len(t))
+	for k := range t {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var s strings.Builder
+	for _, k := range keys {
+		s.WriteString(k + "=" + t[k] + "\x1d")
+	}
+
+	return s.String()
+}
+
+// fields are separated with unit separator and records with record separator
+// so that values moving between fields produce a different hash
+func writeFields(h hash.Hash, fields ...string) {
+	for _, f := range fields {
+		io.WriteString(h, f)
+		io.WriteString(h, "\x1f")
+	}
+	io.WriteString(h, "\x1e")
+}
@@ -3,11 +3,13 @@ package storage
 import "time"
 
 type playlist struct {
-	Id        int64
-	SpotifyId string
-	Name      string
-	Created   time.Time
+	Id         int64
+	SpotifyId  string
+	SnapshotId string
+	Name       string
+	Created    time.Time
 
 	// References
 	BackupdId int64
+	ContentId int64
 }
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"database/sql"
+	"time"
 
 	"github.com/hoffs/crispy-musicular/pkg/auth"
 	bp "github.com/hoffs/crispy-musicular/pkg/backup"
@@ -16,19 +17,45 @@ type Repository interface {
 	SetState(auth.State) error
 	ClearState() error
 
+	// Table: api_tokens
+	AddApiToken(t *auth.ApiToken) error
+	GetApiTokens() (*[]auth.ApiToken, error)
+	GetApiTokenByHash(hash string) (*auth.ApiToken, error)
+	DeleteApiToken(id int64) error
+	SetApiTokenUsed(id int64, used time.Time) error
+
 	AddBackup(b *bp.Backup) error
-	AddPlaylist(b *bp.Backup, p *bp.Playlist) error
-	AddTrack(b *bp.Backup, p *bp.Playlist, t *bp.Track) error
-	AddYoutubePlaylist(b *bp.Backup, p *bp.YoutubePlaylist) error
-	AddYoutubeTrack(b *bp.Backup, p *bp.YoutubePlaylist, t *bp.YoutubeTrack) error
+	AddPlaylist(b *bp.Backup, p *bp.Playlist, t *[]bp.Track) error
+	AddPlaylistFromSnapshot(b *bp.Backup, p *bp.Playlist) (bool, error)
+	SetPlaylistCover(p *bp.Playlist) error
+	AddYoutubePlaylist(b *bp.Backup, p *bp.YoutubePlaylist, t *[]bp.YoutubeTrack) error
+	AddAlbums(b *bp.Backup, a *[]bp.Album) error
+	AddArtists(b *bp.Backup, a *[]bp.Artist) error
+	AddShows(b *bp.Backup, s *[]bp.Show) error
 
 	UpdateBackup(b *bp.Backup) error
 
-	GetLastBackup(userId string) (*bp.Backup, error)
-	GetBackupPlaylistCount(b *bp.Backup) (int64, error)
-	GetBackupTrackCount(b *bp.Backup) (int64, error)
+	GetBackup(id int64) (*bp.Backup, error)
+	GetLastBackup(userId string, platform bp.Scope) (*bp.Backup, error)
+	GetBackupPlaylistCount(b *bp.Backup, platform bp.Scope) (int64, error)
+	GetBackupTrackCount(b *bp.Backup, platform bp.Scope) (int64, error)
 	GetBackupCount(userId string) (int64, error)
-	GetBackupData(b *bp.Backup) (*[]bp.Playlist, *[]bp.Track, *[]bp.YoutubePlaylist, *[]bp.YoutubeTrack, error)
+	GetBackupData(b *bp.Backup) (*[]bp.Playlist, *[]bp.Track, *[]bp.YoutubePlaylist, *[]bp.YoutubeTrack, *bp.Library, error)
+	GetLostTracks(b *bp.Backup) (*[]bp.LostTrack, *[]bp.LostYoutubeTrack, error)
+	GetBackups(userId string) (*[]bp.Backup, error)
+	GetBackupsPage(userId string, offset int, limit int) (*[]bp.Backup, error)
+	GetPlaylistSummaries(b *bp.Backup, offset int, limit int) (*[]bp.PlaylistSummary, error)
+	GetPlaylistSummary(b *bp.Backup, platform string, id string) (*bp.PlaylistSummary, error)
+	GetPlaylistTracks(b *bp.Backup, spotifyId string, offset int, limit int) (*[]bp.Track, error)
+	GetYoutubePlaylistTracks(b *bp.Backup, youtubeId string, offset int, limit int) (*[]bp.YoutubeTrack, error)
+
+	DeleteBackups(b *[]bp.Backup) (orphanedCovers []string, err error)
+	Vacuum() error
+
+	// Table: schedules
+	GetScheduledRun(name string) (*bp.ScheduledRun, error)
+	GetScheduledRuns() (*[]bp.ScheduledRun, error)
+	SetScheduledRun(s *bp.ScheduledRun) error
 }
 
 type repository struct {
@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/hoffs/crispy-musicular/pkg/auth"
+)
+
+func (r *repository) AddApiToken(t *auth.ApiToken) (err error) {
+	result, err := r.db.Exec("INSERT INTO api_tokens (name, token_hash, created) VALUES (?, ?, ?)", t.Name, t.Hash, t.Created)
+	if err != nil {
+		return
+	}
+
+	t.Id, err = result.LastInsertId()
+	return
+}
+
+func (r *repository) GetApiTokens() (t *[]auth.ApiToken, err error) {
+	rows, err := r.db.Query("SELECT id, name, token_hash, created, last_used FROM api_tokens ORDER BY id")
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	tokens := []auth.ApiToken{}
+	for rows.Next() {
+		var v auth.ApiToken
+		var lastUsed sql.NullTime
+		err = rows.Scan(&v.Id, &v.Name, &v.Hash, &v.Created, &lastUsed)
+		if err != nil {
+			return
+		}
+
+		v.LastUsed = lastUsed.Time
+		tokens = append(tokens, v)
+	}
+
+	return &tokens, rows.Err()
+}
+
+func (r *repository) GetApiTokenByHash(hash string) (t *auth.ApiToken, err error) {
+	t = &auth.ApiToken{Hash: hash}
+	var lastUsed sql.NullTime
+	err = r.db.QueryRow("SELECT id, name, created, last_used FROM api_tokens WHERE token_hash = ?", hash).Scan(&t.Id, &t.Name, &t.Created, &lastUsed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, auth.ErrApiTokenNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	t.LastUsed = lastUsed.Time
+	return
+}
+
+func (r *repository) DeleteApiToken(id int64) (err error) {
+	result, err := r.db.Exec("DELETE FROM api_tokens WHERE id = ?", id)
+	if err != nil {
+		return
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return
+	}
+
+	if n == 0 {
+		return auth.ErrApiTokenNotFound
+	}
+
+	return
+}
+
+func (r *repository) SetApiTokenUsed(id int64, used time.Time) (err error) {
+	_, err = r.db.Exec("UPDATE api_tokens SET last_used = ? WHERE id = ?", used, id)
+	return
+}
@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hoffs/crispy-musicular/pkg/auth"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApiTokens(t *testing.T) {
+	r, err := NewRepository(":memory:")
+	require.NoError(t, err)
+
+	tok := auth.ApiToken{Name: "script", Hash: "hash", Created: time.Unix(0, 0).UTC()}
+	err = r.AddApiToken(&tok)
+	require.NoError(t, err)
+	require.NotZero(t, tok.Id)
+
+	err = r.AddApiToken(&auth.ApiToken{Name: "duplicate", Hash: "hash", Created: time.Unix(0, 0).UTC()})
+	require.Error(t, err)
+
+	found, err := r.GetApiTokenByHash("hash")
+	require.NoError(t, err)
+	require.Equal(t, "script", found.Name)
+	require.True(t, found.LastUsed.IsZero())
+
+	err = r.SetApiTokenUsed(tok.Id, time.Unix(100, 0).UTC())
+	require.NoError(t, err)
+
+	tokens, err := r.GetApiTokens()
+	require.NoError(t, err)
+	require.Len(t, *tokens, 1)
+	require.Equal(t, time.Unix(100, 0).UTC(), (*tokens)[0].LastUsed.UTC())
+
+	err = r.DeleteApiToken(tok.Id)
+	require.NoError(t, err)
+
+	err = r.DeleteApiToken(tok.Id)
+	require.ErrorIs(t, err, auth.ErrApiTokenNotFound)
+
+	_, err = r.GetApiTokenByHash("hash")
+	require.ErrorIs(t, err, auth.ErrApiTokenNotFound)
+}
@@ -2,14 +2,22 @@ package storage
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"reflect"
+	"strings"
+	"time"
 
 	bp "github.com/hoffs/crispy-musicular/pkg/backup"
 )
 
 func (r *repository) AddBackup(b *bp.Backup) (err error) {
-	result, err := r.db.Exec("INSERT INTO backups (user_id, started) VALUES (?, ?)", b.UserId, b.Started)
+	if b.Scope == "" {
+		b.Scope = bp.ScopeAll
+	}
+
+	result, err := r.db.Exec("INSERT INTO backups (user_id, started, scope) VALUES (?, ?, ?)", b.UserId, b.Started, b.Scope)
 	if err != nil {
 		return
 	}
@@ -18,13 +26,73 @@ func (r *repository) AddBackup(b *bp.Backup) (err error) {
 	return
 }
 
-func (r *repository) AddPlaylist(b *bp.Backup, p *bp.Playlist) (err error) {
-	result, err := r.db.Exec(
-		"INSERT INTO playlists (spotify_id, name, created, backup_id) VALUES (?, ?, ?, ?)",
+// Stores playlist entry of the backup together with its tracks. Tracks are
+// content addressed, so if exactly the same list of tracks was already stored
+// the playlist only references it instead of storing all tracks again.
+func (r *repository) AddPlaylist(b *bp.Backup, p *bp.Playlist, t *[]bp.Track) (err error) {
+	var tracks []bp.Track
+	if t != nil {
+		tracks = *t
+	}
+
+	for id := range tracks {
+		tracks[id].Availability = availabilityOrDefault(tracks[id].Availability)
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return
+	}
+	defer tx.Rollback()
+
+	contentId, created, err := getOrCreateContent(tx, "playlist_contents", hashTracks(tracks), p.Created)
+	if err != nil {
+		return
+	}
+
+	err = insertPlaylist(tx, b, p, contentId)
+	if err != nil {
+		return
+	}
+
+	if created {
+		err = addTracks(tx, contentId, tracks)
+	} else {
+		err = loadTrackIds(tx, contentId, tracks)
+	}
+	if err != nil {
+		return
+	}
+
+	for id := range tracks {
+		tracks[id].PlaylistId = p.Id
+	}
+
+	return tx.Commit()
+}
+
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+func insertPlaylist(e execer, b *bp.Backup, p *bp.Playlist, contentId int64) (err error) {
+	result, err := e.Exec(
+		`INSERT INTO playlists (spotify_id, snapshot_id, name, description, owner_id, owner_name, collaborative, public, cover_url, cover_blob, position, created, backup_id, content_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		p.SpotifyId,
+		p.SnapshotId,
 		p.Name,
+		p.Description,
+		p.OwnerId,
+		p.OwnerName,
+		p.Collaborative,
+		p.Public,
+		p.CoverUrl,
+		p.CoverBlob,
+		p.Position,
 		p.Created,
-		b.Id)
+		b.Id,
+		contentId)
 	if err != nil {
 		return
 	}
@@ -33,53 +101,336 @@ func (r *repository) AddPlaylist(b *bp.Backup, p *bp.Playlist) (err error) {
 	return
 }
 
-func (r *repository) AddTrack(b *bp.Backup, p *bp.Playlist, t *bp.Track) (err error) {
-	result, err := r.db.Exec("INSERT INTO tracks (spotify_id, name, artist, album, added_at_to_playlist, created, playlist_id, backup_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
-		t.SpotifyId,
-		t.Name,
-		t.Artist,
-		t.Album,
-		t.AddedAtToPlaylist,
-		t.Created,
-		p.Id,
-		b.Id)
+func addTracks(tx *sql.Tx, contentId int64, t []bp.Track) (err error) {
+	for id := range t {
+		result, err := tx.Exec(
+			`INSERT INTO tracks (spotify_id, uri, isrc, name, artist, artist_ids, album, album_artist, album_release_date,
+				duration_ms, track_number, disc_number, explicit, is_local, added_by, added_at_to_playlist, availability, created, content_id, position)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			t[id].SpotifyId,
+			t[id].Uri,
+			t[id].Isrc,
+			t[id].Name,
+			t[id].Artist,
+			strings.Join(t[id].ArtistIds, ","),
+			t[id].Album,
+			t[id].AlbumArtist,
+			t[id].AlbumReleaseDate,
+			t[id].DurationMs,
+			t[id].TrackNumber,
+			t[id].DiscNumber,
+			t[id].Explicit,
+			t[id].IsLocal,
+			t[id].AddedBy,
+			t[id].AddedAtToPlaylist,
+			t[id].Availability,
+			t[id].Created,
+			contentId,
+			id)
+		if err != nil {
+			return err
+		}
+
+		t[id].Id, err = result.LastInsertId()
+		if err != nil {
+			return err
+		}
+	}
+
+	return
+}
+
+// Columns of a Spotify track in the order expected by scanTrack,
+// alias is the name of tracks table in the query.
+func trackColumns(alias string) string {
+	cols := []string{"id", "spotify_id", "uri", "isrc", "name", "artist", "artist_ids", "album", "album_artist", "album_release_date",
+		"duration_ms", "track_number", "disc_number", "explicit", "is_local", "added_by", "added_at_to_playlist", "availability", "created"}
+	for id := range cols {
+		cols[id] = alias + "." + cols[id]
+	}
+
+	return strings.Join(cols, ", ")
+}
+
+// Scans columns from trackColumns followed by any extra values
+func scanTrack(rows *sql.Rows, t *bp.Track, extra ...interface{}) (err error) {
+	var artistIds string
+	dest := []interface{}{&t.Id, &t.SpotifyId, &t.Uri, &t.Isrc, &t.Name, &t.Artist, &artistIds, &t.Album, &t.AlbumArtist, &t.AlbumReleaseDate,
+		&t.DurationMs, &t.TrackNumber, &t.DiscNumber, &t.Explicit, &t.IsLocal, &t.AddedBy, &t.AddedAtToPlaylist, &t.Availability, &t.Created}
+
+	err = rows.Scan(append(dest, extra...)...)
 	if err != nil {
 		return
 	}
 
-	t.Id, err = result.LastInsertId()
+	if artistIds != "" {
+		t.ArtistIds = strings.Split(artistIds, ",")
+	}
+
 	return
 }
 
-func (r *repository) AddYoutubePlaylist(b *bp.Backup, p *bp.YoutubePlaylist) (err error) {
-	result, err := r.db.Exec(
-		"INSERT INTO youtube_playlists (youtube_id, name, created, backup_id) VALUES (?, ?, ?, ?)",
+// updates tracks with values of already stored tracks
+func loadTrackIds(tx *sql.Tx, contentId int64, t []bp.Track) (err error) {
+	result, err := tx.Query("SELECT id, created FROM tracks WHERE content_id = ? ORDER BY position", contentId)
+	if err != nil {
+		return
+	}
+	defer result.Close()
+
+	for id := 0; result.Next() && id < len(t); id++ {
+		err = result.Scan(&t[id].Id, &t[id].Created)
+		if err != nil {
+			return
+		}
+	}
+
+	return result.Err()
+}
+
+// Stores playlist entry of the backup referencing tracks of the latest stored playlist
+// with the same Spotify and snapshot ids. Returns false if no such playlist exists.
+// Description is not listed with playlists, but it is part of the snapshot,
+// so it is taken from the referenced playlist.
+func (r *repository) AddPlaylistFromSnapshot(b *bp.Backup, p *bp.Playlist) (ok bool, err error) {
+	if p.SnapshotId == "" {
+		return false, nil
+	}
+
+	var contentId int64
+	var coverBlob string
+	row := r.db.QueryRow(
+		"SELECT content_id, description, COALESCE(cover_blob, '') FROM playlists WHERE spotify_id = ? AND snapshot_id = ? AND content_id IS NOT NULL ORDER BY id DESC LIMIT 1",
+		p.SpotifyId,
+		p.SnapshotId)
+	err = row.Scan(&contentId, &p.Description, &coverBlob)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+
+	if err != nil {
+		return
+	}
+
+	// Cover of the stored snapshot is kept unless a new one was provided
+	if p.CoverBlob == "" {
+		p.CoverBlob = coverBlob
+	}
+
+	err = insertPlaylist(r.db, b, p, contentId)
+	return err == nil, err
+}
+
+func (r *repository) SetPlaylistCover(p *bp.Playlist) (err error) {
+	_, err = r.db.Exec("UPDATE playlists SET cover_blob = ? WHERE id = ?", p.CoverBlob, p.Id)
+	return
+}
+
+// Same as AddPlaylist, but for Youtube playlists.
+func (r *repository) AddYoutubePlaylist(b *bp.Backup, p *bp.YoutubePlaylist, t *[]bp.YoutubeTrack) (err error) {
+	var tracks []bp.YoutubeTrack
+	if t != nil {
+		tracks = *t
+	}
+
+	for id := range tracks {
+		tracks[id].Availability = availabilityOrDefault(tracks[id].Availability)
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return
+	}
+	defer tx.Rollback()
+
+	contentId, created, err := getOrCreateContent(tx, "youtube_playlist_contents", hashYoutubeTracks(tracks), p.Created)
+	if err != nil {
+		return
+	}
+
+	result, err := tx.Exec(
+		`INSERT INTO youtube_playlists (youtube_id, name, description, channel_id, channel_title, privacy_status, cover_url, cover_blob, position, created, backup_id, content_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		p.YoutubeId,
 		p.Name,
+		p.Description,
+		p.ChannelId,
+		p.ChannelTitle,
+		p.PrivacyStatus,
+		p.CoverUrl,
+		p.CoverBlob,
+		p.Position,
 		p.Created,
-		b.Id)
+		b.Id,
+		contentId)
 	if err != nil {
 		return
 	}
 
 	p.Id, err = result.LastInsertId()
+	if err != nil {
+		return
+	}
+
+	if created {
+		err = addYoutubeTracks(tx, contentId, tracks)
+	} else {
+		err = loadYoutubeTrackIds(tx, contentId, tracks)
+	}
+	if err != nil {
+		return
+	}
+
+	for id := range tracks {
+		tracks[id].PlaylistId = p.Id
+	}
+
+	return tx.Commit()
+}
+
+func addYoutubeTracks(tx *sql.Tx, contentId int64, t []bp.YoutubeTrack) (err error) {
+	for id := range t {
+		tags, err := encodeJson(t[id].Tags)
+		if err != nil {
+			return err
+		}
+
+		thumbnails, err := encodeJson(t[id].Thumbnails)
+		if err != nil {
+			return err
+		}
+
+		result, err := tx.Exec(
+			`INSERT INTO youtube_tracks (youtube_id, name, channel_id, channel_title, description, tags, category_id, duration_seconds, published_at, privacy_status, thumbnails,
+				music_title, music_artist, music_album, music_label, music_release_date, added_at_to_playlist, availability, created, content_id, position)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			t[id].YoutubeId,
+			t[id].Name,
+			t[id].ChannelId,
+			t[id].ChannelTitle,
+			t[id].Description,
+			tags,
+			t[id].CategoryId,
+			t[id].DurationSeconds,
+			t[id].PublishedAt,
+			t[id].PrivacyStatus,
+			thumbnails,
+			t[id].MusicTitle,
+			t[id].MusicArtist,
+			t[id].MusicAlbum,
+			t[id].MusicLabel,
+			t[id].MusicReleaseDate,
+			t[id].AddedAtToPlaylist,
+			t[id].Availability,
+			t[id].Created,
+			contentId,
+			id)
+		if err != nil {
+			return err
+		}
+
+		t[id].Id, err = result.LastInsertId()
+		if err != nil {
+			return err
+		}
+	}
+
 	return
 }
 
-func (r *repository) AddYoutubeTrack(b *bp.Backup, p *bp.YoutubePlaylist, t *bp.YoutubeTrack) (err error) {
-	result, err := r.db.Exec("INSERT INTO youtube_tracks (youtube_id, name, channel_title, added_at_to_playlist, created, playlist_id, backup_id) VALUES (?, ?, ?, ?, ?, ?, ?)",
-		t.YoutubeId,
-		t.Name,
-		t.ChannelTitle,
-		t.AddedAtToPlaylist,
-		t.Created,
-		p.Id,
-		b.Id)
+// Same as trackColumns, but for Youtube tracks
+func youtubeTrackColumns(alias string) string {
+	cols := []string{"id", "youtube_id", "name", "channel_id", "channel_title", "description", "tags", "category_id", "duration_seconds", "published_at",
+		"privacy_status", "thumbnails", "music_title", "music_artist", "music_album", "music_label", "music_release_date", "added_at_to_playlist", "availability", "created"}
+	for id := range cols {
+		cols[id] = alias + "." + cols[id]
+	}
+
+	return strings.Join(cols, ", ")
+}
+
+func scanYoutubeTrack(rows *sql.Rows, t *bp.YoutubeTrack, extra ...interface{}) (err error) {
+	var tags, thumbnails string
+	dest := []interface{}{&t.Id, &t.YoutubeId, &t.Name, &t.ChannelId, &t.ChannelTitle, &t.Description, &tags, &t.CategoryId, &t.DurationSeconds, &t.PublishedAt,
+		&t.PrivacyStatus, &thumbnails, &t.MusicTitle, &t.MusicArtist, &t.MusicAlbum, &t.MusicLabel, &t.MusicReleaseDate, &t.AddedAtToPlaylist, &t.Availability, &t.Created}
+
+	err = rows.Scan(append(dest, extra...)...)
+	if err != nil {
+		return
+	}
+
+	err = decodeJson(tags, &t.Tags)
 	if err != nil {
 		return
 	}
 
-	t.Id, err = result.LastInsertId()
+	return decodeJson(thumbnails, &t.Thumbnails)
+}
+
+// Empty values are stored as empty strings instead of null
+func encodeJson(v interface{}) (string, error) {
+	if reflect.ValueOf(v).Len() == 0 {
+		return "", nil
+	}
+
+	data, err := json.Marshal(v)
+	return string(data), err
+}
+
+func decodeJson(data string, v interface{}) error {
+	if data == "" {
+		return nil
+	}
+
+	return json.Unmarshal([]byte(data), v)
+}
+
+func loadYoutubeTrackIds(tx *sql.Tx, contentId int64, t []bp.YoutubeTrack) (err error) {
+	result, err := tx.Query("SELECT id, created FROM youtube_tracks WHERE content_id = ? ORDER BY position", contentId)
+	if err != nil {
+		return
+	}
+	defer result.Close()
+
+	for id := 0; result.Next() && id < len(t); id++ {
+		err = result.Scan(&t[id].Id, &t[id].Created)
+		if err != nil {
+			return
+		}
+	}
+
+	return result.Err()
+}
+
+// Tracks without known availability are treated as playable
+func availabilityOrDefault(a bp.Availability) bp.Availability {
+	if a == "" {
+		return bp.AvailabilityPlayable
+	}
+
+	return a
+}
+
+// Returns id of content with provided hash, creating it if it doesn't exist.
+// created is true only if content entry was created by this call.
+func getOrCreateContent(tx *sql.Tx, table string, hash string, now time.Time) (id int64, created bool, err error) {
+	row := tx.QueryRow(fmt.Sprintf("SELECT id FROM %s WHERE hash = ?", table), hash)
+	err = row.Scan(&id)
+	if err == nil {
+		return
+	}
+
+	if !errors.Is(err, sql.ErrNoRows) {
+		return
+	}
+
+	result, err := tx.Exec(fmt.Sprintf("INSERT INTO %s (hash, created) VALUES (?, ?)", table), hash, now)
+	if err != nil {
+		return
+	}
+
+	id, err = result.LastInsertId()
+	created = err == nil
 	return
 }
 
@@ -97,12 +448,37 @@ func (r *repository) UpdateBackup(b *bp.Backup) (err error) {
 	return
 }
 
-func (r *repository) GetLastBackup(userId string) (b *bp.Backup, err error) {
+func (r *repository) GetBackup(id int64) (b *bp.Backup, err error) {
+	b = &bp.Backup{Id: id}
+	result := r.db.QueryRow("SELECT user_id, success, started, finished, scope FROM backups WHERE id = ?", id)
+	var finished sql.NullTime
+	var ok sql.NullBool
+	err = result.Scan(&b.UserId, &ok, &b.Started, &finished, &b.Scope)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, bp.ErrBackupNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	b.Finished = finished.Time
+	b.Success = ok.Bool
+	return
+}
+
+// Returns the newest backup which covers the platform, with ScopeAll newest backup
+// of any scope is returned. Backup without id is returned if there are none.
+func (r *repository) GetLastBackup(userId string, platform bp.Scope) (b *bp.Backup, err error) {
 	b = &bp.Backup{UserId: userId}
-	result := r.db.QueryRow("SELECT id, success, started, finished FROM backups WHERE user_id = ? ORDER BY started DESC LIMIT 1", userId)
+	result := r.db.QueryRow(
+		`SELECT id, success, started, finished, scope FROM backups
+		WHERE user_id = ? AND (? = 'all' OR scope IN ('all', ?))
+		ORDER BY started DESC LIMIT 1`,
+		userId, platform, platform)
 	var finished sql.NullTime
 	var ok sql.NullBool
-	err = result.Scan(&b.Id, &ok, &b.Started, &finished)
+	err = result.Scan(&b.Id, &ok, &b.Started, &finished, &b.Scope)
 	if errors.Is(err, sql.ErrNoRows) {
 		return b, nil
 	}
@@ -118,14 +494,25 @@ func (r *repository) GetLastBackup(userId string) (b *bp.Backup, err error) {
 	return
 }
 
-func (r *repository) GetBackupPlaylistCount(b *bp.Backup) (count int64, err error) {
-	result := r.db.QueryRow("SELECT SUM(count) FROM (SELECT count(*) count FROM youtube_playlists WHERE backup_id = ? UNION ALL SELECT count(*) count FROM playlists WHERE backup_id = ?)", b.Id, b.Id)
+// Counts playlists of the platform in the backup, ScopeAll counts all of them
+func (r *repository) GetBackupPlaylistCount(b *bp.Backup, platform bp.Scope) (count int64, err error) {
+	result := r.db.QueryRow(`
+		SELECT SUM(count) FROM (
+			SELECT count(*) count FROM youtube_playlists WHERE backup_id = ? AND ? != 'spotify'
+			UNION ALL
+			SELECT count(*) count FROM playlists WHERE backup_id = ? AND ? != 'youtube'
+		)`, b.Id, platform, b.Id, platform)
 	err = result.Scan(&count)
 	return
 }
 
-func (r *repository) GetBackupTrackCount(b *bp.Backup) (count int64, err error) {
-	result := r.db.QueryRow("SELECT SUM(count) FROM (SELECT count(*) count FROM youtube_tracks WHERE backup_id = ? UNION ALL SELECT count(*) count FROM tracks WHERE backup_id = ?)", b.Id, b.Id)
+func (r *repository) GetBackupTrackCount(b *bp.Backup, platform bp.Scope) (count int64, err error) {
+	result := r.db.QueryRow(`
+		SELECT SUM(count) FROM (
+			SELECT count(*) count FROM youtube_playlists p JOIN youtube_tracks t ON t.content_id = p.content_id WHERE p.backup_id = ? AND ? != 'spotify'
+			UNION ALL
+			SELECT count(*) count FROM playlists p JOIN tracks t ON t.content_id = p.content_id WHERE p.backup_id = ? AND ? != 'youtube'
+		)`, b.Id, platform, b.Id, platform)
 	err = result.Scan(&count)
 	return
 }
@@ -136,14 +523,15 @@ func (r *repository) GetBackupCount(userId string) (count int64, err error) {
 	return
 }
 
-func (r *repository) GetBackupData(b *bp.Backup) (p *[]bp.Playlist, t *[]bp.Track, yp *[]bp.YoutubePlaylist, yt *[]bp.YoutubeTrack, err error) {
+func (r *repository) GetBackupData(b *bp.Backup) (p *[]bp.Playlist, t *[]bp.Track, yp *[]bp.YoutubePlaylist, yt *[]bp.YoutubeTrack, l *bp.Library, err error) {
 	var lp []bp.Playlist
 	var lt []bp.Track
 	p = &lp
 	t = &lt
 
 	result, err := r.db.Query(
-		"SELECT id, spotify_id, name, created FROM playlists WHERE backup_id = ?",
+		`SELECT id, spotify_id, snapshot_id, name, description, owner_id, owner_name, collaborative, public, cover_url, cover_blob, position, created
+		FROM playlists WHERE backup_id = ? ORDER BY id`,
 		b.Id)
 	if err != nil {
 		return
@@ -152,16 +540,23 @@ func (r *repository) GetBackupData(b *bp.Backup) (p *[]bp.Playlist, t *[]bp.Trac
 
 	for result.Next() {
 		sp := bp.Playlist{}
-		err = result.Scan(&sp.Id, &sp.SpotifyId, &sp.Name, &sp.Created)
+		var snapshotId sql.NullString
+		err = result.Scan(&sp.Id, &sp.SpotifyId, &snapshotId, &sp.Name, &sp.Description, &sp.OwnerId, &sp.OwnerName,
+			&sp.Collaborative, &sp.Public, &sp.CoverUrl, &sp.CoverBlob, &sp.Position, &sp.Created)
 		if err != nil {
 			return
 		}
 
+		sp.SnapshotId = snapshotId.String
+
 		lp = append(lp, sp)
 	}
 
 	result, err = r.db.Query(
-		"SELECT id, spotify_id, name, artist, album, added_at_to_playlist, created, playlist_id FROM tracks WHERE backup_id = ?",
+		`SELECT `+trackColumns("t")+`, p.id
+		FROM playlists p JOIN tracks t ON t.content_id = p.content_id
+		WHERE p.backup_id = ?
+		ORDER BY p.id, t.position`,
 		b.Id)
 	if err != nil {
 		return
@@ -170,7 +565,7 @@ func (r *repository) GetBackupData(b *bp.Backup) (p *[]bp.Playlist, t *[]bp.Trac
 
 	for result.Next() {
 		st := bp.Track{}
-		err = result.Scan(&st.Id, &st.SpotifyId, &st.Name, &st.Artist, &st.Album, &st.AddedAtToPlaylist, &st.Created, &st.PlaylistId)
+		err = scanTrack(result, &st, &st.PlaylistId)
 		if err != nil {
 			return
 		}
@@ -184,7 +579,8 @@ func (r *repository) GetBackupData(b *bp.Backup) (p *[]bp.Playlist, t *[]bp.Trac
 	yt = &ytlt
 
 	result, err = r.db.Query(
-		"SELECT id, youtube_id, name, created FROM youtube_playlists WHERE backup_id = ?",
+		`SELECT id, youtube_id, name, description, channel_id, channel_title, privacy_status, cover_url, cover_blob, position, created
+		FROM youtube_playlists WHERE backup_id = ? ORDER BY id`,
 		b.Id)
 	if err != nil {
 		return
@@ -193,7 +589,8 @@ func (r *repository) GetBackupData(b *bp.Backup) (p *[]bp.Playlist, t *[]bp.Trac
 
 	for result.Next() {
 		sp := bp.YoutubePlaylist{}
-		err = result.Scan(&sp.Id, &sp.YoutubeId, &sp.Name, &sp.Created)
+		err = result.Scan(&sp.Id, &sp.YoutubeId, &sp.Name, &sp.Description, &sp.ChannelId, &sp.ChannelTitle,
+			&sp.PrivacyStatus, &sp.CoverUrl, &sp.CoverBlob, &sp.Position, &sp.Created)
 		if err != nil {
 			return
 		}
@@ -202,7 +599,10 @@ func (r *repository) GetBackupData(b *bp.Backup) (p *[]bp.Playlist, t *[]bp.Trac
 	}
 
 	result, err = r.db.Query(
-		"SELECT id, youtube_id, name, channel_title, added_at_to_playlist, created, playlist_id FROM youtube_tracks WHERE backup_id = ?",
+		`SELECT `+youtubeTrackColumns("t")+`, p.id
+		FROM youtube_playlists p JOIN youtube_tracks t ON t.content_id = p.content_id
+		WHERE p.backup_id = ?
+		ORDER BY p.id, t.position`,
 		b.Id)
 	if err != nil {
 		return
@@ -211,7 +611,7 @@ func (r *repository) GetBackupData(b *bp.Backup) (p *[]bp.Playlist, t *[]bp.Trac
 
 	for result.Next() {
 		st := bp.YoutubeTrack{}
-		err = result.Scan(&st.Id, &st.YoutubeId, &st.Name, &st.ChannelTitle, &st.AddedAtToPlaylist, &st.Created, &st.PlaylistId)
+		err = scanYoutubeTrack(result, &st, &st.PlaylistId)
 		if err != nil {
 			return
 		}
@@ -219,5 +619,112 @@ func (r *repository) GetBackupData(b *bp.Backup) (p *[]bp.Playlist, t *[]bp.Trac
 		ytlt = append(ytlt, st)
 	}
 
+	l, err = r.getBackupLibrary(b)
+	return
+}
+
+// Lost tracks are tracks that are unavailable in the provided backup, but were
+// playable in an earlier backup of the same user. Returned track data is from
+// the latest backup where it was still playable.
+func (r *repository) GetLostTracks(b *bp.Backup) (t *[]bp.LostTrack, yt *[]bp.LostYoutubeTrack, err error) {
+	var lt []bp.LostTrack
+	var ylt []bp.LostYoutubeTrack
+	t = &lt
+	yt = &ylt
+
+	// Tracks removed from Spotify are returned without any data, including their id,
+	// so they are matched to earlier backups of the same playlist by when they were
+	// added and by their position instead.
+	result, err := r.db.Query(`
+		WITH current AS (
+			SELECT p.spotify_id playlist_id, p.name playlist_name, t.spotify_id, t.availability,
+				t.position, COALESCE(t.added_at_to_playlist, '') added_at
+			FROM playlists p JOIN tracks t ON t.content_id = p.content_id
+			WHERE p.backup_id = ?
+		),
+		lost AS (
+			SELECT DISTINCT playlist_id, playlist_name, spotify_id, availability
+			FROM current
+			WHERE availability NOT IN ('playable', 'local') AND spotify_id != ''
+		),
+		known AS (
+			SELECT t.*, b.started,
+				ROW_NUMBER() OVER (PARTITION BY t.spotify_id ORDER BY b.started DESC, t.id DESC) rn
+			FROM backups b
+			JOIN playlists p ON p.backup_id = b.id
+			JOIN tracks t ON t.content_id = p.content_id
+			WHERE b.user_id = ? AND b.started < ? AND t.availability = 'playable'
+				AND t.spotify_id IN (SELECT spotify_id FROM lost)
+		),
+		removed AS (
+			SELECT t.*, b.started, c.playlist_id lost_playlist_id, c.playlist_name lost_playlist_name, c.availability lost_availability,
+				ROW_NUMBER() OVER (PARTITION BY c.playlist_id, c.position
+					ORDER BY b.started DESC, ABS(t.position - c.position), t.id DESC) rn
+			FROM current c
+			JOIN playlists p ON p.spotify_id = c.playlist_id
+			JOIN backups b ON b.id = p.backup_id
+			JOIN tracks t ON t.content_id = p.content_id
+			WHERE c.availability = 'deleted' AND c.spotify_id = ''
+				AND b.user_id = ? AND b.started < ? AND t.availability = 'playable' AND t.spotify_id != ''
+				AND ((c.added_at != '' AND t.added_at_to_playlist = c.added_at)
+					OR (c.added_at = '' AND t.position = c.position))
+				AND t.spotify_id NOT IN (SELECT spotify_id FROM current WHERE playlist_id = c.playlist_id)
+		)
+		SELECT `+trackColumns("k")+`, l.playlist_id, l.playlist_name, l.availability, k.started
+		FROM lost l JOIN known k ON k.spotify_id = l.spotify_id AND k.rn = 1
+		UNION ALL
+		SELECT `+trackColumns("d")+`, d.lost_playlist_id, d.lost_playlist_name, d.lost_availability, d.started
+		FROM removed d WHERE d.rn = 1
+		ORDER BY playlist_name, playlist_id, name`,
+		b.Id, b.UserId, b.Started, b.UserId, b.Started)
+	if err != nil {
+		return
+	}
+	defer result.Close()
+
+	for result.Next() {
+		v := bp.LostTrack{}
+		err = scanTrack(result, &v.Track, &v.PlaylistId, &v.PlaylistName, &v.Availability, &v.LastSeen)
+		if err != nil {
+			return
+		}
+
+		lt = append(lt, v)
+	}
+
+	result, err = r.db.Query(`
+		WITH lost AS (
+			SELECT DISTINCT p.youtube_id playlist_id, p.name playlist_name, t.youtube_id, t.availability
+			FROM youtube_playlists p JOIN youtube_tracks t ON t.content_id = p.content_id
+			WHERE p.backup_id = ? AND t.availability NOT IN ('playable', 'local')
+		),
+		known AS (
+			SELECT t.*, b.started,
+				ROW_NUMBER() OVER (PARTITION BY t.youtube_id ORDER BY b.started DESC, t.id DESC) rn
+			FROM backups b
+			JOIN youtube_playlists p ON p.backup_id = b.id
+			JOIN youtube_tracks t ON t.content_id = p.content_id
+			WHERE b.user_id = ? AND b.started < ? AND t.availability = 'playable'
+				AND t.youtube_id IN (SELECT youtube_id FROM lost)
+		)
+		SELECT `+youtubeTrackColumns("k")+`, l.playlist_id, l.playlist_name, l.availability, k.started
+		FROM lost l JOIN known k ON k.youtube_id = l.youtube_id AND k.rn = 1
+		ORDER BY l.playlist_name, l.playlist_id, k.name`,
+		b.Id, b.UserId, b.Started)
+	if err != nil {
+		return
+	}
+	defer result.Close()
+
+	for result.Next() {
+		v := bp.LostYoutubeTrack{}
+		err = scanYoutubeTrack(result, &v.Track, &v.PlaylistId, &v.PlaylistName, &v.Availability, &v.LastSeen)
+		if err != nil {
+			return
+		}
+
+		ylt = append(ylt, v)
+	}
+
 	return
 }
@@ -26,11 +26,11 @@ func TestAddPlaylist(t *testing.T) {
 	err = r.AddBackup(&b)
 
 	p := bp.Playlist{SpotifyId: "S", Name: "N", Created: time.Unix(0, 0).UTC()}
-	err = r.AddPlaylist(&b, &p)
+	err = r.AddPlaylist(&b, &p, nil)
 	require.NoError(t, err)
 }
 
-func TestAddTrack(t *testing.T) {
+func TestAddPlaylistTracks(t *testing.T) {
 	r, err := NewRepository(":memory:")
 	require.NoError(t, err)
 
@@ -38,11 +38,124 @@ func TestAddTrack(t *testing.T) {
 	err = r.AddBackup(&b)
 
 	p := bp.Playlist{SpotifyId: "S", Name: "N", Created: time.Unix(0, 0).UTC()}
-	err = r.AddPlaylist(&b, &p)
+	tr := []bp.Track{{SpotifyId: "S", Name: "N", Artist: "Art", Album: "A", AddedAtToPlaylist: "now", Created: time.Unix(0, 0).UTC()}}
+	err = r.AddPlaylist(&b, &p, &tr)
+	require.NoError(t, err)
+	require.NotZero(t, tr[0].Id)
+	require.Equal(t, p.Id, tr[0].PlaylistId)
+}
+
+func TestAddPlaylistDeduplicatesTracks(t *testing.T) {
+	r, err := NewRepository(":memory:")
+	require.NoError(t, err)
+
+	b1 := bp.Backup{UserId: "User", Started: time.Unix(0, 0).UTC()}
+	err = r.AddBackup(&b1)
+	b2 := bp.Backup{UserId: "User", Started: time.Unix(100, 0).UTC()}
+	err = r.AddBackup(&b2)
+
+	p1 := bp.Playlist{SpotifyId: "S", Name: "N", Created: time.Unix(0, 0).UTC()}
+	tr1 := []bp.Track{
+		{SpotifyId: "S1", Name: "N1", Artist: "Art", Album: "A", AddedAtToPlaylist: "now", Created: time.Unix(0, 0).UTC()},
+		{SpotifyId: "S2", Name: "N2", Artist: "Art", Album: "A", AddedAtToPlaylist: "now", Created: time.Unix(0, 0).UTC()},
+	}
+	err = r.AddPlaylist(&b1, &p1, &tr1)
+	require.NoError(t, err)
+
+	p2 := bp.Playlist{SpotifyId: "S", Name: "Renamed", Created: time.Unix(100, 0).UTC()}
+	tr2 := []bp.Track{
+		{SpotifyId: "S1", Name: "N1", Artist: "Art", Album: "A", AddedAtToPlaylist: "now", Created: time.Unix(100, 0).UTC()},
+		{SpotifyId: "S2", Name: "N2", Artist: "Art", Album: "A", AddedAtToPlaylist: "now", Created: time.Unix(100, 0).UTC()},
+	}
+	err = r.AddPlaylist(&b2, &p2, &tr2)
+	require.NoError(t, err)
+	require.Equal(t, tr1[0].Id, tr2[0].Id)
+	require.Equal(t, tr1[1].Id, tr2[1].Id)
 
-	tr := bp.Track{SpotifyId: "S", Name: "N", Artist: "Art", Album: "A", AddedAtToPlaylist: "now", Created: time.Unix(0, 0).UTC()}
-	err = r.AddTrack(&b, &p, &tr)
+	rr := r.(*repository)
+	var stored int64
+	err = rr.db.QueryRow("SELECT count(*) FROM tracks").Scan(&stored)
 	require.NoError(t, err)
+	require.EqualValues(t, 2, stored)
+
+	count, err := r.GetBackupTrackCount(&b2, bp.ScopeAll)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, count)
+
+	sp, st, _, _, _, err := r.GetBackupData(&b2)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, len(*sp))
+	require.Equal(t, "Renamed", (*sp)[0].Name)
+	require.EqualValues(t, 2, len(*st))
+	require.Equal(t, "S1", (*st)[0].SpotifyId)
+	require.Equal(t, p2.Id, (*st)[0].PlaylistId)
+	require.Equal(t, "S2", (*st)[1].SpotifyId)
+}
+
+func TestAddPlaylistChangedTracks(t *testing.T) {
+	r, err := NewRepository(":memory:")
+	require.NoError(t, err)
+
+	b := bp.Backup{UserId: "User", Started: time.Unix(0, 0).UTC()}
+	err = r.AddBackup(&b)
+
+	p1 := bp.Playlist{SpotifyId: "S", Name: "N", Created: time.Unix(0, 0).UTC()}
+	tr1 := []bp.Track{{SpotifyId: "S1", Name: "N1", Artist: "Art", Album: "A", AddedAtToPlaylist: "now", Created: time.Unix(0, 0).UTC()}}
+	err = r.AddPlaylist(&b, &p1, &tr1)
+	require.NoError(t, err)
+
+	p2 := bp.Playlist{SpotifyId: "S", Name: "N", Created: time.Unix(0, 0).UTC()}
+	tr2 := []bp.Track{{SpotifyId: "S2", Name: "N1", Artist: "Art", Album: "A", AddedAtToPlaylist: "now", Created: time.Unix(0, 0).UTC()}}
+	err = r.AddPlaylist(&b, &p2, &tr2)
+	require.NoError(t, err)
+	require.NotEqual(t, tr1[0].Id, tr2[0].Id)
+}
+
+func TestAddPlaylistFromSnapshot(t *testing.T) {
+	r, err := NewRepository(":memory:")
+	require.NoError(t, err)
+
+	b1 := bp.Backup{UserId: "User", Started: time.Unix(0, 0).UTC()}
+	err = r.AddBackup(&b1)
+	b2 := bp.Backup{UserId: "User", Started: time.Unix(100, 0).UTC()}
+	err = r.AddBackup(&b2)
+
+	p := bp.Playlist{SpotifyId: "S", SnapshotId: "snap", Name: "N", Description: "D", CoverBlob: "cover", Created: time.Unix(0, 0).UTC()}
+	ok, err := r.AddPlaylistFromSnapshot(&b1, &p)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	tr := []bp.Track{{SpotifyId: "S1", Name: "N1", Artist: "Art", Album: "A", AddedAtToPlaylist: "now", Created: time.Unix(0, 0).UTC()}}
+	err = r.AddPlaylist(&b1, &p, &tr)
+	require.NoError(t, err)
+
+	other := bp.Playlist{SpotifyId: "S", SnapshotId: "other", Name: "N", Created: time.Unix(100, 0).UTC()}
+	ok, err = r.AddPlaylistFromSnapshot(&b2, &other)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	same := bp.Playlist{SpotifyId: "S", SnapshotId: "snap", Name: "N", Created: time.Unix(100, 0).UTC()}
+	ok, err = r.AddPlaylistFromSnapshot(&b2, &same)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, "D", same.Description)
+	require.Equal(t, "cover", same.CoverBlob)
+
+	sp, st, _, _, _, err := r.GetBackupData(&b2)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, len(*sp))
+	require.Equal(t, same, (*sp)[0])
+	require.EqualValues(t, 1, len(*st))
+	require.Equal(t, tr[0].Id, (*st)[0].Id)
+	require.Equal(t, same.Id, (*st)[0].PlaylistId)
+
+	same.CoverBlob = "new"
+	err = r.SetPlaylistCover(&same)
+	require.NoError(t, err)
+
+	sp, _, _, _, _, err = r.GetBackupData(&b2)
+	require.NoError(t, err)
+	require.Equal(t, "new", (*sp)[0].CoverBlob)
 }
 
 func TestGetBackupCount(t *testing.T) {
@@ -74,12 +187,11 @@ func TestGetBackupStatsSpotify(t *testing.T) {
 	err = r.AddBackup(&b)
 
 	p := bp.Playlist{SpotifyId: "S", Name: "N", Created: time.Unix(0, 0).UTC()}
-	err = r.AddPlaylist(&b, &p)
-
-	tr := bp.Track{SpotifyId: "S", Name: "N", Artist: "Art", Album: "A", AddedAtToPlaylist: "now", Created: time.Unix(0, 0).UTC(), PlaylistId: p.Id}
-	err = r.AddTrack(&b, &p, &tr)
+	trs := []bp.Track{{SpotifyId: "S", Name: "N", Artist: "Art", Album: "A", AddedAtToPlaylist: "now", Created: time.Unix(0, 0).UTC()}}
+	err = r.AddPlaylist(&b, &p, &trs)
+	tr := trs[0]
 
-	sp, st, yp, yt, err := r.GetBackupData(&b)
+	sp, st, yp, yt, _, err := r.GetBackupData(&b)
 	require.NoError(t, err)
 
 	require.EqualValues(t, 1, len(*sp))
@@ -98,11 +210,11 @@ func TestAddYoutubePlaylist(t *testing.T) {
 	err = r.AddBackup(&b)
 
 	p := bp.YoutubePlaylist{YoutubeId: "S", Name: "N", Created: time.Unix(0, 0).UTC()}
-	err = r.AddYoutubePlaylist(&b, &p)
+	err = r.AddYoutubePlaylist(&b, &p, nil)
 	require.NoError(t, err)
 }
 
-func TestAddYoutubeTrack(t *testing.T) {
+func TestAddYoutubePlaylistTracks(t *testing.T) {
 	r, err := NewRepository(":memory:")
 	require.NoError(t, err)
 
@@ -110,11 +222,36 @@ func TestAddYoutubeTrack(t *testing.T) {
 	err = r.AddBackup(&b)
 
 	p := bp.YoutubePlaylist{YoutubeId: "S", Name: "N", Created: time.Unix(0, 0).UTC()}
-	err = r.AddYoutubePlaylist(&b, &p)
+	trs := []bp.YoutubeTrack{{YoutubeId: "S", Name: "N", ChannelTitle: "A", AddedAtToPlaylist: "now", Created: time.Unix(0, 0).UTC()}}
+	err = r.AddYoutubePlaylist(&b, &p, &trs)
+	require.NoError(t, err)
+	require.NotZero(t, trs[0].Id)
+	require.Equal(t, p.Id, trs[0].PlaylistId)
+}
 
-	tr := bp.YoutubeTrack{YoutubeId: "S", Name: "N", ChannelTitle: "A", AddedAtToPlaylist: "now", Created: time.Unix(0, 0).UTC()}
-	err = r.AddYoutubeTrack(&b, &p, &tr)
+func TestAddYoutubePlaylistDeduplicatesTracks(t *testing.T) {
+	r, err := NewRepository(":memory:")
 	require.NoError(t, err)
+
+	b1 := bp.Backup{UserId: "User", Started: time.Unix(0, 0).UTC()}
+	err = r.AddBackup(&b1)
+	b2 := bp.Backup{UserId: "User", Started: time.Unix(100, 0).UTC()}
+	err = r.AddBackup(&b2)
+
+	p1 := bp.YoutubePlaylist{YoutubeId: "S", Name: "N", Created: time.Unix(0, 0).UTC()}
+	tr1 := []bp.YoutubeTrack{{YoutubeId: "S", Name: "N", ChannelTitle: "A", AddedAtToPlaylist: "now", Created: time.Unix(0, 0).UTC()}}
+	err = r.AddYoutubePlaylist(&b1, &p1, &tr1)
+	require.NoError(t, err)
+
+	p2 := bp.YoutubePlaylist{YoutubeId: "S", Name: "N", Created: time.Unix(100, 0).UTC()}
+	tr2 := []bp.YoutubeTrack{{YoutubeId: "S", Name: "N", ChannelTitle: "A", AddedAtToPlaylist: "now", Created: time.Unix(100, 0).UTC()}}
+	err = r.AddYoutubePlaylist(&b2, &p2, &tr2)
+	require.NoError(t, err)
+	require.Equal(t, tr1[0].Id, tr2[0].Id)
+
+	count, err := r.GetBackupTrackCount(&b2, bp.ScopeAll)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, count)
 }
 
 func TestGetBackupStatsYoutube(t *testing.T) {
@@ -125,12 +262,11 @@ func TestGetBackupStatsYoutube(t *testing.T) {
 	err = r.AddBackup(&b)
 
 	p := bp.YoutubePlaylist{YoutubeId: "S", Name: "N", Created: time.Unix(0, 0).UTC()}
-	err = r.AddYoutubePlaylist(&b, &p)
+	trs := []bp.YoutubeTrack{{YoutubeId: "S", Name: "N", ChannelTitle: "A", AddedAtToPlaylist: "now", Created: time.Unix(0, 0).UTC()}}
+	err = r.AddYoutubePlaylist(&b, &p, &trs)
+	tr := trs[0]
 
-	tr := bp.YoutubeTrack{YoutubeId: "S", Name: "N", ChannelTitle: "A", AddedAtToPlaylist: "now", Created: time.Unix(0, 0).UTC(), PlaylistId: p.Id}
-	err = r.AddYoutubeTrack(&b, &p, &tr)
-
-	sp, st, yp, yt, err := r.GetBackupData(&b)
+	sp, st, yp, yt, _, err := r.GetBackupData(&b)
 	require.NoError(t, err)
 
 	require.EqualValues(t, 0, len(*sp))
@@ -149,18 +285,16 @@ func TestGetBackupStats(t *testing.T) {
 	err = r.AddBackup(&b)
 
 	sp := bp.Playlist{SpotifyId: "S", Name: "N", Created: time.Unix(0, 0).UTC()}
-	err = r.AddPlaylist(&b, &sp)
-
-	str := bp.Track{SpotifyId: "S", Name: "N", Artist: "Art", Album: "A", AddedAtToPlaylist: "now", Created: time.Unix(0, 0).UTC(), PlaylistId: sp.Id}
-	err = r.AddTrack(&b, &sp, &str)
+	strs := []bp.Track{{SpotifyId: "S", Name: "N", Artist: "Art", Album: "A", AddedAtToPlaylist: "now", Created: time.Unix(0, 0).UTC()}}
+	err = r.AddPlaylist(&b, &sp, &strs)
+	str := strs[0]
 
 	p := bp.YoutubePlaylist{YoutubeId: "S", Name: "N", Created: time.Unix(0, 0).UTC()}
-	err = r.AddYoutubePlaylist(&b, &p)
-
-	tr := bp.YoutubeTrack{YoutubeId: "S", Name: "N", ChannelTitle: "A", AddedAtToPlaylist: "now", Created: time.Unix(0, 0).UTC(), PlaylistId: p.Id}
-	err = r.AddYoutubeTrack(&b, &p, &tr)
+	trs := []bp.YoutubeTrack{{YoutubeId: "S", Name: "N", ChannelTitle: "A", AddedAtToPlaylist: "now", Created: time.Unix(0, 0).UTC()}}
+	err = r.AddYoutubePlaylist(&b, &p, &trs)
+	tr := trs[0]
 
-	rsp, rst, ryp, ryt, err := r.GetBackupData(&b)
+	rsp, rst, ryp, ryt, _, err := r.GetBackupData(&b)
 	require.NoError(t, err)
 
 	require.EqualValues(t, 1, len(*rsp))
@@ -181,15 +315,15 @@ func TestGetBackupPlaylistCount(t *testing.T) {
 	err = r.AddBackup(&b)
 
 	sp := bp.Playlist{SpotifyId: "S", Name: "N", Created: time.Unix(0, 0).UTC()}
-	err = r.AddPlaylist(&b, &sp)
+	err = r.AddPlaylist(&b, &sp, nil)
 
 	p := bp.YoutubePlaylist{YoutubeId: "S", Name: "N", Created: time.Unix(0, 0).UTC()}
-	err = r.AddYoutubePlaylist(&b, &p)
+	err = r.AddYoutubePlaylist(&b, &p, nil)
 
 	p = bp.YoutubePlaylist{YoutubeId: "S2", Name: "N", Created: time.Unix(0, 0).UTC()}
-	err = r.AddYoutubePlaylist(&b, &p)
+	err = r.AddYoutubePlaylist(&b, &p, nil)
 
-	count, err := r.GetBackupPlaylistCount(&b)
+	count, err := r.GetBackupPlaylistCount(&b, bp.ScopeAll)
 	require.NoError(t, err)
 
 	require.EqualValues(t, 3, count)
@@ -203,21 +337,17 @@ func TestGetBackupTrackCount(t *testing.T) {
 	err = r.AddBackup(&b)
 
 	sp := bp.Playlist{SpotifyId: "S", Name: "N", Created: time.Unix(0, 0).UTC()}
-	err = r.AddPlaylist(&b, &sp)
-
-	str := bp.Track{SpotifyId: "S", Name: "N", Artist: "Art", Album: "A", AddedAtToPlaylist: "now", Created: time.Unix(0, 0).UTC(), PlaylistId: sp.Id}
-	err = r.AddTrack(&b, &sp, &str)
-
-	str = bp.Track{SpotifyId: "S2", Name: "N", Artist: "Art", Album: "A", AddedAtToPlaylist: "now", Created: time.Unix(0, 0).UTC(), PlaylistId: sp.Id}
-	err = r.AddTrack(&b, &sp, &str)
+	strs := []bp.Track{
+		{SpotifyId: "S", Name: "N", Artist: "Art", Album: "A", AddedAtToPlaylist: "now", Created: time.Unix(0, 0).UTC()},
+		{SpotifyId: "S2", Name: "N", Artist: "Art", Album: "A", AddedAtToPlaylist: "now", Created: time.Unix(0, 0).UTC()},
+	}
+	err = r.AddPlaylist(&b, &sp, &strs)
 
 	p := bp.YoutubePlaylist{YoutubeId: "S", Name: "N", Created: time.Unix(0, 0).UTC()}
-	err = r.AddYoutubePlaylist(&b, &p)
+	trs := []bp.YoutubeTrack{{YoutubeId: "S", Name: "N", ChannelTitle: "A", AddedAtToPlaylist: "now", Created: time.Unix(0, 0).UTC()}}
+	err = r.AddYoutubePlaylist(&b, &p, &trs)
 
-	tr := bp.YoutubeTrack{YoutubeId: "S", Name: "N", ChannelTitle: "A", AddedAtToPlaylist: "now", Created: time.Unix(0, 0).UTC(), PlaylistId: p.Id}
-	err = r.AddYoutubeTrack(&b, &p, &tr)
-
-	count, err := r.GetBackupTrackCount(&b)
+	count, err := r.GetBackupTrackCount(&b, bp.ScopeAll)
 	require.NoError(t, err)
 
 	require.EqualValues(t, 3, count)
@@ -236,8 +366,322 @@ func TestGetLastBackup(t *testing.T) {
 	b3 := bp.Backup{UserId: "User2", Started: time.Unix(500, 0).UTC()}
 	err = r.AddBackup(&b3)
 
-	lastBackup, err := r.GetLastBackup("User")
+	lastBackup, err := r.GetLastBackup("User", bp.ScopeAll)
+	require.NoError(t, err)
+
+	require.Equal(t, b2, *lastBackup)
+	require.Equal(t, bp.ScopeAll, lastBackup.Scope)
+
+	// partial backup is only returned for its platform
+	b4 := bp.Backup{UserId: "User", Started: time.Unix(300, 0).UTC(), Scope: bp.ScopeYoutube}
+	err = r.AddBackup(&b4)
+	require.NoError(t, err)
+
+	lastBackup, err = r.GetLastBackup("User", bp.ScopeAll)
+	require.NoError(t, err)
+	require.Equal(t, b4, *lastBackup)
+
+	lastBackup, err = r.GetLastBackup("User", bp.ScopeYoutube)
 	require.NoError(t, err)
+	require.Equal(t, b4, *lastBackup)
 
+	lastBackup, err = r.GetLastBackup("User", bp.ScopeSpotify)
+	require.NoError(t, err)
 	require.Equal(t, b2, *lastBackup)
+
+	lastBackup, err = r.GetLastBackup("Nobody", bp.ScopeSpotify)
+	require.NoError(t, err)
+	require.Equal(t, int64(0), lastBackup.Id)
+}
+
+func TestGetBackupCountsByPlatform(t *testing.T) {
+	r, err := NewRepository(":memory:")
+	require.NoError(t, err)
+
+	b := bp.Backup{UserId: "User", Started: time.Unix(100, 0).UTC()}
+	err = r.AddBackup(&b)
+	require.NoError(t, err)
+
+	tracks := []bp.Track{{SpotifyId: "S1", Name: "N1", Created: time.Unix(0, 0).UTC()}, {SpotifyId: "S2", Name: "N2", Created: time.Unix(0, 0).UTC()}}
+	err = r.AddPlaylist(&b, &bp.Playlist{SpotifyId: "S", Name: "N", Created: time.Unix(0, 0).UTC()}, &tracks)
+	require.NoError(t, err)
+	yt := []bp.YoutubeTrack{{YoutubeId: "Y", Name: "N", Created: time.Unix(0, 0).UTC()}}
+	err = r.AddYoutubePlaylist(&b, &bp.YoutubePlaylist{YoutubeId: "Y", Name: "N", Created: time.Unix(0, 0).UTC()}, &yt)
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		platform  bp.Scope
+		playlists int64
+		tracks    int64
+	}{
+		{bp.ScopeAll, 2, 3},
+		{bp.ScopeSpotify, 1, 2},
+		{bp.ScopeYoutube, 1, 1},
+	} {
+		playlists, err := r.GetBackupPlaylistCount(&b, tc.platform)
+		require.NoError(t, err)
+		require.Equal(t, tc.playlists, playlists, tc.platform)
+
+		tracks, err := r.GetBackupTrackCount(&b, tc.platform)
+		require.NoError(t, err)
+		require.Equal(t, tc.tracks, tracks, tc.platform)
+	}
+}
+
+func TestGetBackup(t *testing.T) {
+	r, err := NewRepository(":memory:")
+	require.NoError(t, err)
+
+	b := bp.Backup{UserId: "User", Started: time.Unix(100, 0).UTC(), Scope: bp.ScopeSpotify}
+	err = r.AddBackup(&b)
+	require.NoError(t, err)
+
+	found, err := r.GetBackup(b.Id)
+	require.NoError(t, err)
+	require.Equal(t, b, *found)
+
+	_, err = r.GetBackup(b.Id + 1)
+	require.ErrorIs(t, err, bp.ErrBackupNotFound)
+}
+
+func TestGetLostTracks(t *testing.T) {
+	r, err := NewRepository(":memory:")
+	require.NoError(t, err)
+
+	b1 := bp.Backup{UserId: "User", Started: time.Unix(100, 0).UTC()}
+	err = r.AddBackup(&b1)
+	b2 := bp.Backup{UserId: "User", Started: time.Unix(200, 0).UTC()}
+	err = r.AddBackup(&b2)
+
+	p1 := bp.Playlist{SpotifyId: "P", Name: "N", Created: time.Unix(100, 0).UTC()}
+	tr1 := []bp.Track{
+		{SpotifyId: "S1", Name: "Original", Artist: "Art", Album: "A", Availability: bp.AvailabilityPlayable, Created: time.Unix(100, 0).UTC()},
+		{SpotifyId: "S2", Name: "Kept", Artist: "Art", Album: "A", Availability: bp.AvailabilityPlayable, Created: time.Unix(100, 0).UTC()},
+		{SpotifyId: "", Name: "Local", Artist: "Art", Album: "A", Availability: bp.AvailabilityLocal, Created: time.Unix(100, 0).UTC()},
+	}
+	err = r.AddPlaylist(&b1, &p1, &tr1)
+	require.NoError(t, err)
+
+	yp1 := bp.YoutubePlaylist{YoutubeId: "Y", Name: "N", Created: time.Unix(100, 0).UTC()}
+	ytr1 := []bp.YoutubeTrack{{YoutubeId: "Y1", Name: "Song", ChannelTitle: "C", Availability: bp.AvailabilityPlayable, Created: time.Unix(100, 0).UTC()}}
+	err = r.AddYoutubePlaylist(&b1, &yp1, &ytr1)
+	require.NoError(t, err)
+
+	p2 := bp.Playlist{SpotifyId: "P", Name: "N", Created: time.Unix(200, 0).UTC()}
+	tr2 := []bp.Track{
+		{SpotifyId: "S1", Name: "Changed", Artist: "Art", Album: "A", Availability: bp.AvailabilityRegionRestricted, Created: time.Unix(200, 0).UTC()},
+		{SpotifyId: "S2", Name: "Kept", Artist: "Art", Album: "A", Availability: bp.AvailabilityPlayable, Created: time.Unix(200, 0).UTC()},
+		{SpotifyId: "", Name: "Local", Artist: "Art", Album: "A", Availability: bp.AvailabilityLocal, Created: time.Unix(200, 0).UTC()},
+	}
+	err = r.AddPlaylist(&b2, &p2, &tr2)
+	require.NoError(t, err)
+
+	yp2 := bp.YoutubePlaylist{YoutubeId: "Y", Name: "N", Created: time.Unix(200, 0).UTC()}
+	ytr2 := []bp.YoutubeTrack{{YoutubeId: "Y1", Name: "Deleted video", ChannelTitle: "", Availability: bp.AvailabilityDeleted, Created: time.Unix(200, 0).UTC()}}
+	err = r.AddYoutubePlaylist(&b2, &yp2, &ytr2)
+	require.NoError(t, err)
+
+	lost, ylost, err := r.GetLostTracks(&b2)
+	require.NoError(t, err)
+
+	require.Len(t, *lost, 1)
+	require.Equal(t, "Original", (*lost)[0].Track.Name)
+	require.Equal(t, bp.AvailabilityRegionRestricted, (*lost)[0].Availability)
+	require.Equal(t, b1.Started, (*lost)[0].LastSeen.UTC())
+	require.Equal(t, "P", (*lost)[0].PlaylistId)
+
+	require.Len(t, *ylost, 1)
+	require.Equal(t, "Song", (*ylost)[0].Track.Name)
+	require.Equal(t, "C", (*ylost)[0].Track.ChannelTitle)
+	require.Equal(t, bp.AvailabilityDeleted, (*ylost)[0].Availability)
+
+	// nothing was playable before the first backup
+	lost, ylost, err = r.GetLostTracks(&b1)
+	require.NoError(t, err)
+	require.Len(t, *lost, 0)
+	require.Len(t, *ylost, 0)
+}
+
+func TestGetLostTracksRemovedFromSpotify(t *testing.T) {
+	r, err := NewRepository(":memory:")
+	require.NoError(t, err)
+
+	b1 := bp.Backup{UserId: "User", Started: time.Unix(100, 0).UTC()}
+	err = r.AddBackup(&b1)
+	require.NoError(t, err)
+	b2 := bp.Backup{UserId: "User", Started: time.Unix(200, 0).UTC()}
+	err = r.AddBackup(&b2)
+	require.NoError(t, err)
+
+	created := time.Unix(100, 0).UTC()
+	p1 := bp.Playlist{SpotifyId: "P", Name: "N", Created: created}
+	tr1 := []bp.Track{
+		{SpotifyId: "S1", Uri: "spotify:track:S1", Isrc: "I1", Name: "Removed", Artist: "Art", Album: "A", AddedAtToPlaylist: "2021-01-01T00:00:00Z", Availability: bp.AvailabilityPlayable, Created: created},
+		{SpotifyId: "S2", Name: "Kept", Artist: "Art", Album: "A", AddedAtToPlaylist: "2021-01-01T00:00:00Z", Availability: bp.AvailabilityPlayable, Created: created},
+		{SpotifyId: "S3", Name: "No added at", Artist: "Art", Album: "A", Availability: bp.AvailabilityPlayable, Created: created},
+	}
+	err = r.AddPlaylist(&b1, &p1, &tr1)
+	require.NoError(t, err)
+
+	// a new track is inserted at the start, so positions of the earlier ones shift
+	created = time.Unix(200, 0).UTC()
+	p2 := bp.Playlist{SpotifyId: "P", Name: "N", Created: created}
+	tr2 := []bp.Track{
+		{SpotifyId: "S4", Name: "New", Artist: "Art", Album: "A", AddedAtToPlaylist: "2021-02-01T00:00:00Z", Availability: bp.AvailabilityPlayable, Created: created},
+		{SpotifyId: "", AddedAtToPlaylist: "2021-01-01T00:00:00Z", Availability: bp.AvailabilityDeleted, Created: created},
+		{SpotifyId: "S2", Name: "Kept", Artist: "Art", Album: "A", AddedAtToPlaylist: "2021-01-01T00:00:00Z", Availability: bp.AvailabilityPlayable, Created: created},
+		{SpotifyId: "", Availability: bp.AvailabilityDeleted, Created: created},
+	}
+	err = r.AddPlaylist(&b2, &p2, &tr2)
+	require.NoError(t, err)
+
+	lost, _, err := r.GetLostTracks(&b2)
+	require.NoError(t, err)
+
+	require.Len(t, *lost, 1)
+	require.Equal(t, "Removed", (*lost)[0].Track.Name)
+	require.Equal(t, "spotify:track:S1", (*lost)[0].Track.Uri)
+	require.Equal(t, "I1", (*lost)[0].Track.Isrc)
+	require.Equal(t, bp.AvailabilityDeleted, (*lost)[0].Availability)
+	require.Equal(t, b1.Started, (*lost)[0].LastSeen.UTC())
+	require.Equal(t, "P", (*lost)[0].PlaylistId)
+
+	// without added at tracks are matched by position
+	tr3 := []bp.Track{
+		{SpotifyId: "S1", Name: "Removed", Artist: "Art", Album: "A", AddedAtToPlaylist: "2021-01-01T00:00:00Z", Availability: bp.AvailabilityPlayable, Created: created},
+		{SpotifyId: "S2", Name: "Kept", Artist: "Art", Album: "A", AddedAtToPlaylist: "2021-01-01T00:00:00Z", Availability: bp.AvailabilityPlayable, Created: created},
+		{SpotifyId: "", Availability: bp.AvailabilityDeleted, Created: created},
+	}
+	b3 := bp.Backup{UserId: "User", Started: time.Unix(300, 0).UTC()}
+	err = r.AddBackup(&b3)
+	require.NoError(t, err)
+	p3 := bp.Playlist{SpotifyId: "P", Name: "N", Created: created}
+	err = r.AddPlaylist(&b3, &p3, &tr3)
+	require.NoError(t, err)
+
+	lost, _, err = r.GetLostTracks(&b3)
+	require.NoError(t, err)
+	require.Len(t, *lost, 1)
+	require.Equal(t, "No added at", (*lost)[0].Track.Name)
+	require.Equal(t, b1.Started, (*lost)[0].LastSeen.UTC())
+}
+
+func TestAddPlaylistTrackMetadata(t *testing.T) {
+	r, err := NewRepository(":memory:")
+	require.NoError(t, err)
+
+	b := bp.Backup{UserId: "User", Started: time.Unix(0, 0).UTC()}
+	err = r.AddBackup(&b)
+
+	p := bp.Playlist{SpotifyId: "S", Name: "N", Created: time.Unix(0, 0).UTC()}
+	tr := []bp.Track{{
+		SpotifyId:         "S",
+		Uri:               "spotify:track:S",
+		Isrc:              "ISRC",
+		Name:              "N",
+		Artist:            "Art 1, Art 2",
+		ArtistIds:         []string{"A1", "A2"},
+		Album:             "A",
+		AlbumArtist:       "Art 1",
+		AlbumReleaseDate:  "2020",
+		DurationMs:        1000,
+		TrackNumber:       2,
+		DiscNumber:        1,
+		Explicit:          true,
+		AddedBy:           "User",
+		AddedAtToPlaylist: "now",
+		Availability:      bp.AvailabilityPlayable,
+		Created:           time.Unix(0, 0).UTC(),
+	}}
+	err = r.AddPlaylist(&b, &p, &tr)
+	require.NoError(t, err)
+
+	_, st, _, _, _, err := r.GetBackupData(&b)
+	require.NoError(t, err)
+	require.Equal(t, tr, *st)
+}
+
+func TestAddPlaylistMetadata(t *testing.T) {
+	r, err := NewRepository(":memory:")
+	require.NoError(t, err)
+
+	b := bp.Backup{UserId: "User", Started: time.Unix(0, 0).UTC()}
+	err = r.AddBackup(&b)
+
+	p := bp.Playlist{
+		SpotifyId:     "S",
+		SnapshotId:    "snap",
+		Name:          "N",
+		Description:   "D",
+		OwnerId:       "O",
+		OwnerName:     "Owner",
+		Collaborative: true,
+		Public:        true,
+		CoverUrl:      "https://example.com/cover.jpg",
+		CoverBlob:     "blob",
+		Position:      3,
+		Created:       time.Unix(0, 0).UTC(),
+	}
+	err = r.AddPlaylist(&b, &p, nil)
+	require.NoError(t, err)
+
+	yp := bp.YoutubePlaylist{
+		YoutubeId:     "Y",
+		Name:          "N",
+		Description:   "D",
+		ChannelId:     "C",
+		ChannelTitle:  "Channel",
+		PrivacyStatus: "unlisted",
+		CoverUrl:      "https://example.com/cover.jpg",
+		CoverBlob:     "blob",
+		Position:      1,
+		Created:       time.Unix(0, 0).UTC(),
+	}
+	err = r.AddYoutubePlaylist(&b, &yp, nil)
+	require.NoError(t, err)
+
+	sp, _, ryp, _, _, err := r.GetBackupData(&b)
+	require.NoError(t, err)
+	require.Equal(t, []bp.Playlist{p}, *sp)
+	require.Equal(t, []bp.YoutubePlaylist{yp}, *ryp)
+}
+
+func TestAddYoutubePlaylistTrackMetadata(t *testing.T) {
+	r, err := NewRepository(":memory:")
+	require.NoError(t, err)
+
+	b := bp.Backup{UserId: "User", Started: time.Unix(0, 0).UTC()}
+	err = r.AddBackup(&b)
+
+	p := bp.YoutubePlaylist{YoutubeId: "Y", Name: "N", Created: time.Unix(0, 0).UTC()}
+	tr := []bp.YoutubeTrack{
+		{
+			YoutubeId:         "V1",
+			Name:              "N",
+			ChannelId:         "C",
+			ChannelTitle:      "Channel",
+			Description:       "D",
+			Tags:              []string{"a, b", "c"},
+			CategoryId:        "10",
+			DurationSeconds:   180,
+			PublishedAt:       "published",
+			PrivacyStatus:     "public",
+			Thumbnails:        map[string]string{"high": "https://example.com/high.jpg"},
+			MusicTitle:        "Song",
+			MusicArtist:       "Artist",
+			MusicAlbum:        "Album",
+			MusicLabel:        "Label",
+			MusicReleaseDate:  "2020-01-01",
+			AddedAtToPlaylist: "now",
+			Availability:      bp.AvailabilityPlayable,
+			Created:           time.Unix(0, 0).UTC(),
+		},
+		{YoutubeId: "V2", Name: "Deleted video", AddedAtToPlaylist: "now", Availability: bp.AvailabilityDeleted, Created: time.Unix(0, 0).UTC()},
+	}
+	err = r.AddYoutubePlaylist(&b, &p, &tr)
+	require.NoError(t, err)
+
+	_, _, _, yt, _, err := r.GetBackupData(&b)
+	require.NoError(t, err)
+	require.Equal(t, tr, *yt)
 }
@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+
+	bp "github.com/hoffs/crispy-musicular/pkg/backup"
+)
+
+// Returns backups of the user newest first, negative limit returns all of them
+func (r *repository) GetBackupsPage(userId string, offset int, limit int) (b *[]bp.Backup, err error) {
+	rows, err := r.db.Query("SELECT id, success, started, finished, scope FROM backups WHERE user_id = ? ORDER BY started DESC LIMIT ? OFFSET ?", userId, limit, offset)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	backups := []bp.Backup{}
+	for rows.Next() {
+		v := bp.Backup{UserId: userId}
+		var finished sql.NullTime
+		var ok sql.NullBool
+		err = rows.Scan(&v.Id, &ok, &v.Started, &finished, &v.Scope)
+		if err != nil {
+			return
+		}
+
+		v.Finished = finished.Time
+		v.Success = ok.Bool
+		backups = append(backups, v)
+	}
+
+	return &backups, rows.Err()
+}
+
+// Spotify and Youtube playlists of a backup, backup id has to be provided for both
+const playlistSummariesSql = `
+	SELECT * FROM (
+		SELECT 'spotify' platform, p.spotify_id id, p.name, p.description, p.owner_name, p.cover_url, p.position, p.id row_id,
+			(SELECT count(*) FROM tracks t WHERE t.content_id = p.content_id)
+		FROM playlists p WHERE p.backup_id = ?
+		UNION ALL
+		SELECT 'youtube', p.youtube_id, p.name, p.description, p.channel_title, p.cover_url, p.position, p.id,
+			(SELECT count(*) FROM youtube_tracks t WHERE t.content_id = p.content_id)
+		FROM youtube_playlists p WHERE p.backup_id = ?
+	)`
+
+// Returns Spotify playlists followed by Youtube playlists of the backup, in the order they were saved.
+// Negative limit returns all of them.
+func (r *repository) GetPlaylistSummaries(b *bp.Backup, offset int, limit int) (p *[]bp.PlaylistSummary, err error) {
+	rows, err := r.db.Query(playlistSummariesSql+" ORDER BY platform, position, row_id LIMIT ? OFFSET ?", b.Id, b.Id, limit, offset)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	summaries := []bp.PlaylistSummary{}
+	for rows.Next() {
+		var v bp.PlaylistSummary
+		err = scanPlaylistSummary(rows, &v)
+		if err != nil {
+			return
+		}
+
+		summaries = append(summaries, v)
+	}
+
+	return &summaries, rows.Err()
+}
+
+func (r *repository) GetPlaylistSummary(b *bp.Backup, platform string, id string) (p *bp.PlaylistSummary, err error) {
+	row := r.db.QueryRow(playlistSummariesSql+" WHERE platform = ? AND id = ? ORDER BY row_id LIMIT 1", b.Id, b.Id, platform, id)
+
+	p = &bp.PlaylistSummary{}
+	err = scanPlaylistSummary(row, p)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, bp.ErrPlaylistNotFound
+	}
+
+	return
+}
+
+// Either *sql.Row or *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanPlaylistSummary(row rowScanner, v *bp.PlaylistSummary) error {
+	var id int64
+	return row.Scan(&v.Platform, &v.Id, &v.Name, &v.Description, &v.Owner, &v.CoverUrl, &v.Position, &id, &v.TrackCount)
+}
+
+// Returns tracks of the playlist in the backup in playlist order, negative limit returns all of them
+func (r *repository) GetPlaylistTracks(b *bp.Backup, spotifyId string, offset int, limit int) (t *[]bp.Track, err error) {
+	rows, err := r.db.Query(
+		`SELECT `+trackColumns("t")+`
+		FROM tracks t
+		WHERE t.content_id = (SELECT content_id FROM playlists WHERE backup_id = ? AND spotify_id = ? ORDER BY id LIMIT 1)
+		ORDER BY t.position LIMIT ? OFFSET ?`,
+		b.Id, spotifyId, limit, offset)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	tracks := []bp.Track{}
+	for rows.Next() {
+		var v bp.Track
+		err = scanTrack(rows, &v)
+		if err != nil {
+			return
+		}
+
+		tracks = append(tracks, v)
+	}
+
+	return &tracks, rows.Err()
+}
+
+// Same as GetPlaylistTracks, but for Youtube playlists
+func (r *repository) GetYoutubePlaylistTracks(b *bp.Backup, youtubeId string, offset int, limit int) (t *[]bp.YoutubeTrack, err error) {
+	rows, err := r.db.Query(
+		`SELECT `+youtubeTrackColumns("t")+`
+		FROM youtube_tracks t
+		WHERE t.content_id = (SELECT content_id FROM youtube_playlists WHERE backup_id = ? AND youtube_id = ? ORDER BY id LIMIT 1)
+		ORDER BY t.position LIMIT ? OFFSET ?`,
+		b.Id, youtubeId, limit, offset)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	tracks := []bp.YoutubeTrack{}
+	for rows.Next() {
+		var v bp.YoutubeTrack
+		err = scanYoutubeTrack(rows, &v)
+		if err != nil {
+			return
+		}
+
+		tracks = append(tracks, v)
+	}
+
+	return &tracks, rows.Err()
+}
@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	bp "github.com/hoffs/crispy-musicular/pkg/backup"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetBackupsPage(t *testing.T) {
+	r, err := NewRepository(":memory:")
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		err = r.AddBackup(&bp.Backup{UserId: "User", Started: time.Unix(int64(i*100), 0).UTC()})
+		require.NoError(t, err)
+	}
+
+	b, err := r.GetBackupsPage("User", 1, 2)
+	require.NoError(t, err)
+	require.Len(t, *b, 2)
+	require.Equal(t, time.Unix(300, 0).UTC(), (*b)[0].Started.UTC())
+	require.Equal(t, time.Unix(200, 0).UTC(), (*b)[1].Started.UTC())
+
+	b, err = r.GetBackupsPage("User", 4, 10)
+	require.NoError(t, err)
+	require.Len(t, *b, 1)
+}
+
+func TestGetPlaylistSummariesAndTracks(t *testing.T) {
+	r, err := NewRepository(":memory:")
+	require.NoError(t, err)
+
+	b := bp.Backup{UserId: "User", Started: time.Unix(0, 0).UTC()}
+	err = r.AddBackup(&b)
+	require.NoError(t, err)
+
+	tracks := []bp.Track{
+		{SpotifyId: "S1", Name: "N1", Artist: "Art", Album: "A", Created: time.Unix(0, 0).UTC()},
+		{SpotifyId: "S2", Name: "N2", Artist: "Art", Album: "A", Created: time.Unix(0, 0).UTC()},
+		{SpotifyId: "S3", Name: "N3", Artist: "Art", Album: "A", Created: time.Unix(0, 0).UTC()},
+	}
+	yt := []bp.YoutubeTrack{{YoutubeId: "Y1", Name: "N", ChannelTitle: "C", Created: time.Unix(0, 0).UTC()}}
+
+	err = r.AddPlaylist(&b, &bp.Playlist{SpotifyId: "P2", Name: "Second", OwnerName: "Owner", Position: 1, Created: time.Unix(0, 0).UTC()}, &tracks)
+	require.NoError(t, err)
+	err = r.AddPlaylist(&b, &bp.Playlist{SpotifyId: "P1", Name: "First", Position: 0, Created: time.Unix(0, 0).UTC()}, &[]bp.Track{})
+	require.NoError(t, err)
+	err = r.AddYoutubePlaylist(&b, &bp.YoutubePlaylist{YoutubeId: "YP", Name: "Videos", ChannelTitle: "Channel", Created: time.Unix(0, 0).UTC()}, &yt)
+	require.NoError(t, err)
+
+	p, err := r.GetPlaylistSummaries(&b, 0, -1)
+	require.NoError(t, err)
+	require.Equal(t, []bp.PlaylistSummary{
+		{Platform: bp.PlatformSpotify, Id: "P1", Name: "First", Position: 0, TrackCount: 0},
+		{Platform: bp.PlatformSpotify, Id: "P2", Name: "Second", Owner: "Owner", Position: 1, TrackCount: 3},
+		{Platform: bp.PlatformYoutube, Id: "YP", Name: "Videos", Owner: "Channel", TrackCount: 1},
+	}, *p)
+
+	p, err = r.GetPlaylistSummaries(&b, 1, 1)
+	require.NoError(t, err)
+	require.Len(t, *p, 1)
+	require.Equal(t, "P2", (*p)[0].Id)
+
+	s, err := r.GetPlaylistSummary(&b, bp.PlatformYoutube, "YP")
+	require.NoError(t, err)
+	require.Equal(t, bp.PlaylistSummary{Platform: bp.PlatformYoutube, Id: "YP", Name: "Videos", Owner: "Channel", TrackCount: 1}, *s)
+
+	_, err = r.GetPlaylistSummary(&b, bp.PlatformSpotify, "YP")
+	require.ErrorIs(t, err, bp.ErrPlaylistNotFound)
+
+	st, err := r.GetPlaylistTracks(&b, "P2", 1, 5)
+	require.NoError(t, err)
+	require.Len(t, *st, 2)
+	require.Equal(t, "S2", (*st)[0].SpotifyId)
+	require.Equal(t, "S3", (*st)[1].SpotifyId)
+
+	st, err = r.GetPlaylistTracks(&b, "missing", 0, -1)
+	require.NoError(t, err)
+	require.Empty(t, *st)
+
+	yst, err := r.GetYoutubePlaylistTracks(&b, "YP", 0, -1)
+	require.NoError(t, err)
+	require.Len(t, *yst, 1)
+	require.Equal(t, "Y1", (*yst)[0].YoutubeId)
+}
@@ -0,0 +1,146 @@
+package storage
+
+import (
+	"database/sql"
+
+	bp "github.com/hoffs/crispy-musicular/pkg/backup"
+)
+
+func (r *repository) AddAlbums(b *bp.Backup, a *[]bp.Album) (err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return
+	}
+	defer tx.Rollback()
+
+	for id := range *a {
+		v := &(*a)[id]
+		var result sql.Result
+		result, err = tx.Exec(
+			"INSERT INTO albums (spotify_id, name, artist, release_date, added_at, created, backup_id) VALUES (?, ?, ?, ?, ?, ?, ?)",
+			v.SpotifyId, v.Name, v.Artist, v.ReleaseDate, v.AddedAt, v.Created, b.Id)
+		if err != nil {
+			return
+		}
+
+		v.Id, err = result.LastInsertId()
+		if err != nil {
+			return
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *repository) AddArtists(b *bp.Backup, a *[]bp.Artist) (err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return
+	}
+	defer tx.Rollback()
+
+	for id := range *a {
+		v := &(*a)[id]
+		var result sql.Result
+		result, err = tx.Exec(
+			"INSERT INTO artists (spotify_id, name, genres, created, backup_id) VALUES (?, ?, ?, ?, ?)",
+			v.SpotifyId, v.Name, v.Genres, v.Created, b.Id)
+		if err != nil {
+			return
+		}
+
+		v.Id, err = result.LastInsertId()
+		if err != nil {
+			return
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *repository) AddShows(b *bp.Backup, s *[]bp.Show) (err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return
+	}
+	defer tx.Rollback()
+
+	for id := range *s {
+		v := &(*s)[id]
+		var result sql.Result
+		result, err = tx.Exec(
+			"INSERT INTO shows (spotify_id, name, publisher, added_at, created, backup_id) VALUES (?, ?, ?, ?, ?, ?)",
+			v.SpotifyId, v.Name, v.Publisher, v.AddedAt, v.Created, b.Id)
+		if err != nil {
+			return
+		}
+
+		v.Id, err = result.LastInsertId()
+		if err != nil {
+			return
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *repository) getBackupLibrary(b *bp.Backup) (l *bp.Library, err error) {
+	l = &bp.Library{}
+
+	result, err := r.db.Query("SELECT id, spotify_id, name, artist, release_date, added_at, created FROM albums WHERE backup_id = ? ORDER BY id", b.Id)
+	if err != nil {
+		return
+	}
+	defer result.Close()
+
+	for result.Next() {
+		v := bp.Album{}
+		var releaseDate, addedAt sql.NullString
+		err = result.Scan(&v.Id, &v.SpotifyId, &v.Name, &v.Artist, &releaseDate, &addedAt, &v.Created)
+		if err != nil {
+			return
+		}
+
+		v.ReleaseDate = releaseDate.String
+		v.AddedAt = addedAt.String
+		l.Albums = append(l.Albums, v)
+	}
+
+	result, err = r.db.Query("SELECT id, spotify_id, name, genres, created FROM artists WHERE backup_id = ? ORDER BY id", b.Id)
+	if err != nil {
+		return
+	}
+	defer result.Close()
+
+	for result.Next() {
+		v := bp.Artist{}
+		var genres sql.NullString
+		err = result.Scan(&v.Id, &v.SpotifyId, &v.Name, &genres, &v.Created)
+		if err != nil {
+			return
+		}
+
+		v.Genres = genres.String
+		l.Artists = append(l.Artists, v)
+	}
+
+	result, err = r.db.Query("SELECT id, spotify_id, name, publisher, added_at, created FROM shows WHERE backup_id = ? ORDER BY id", b.Id)
+	if err != nil {
+		return
+	}
+	defer result.Close()
+
+	for result.Next() {
+		v := bp.Show{}
+		var addedAt sql.NullString
+		err = result.Scan(&v.Id, &v.SpotifyId, &v.Name, &v.Publisher, &addedAt, &v.Created)
+		if err != nil {
+			return
+		}
+
+		v.AddedAt = addedAt.String
+		l.Shows = append(l.Shows, v)
+	}
+
+	return
+}
@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	bp "github.com/hoffs/crispy-musicular/pkg/backup"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddLibrary(t *testing.T) {
+	r, err := NewRepository(":memory:")
+	require.NoError(t, err)
+
+	b := bp.Backup{UserId: "User", Started: time.Unix(0, 0).UTC()}
+	err = r.AddBackup(&b)
+	require.NoError(t, err)
+
+	a := []bp.Album{
+		{SpotifyId: "A1", Name: "Album 1", Artist: "Art", ReleaseDate: "2020", AddedAt: "now", Created: time.Unix(0, 0).UTC()},
+		{SpotifyId: "A2", Name: "Album 2", Artist: "Art", ReleaseDate: "2021-01-01", AddedAt: "now", Created: time.Unix(0, 0).UTC()},
+	}
+	err = r.AddAlbums(&b, &a)
+	require.NoError(t, err)
+	require.NotZero(t, a[0].Id)
+
+	ar := []bp.Artist{{SpotifyId: "AR", Name: "Artist", Genres: "rock, pop", Created: time.Unix(0, 0).UTC()}}
+	err = r.AddArtists(&b, &ar)
+	require.NoError(t, err)
+	require.NotZero(t, ar[0].Id)
+
+	s := []bp.Show{{SpotifyId: "S", Name: "Show", Publisher: "P", AddedAt: "now", Created: time.Unix(0, 0).UTC()}}
+	err = r.AddShows(&b, &s)
+	require.NoError(t, err)
+	require.NotZero(t, s[0].Id)
+
+	_, _, _, _, l, err := r.GetBackupData(&b)
+	require.NoError(t, err)
+	require.Equal(t, a, l.Albums)
+	require.Equal(t, ar, l.Artists)
+	require.Equal(t, s, l.Shows)
+}
+
+func TestGetBackupDataLibraryIsPerBackup(t *testing.T) {
+	r, err := NewRepository(":memory:")
+	require.NoError(t, err)
+
+	b1 := bp.Backup{UserId: "User", Started: time.Unix(0, 0).UTC()}
+	err = r.AddBackup(&b1)
+	b2 := bp.Backup{UserId: "User", Started: time.Unix(100, 0).UTC()}
+	err = r.AddBackup(&b2)
+
+	a := []bp.Album{{SpotifyId: "A1", Name: "Album 1", Artist: "Art", Created: time.Unix(0, 0).UTC()}}
+	err = r.AddAlbums(&b1, &a)
+	require.NoError(t, err)
+
+	_, _, _, _, l, err := r.GetBackupData(&b2)
+	require.NoError(t, err)
+	require.Empty(t, l.Albums)
+	require.Empty(t, l.Artists)
+	require.Empty(t, l.Shows)
+}
@@ -1,21 +1,46 @@
 package storage
 
 import (
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"hash"
+	"io"
 
+	bp "github.com/hoffs/crispy-musicular/pkg/backup"
 	"github.com/rs/zerolog/log"
 )
 
+// Migration is applied inside of a transaction and must
+// update user_version to the version it migrates to.
+type migration func(tx *sql.Tx) error
+
 var (
-	maxVer     = 2
-	migrations = map[int]string{
-		1: addDriveSql,
-		2: addYoutubeSql,
+	maxVer     = 11
+	migrations = map[int]migration{
+		1:  sqlMigration(addDriveSql),
+		2:  sqlMigration(addYoutubeSql),
+		3:  migrateContentStorage,
+		4:  sqlMigration(addAvailabilitySql),
+		5:  sqlMigration(addLibrarySql),
+		6:  sqlMigration(addTrackMetadataSql),
+		7:  sqlMigration(addPlaylistMetadataSql),
+		8:  sqlMigration(addVideoMetadataSql),
+		9:  sqlMigration(addApiTokensSql),
+		10: sqlMigration(addSchedulesSql),
+		11: sqlMigration(addBackupScopeSql),
 	}
 )
 
+func sqlMigration(query string) migration {
+	return func(tx *sql.Tx) error {
+		_, err := tx.Exec(query)
+		return err
+	}
+}
+
 func createDatabase(db *sql.DB) error {
 	_, err := db.Exec(createDbSql)
 	if err != nil {
@@ -44,7 +69,7 @@ func (r *repository) migrate() (err error) {
 }
 
 func (r *repository) applyMigration(ver int) (int, error) {
-	sql, ok := migrations[ver]
+	m, ok := migrations[ver]
 	if !ok {
 		return 0, fmt.Errorf("storage: missing migration for version %d", ver)
 	}
@@ -54,7 +79,18 @@ func (r *repository) applyMigration(ver int) (int, error) {
 		return 0, err
 	}
 
-	_, err = r.db.Exec(sql)
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	err = m(tx)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
 	if err != nil {
 		return 0, err
 	}
@@ -68,6 +104,7 @@ func (r *repository) applyMigration(ver int) (int, error) {
 		return 0, fmt.Errorf("storage: after migration %d version remains the same", oldVer)
 	}
 
+	log.Info().Msgf("storage: migrated database to version %d", ver)
 	return ver, nil
 }
 
@@ -85,3 +122,193 @@ func (r *repository) getVersion() (ver int, err error) {
 
 	return
 }
+
+// Converts tracks stored per playlist into content addressed tracks,
+// every playlist with the same list of tracks ends up referencing
+// a single copy of them and duplicates are dropped.
+func migrateContentStorage(tx *sql.Tx) (err error) {
+	_, err = tx.Exec(addContentStorageSql)
+	if err != nil {
+		return
+	}
+
+	err = convertSpotifyContent(tx)
+	if err != nil {
+		return fmt.Errorf("storage: failed to convert spotify tracks: %w", err)
+	}
+
+	err = convertYoutubeContent(tx)
+	if err != nil {
+		return fmt.Errorf("storage: failed to convert youtube tracks: %w", err)
+	}
+
+	_, err = tx.Exec(finishContentStorageSql)
+	return
+}
+
+func convertSpotifyContent(tx *sql.Tx) (err error) {
+	playlists, err := queryPlaylistsForConversion(tx, "SELECT id, created FROM playlists ORDER BY id")
+	if err != nil {
+		return
+	}
+
+	for _, p := range playlists {
+		var tracks []bp.Track
+		tracks, err = queryOldTracks(tx, p.Id)
+		if err != nil {
+			return
+		}
+
+		contentId, created, err := getOrCreateContent(tx, "playlist_contents", hashTracksV3(tracks), p.Created)
+		if err != nil {
+			return err
+		}
+
+		if created {
+			for id, t := range tracks {
+				_, err = tx.Exec("INSERT INTO content_tracks (spotify_id, name, artist, album, added_at_to_playlist, created, content_id, position) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+					t.SpotifyId, t.Name, t.Artist, t.Album, t.AddedAtToPlaylist, t.Created, contentId, id)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		_, err = tx.Exec("UPDATE playlists SET content_id = ? WHERE id = ?", contentId, p.Id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return
+}
+
+func convertYoutubeContent(tx *sql.Tx) (err error) {
+	playlists, err := queryPlaylistsForConversion(tx, "SELECT id, created FROM youtube_playlists ORDER BY id")
+	if err != nil {
+		return
+	}
+
+	for _, p := range playlists {
+		var tracks []bp.YoutubeTrack
+		tracks, err = queryOldYoutubeTracks(tx, p.Id)
+		if err != nil {
+			return
+		}
+
+		contentId, created, err := getOrCreateContent(tx, "youtube_playlist_contents", hashYoutubeTracksV3(tracks), p.Created)
+		if err != nil {
+			return err
+		}
+
+		if created {
+			for id, t := range tracks {
+				_, err = tx.Exec("INSERT INTO content_youtube_tracks (youtube_id, name, channel_title, added_at_to_playlist, created, content_id, position) VALUES (?, ?, ?, ?, ?, ?, ?)",
+					t.YoutubeId, t.Name, t.ChannelTitle, t.AddedAtToPlaylist, t.Created, contentId, id)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		_, err = tx.Exec("UPDATE youtube_playlists SET content_id = ? WHERE id = ?", contentId, p.Id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return
+}
+
+func queryPlaylistsForConversion(tx *sql.Tx, query string) (p []playlist, err error) {
+	result, err := tx.Query(query)
+	if err != nil {
+		return
+	}
+	defer result.Close()
+
+	for result.Next() {
+		var v playlist
+		err = result.Scan(&v.Id, &v.Created)
+		if err != nil {
+			return
+		}
+
+		p = append(p, v)
+	}
+
+	return p, result.Err()
+}
+
+func queryOldTracks(tx *sql.Tx, playlistId int64) (t []bp.Track, err error) {
+	result, err := tx.Query("SELECT spotify_id, name, artist, album, added_at_to_playlist, created FROM tracks WHERE playlist_id = ? ORDER BY id", playlistId)
+	if err != nil {
+		return
+	}
+	defer result.Close()
+
+	for result.Next() {
+		var v bp.Track
+		var addedAt sql.NullString
+		err = result.Scan(&v.SpotifyId, &v.Name, &v.Artist, &v.Album, &addedAt, &v.Created)
+		if err != nil {
+			return
+		}
+
+		v.AddedAtToPlaylist = addedAt.String
+		t = append(t, v)
+	}
+
+	return t, result.Err()
+}
+
+func queryOldYoutubeTracks(tx *sql.Tx, playlistId int64) (t []bp.YoutubeTrack, err error) {
+	result, err := tx.Query("SELECT youtube_id, name, channel_title, added_at_to_playlist, created FROM youtube_tracks WHERE playlist_id = ? ORDER BY id", playlistId)
+	if err != nil {
+		return
+	}
+	defer result.Close()
+
+	for result.Next() {
+		var v bp.YoutubeTrack
+		var addedAt sql.NullString
+		err = result.Scan(&v.YoutubeId, &v.Name, &v.ChannelTitle, &addedAt, &v.Created)
+		if err != nil {
+			return
+		}
+
+		v.AddedAtToPlaylist = addedAt.String
+		t = append(t, v)
+	}
+
+	return t, result.Err()
+}
+
+// Content hashes as they were computed at version 3. Live hashes include fields
+// added by later versions, so they must not be used here or the migration would
+// depend on code that keeps changing.
+func hashTracksV3(t []bp.Track) string {
+	h := sha256.New()
+	for _, v := range t {
+		writeFieldsV3(h, v.SpotifyId, v.Name, v.Artist, v.Album, v.AddedAtToPlaylist)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func hashYoutubeTracksV3(t []bp.YoutubeTrack) string {
+	h := sha256.New()
+	for _, v := range t {
+		writeFieldsV3(h, v.YoutubeId, v.Name, v.ChannelTitle, v.AddedAtToPlaylist)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func writeFieldsV3(h hash.Hash, fields ...string) {
+	for _, f := range fields {
+		io.WriteString(h, f)
+		io.WriteString(h, "\x1f")
+	}
+	io.WriteString(h, "\x1e")
+}
@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"database/sql"
+
+	bp "github.com/hoffs/crispy-musicular/pkg/backup"
+)
+
+// Returns all backups of the user, newest first
+func (r *repository) GetBackups(userId string) (b *[]bp.Backup, err error) {
+	return r.GetBackupsPage(userId, 0, -1)
+}
+
+// Deletes backups together with everything that belongs to them. Track
+// contents are shared between backups, so they are only deleted when
+// no playlist references them anymore. Covers are stored outside of the
+// database, so keys of covers which are no longer referenced are returned
+// for the caller to delete.
+func (r *repository) DeleteBackups(b *[]bp.Backup) (orphanedCovers []string, err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return
+	}
+	defer tx.Rollback()
+
+	covers := map[string]bool{}
+	candidates := []string{}
+	for _, v := range *b {
+		var rows *sql.Rows
+		rows, err = tx.Query(
+			`SELECT cover_blob FROM playlists WHERE backup_id = ? AND cover_blob != ''
+			UNION SELECT cover_blob FROM youtube_playlists WHERE backup_id = ? AND cover_blob != ''`,
+			v.Id, v.Id)
+		if err != nil {
+			return
+		}
+
+		for rows.Next() {
+			var key string
+			err = rows.Scan(&key)
+			if err != nil {
+				rows.Close()
+				return
+			}
+
+			if !covers[key] {
+				covers[key] = true
+				candidates = append(candidates, key)
+			}
+		}
+
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return
+		}
+
+		for _, table := range []string{"playlists", "youtube_playlists", "albums", "artists", "shows"} {
+			_, err = tx.Exec("DELETE FROM "+table+" WHERE backup_id = ?", v.Id)
+			if err != nil {
+				return
+			}
+		}
+
+		_, err = tx.Exec("DELETE FROM backups WHERE id = ?", v.Id)
+		if err != nil {
+			return
+		}
+	}
+
+	_, err = tx.Exec(deleteOrphanedContentSql)
+	if err != nil {
+		return
+	}
+
+	for _, key := range candidates {
+		var used bool
+		err = tx.QueryRow(
+			`SELECT EXISTS (SELECT 1 FROM playlists WHERE cover_blob = ?)
+			OR EXISTS (SELECT 1 FROM youtube_playlists WHERE cover_blob = ?)`,
+			key, key).Scan(&used)
+		if err != nil {
+			return
+		}
+
+		if !used {
+			orphanedCovers = append(orphanedCovers, key)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+var deleteOrphanedContentSql = `
+DELETE FROM tracks WHERE content_id NOT IN (SELECT content_id FROM playlists WHERE content_id IS NOT NULL);
+DELETE FROM playlist_contents WHERE id NOT IN (SELECT content_id FROM playlists WHERE content_id IS NOT NULL);
+DELETE FROM youtube_tracks WHERE content_id NOT IN (SELECT content_id FROM youtube_playlists WHERE content_id IS NOT NULL);
+DELETE FROM youtube_playlist_contents WHERE id NOT IN (SELECT content_id FROM youtube_playlists WHERE content_id IS NOT NULL);
+`
+
+// Rebuilds database file so that space of deleted rows is returned to the file system
+func (r *repository) Vacuum() (err error) {
+	_, err = r.db.Exec("VACUUM")
+	return
+}
@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	bp "github.com/hoffs/crispy-musicular/pkg/backup"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetBackups(t *testing.T) {
+	r, err := NewRepository(":memory:")
+	require.NoError(t, err)
+
+	b1 := bp.Backup{UserId: "User", Started: time.Unix(0, 0).UTC()}
+	err = r.AddBackup(&b1)
+	require.NoError(t, err)
+	b1.Success = true
+	b1.Finished = time.Unix(10, 0).UTC()
+	err = r.UpdateBackup(&b1)
+	require.NoError(t, err)
+
+	b2 := bp.Backup{UserId: "User", Started: time.Unix(100, 0).UTC()}
+	err = r.AddBackup(&b2)
+	require.NoError(t, err)
+
+	err = r.AddBackup(&bp.Backup{UserId: "Other", Started: time.Unix(100, 0).UTC()})
+	require.NoError(t, err)
+
+	b, err := r.GetBackups("User")
+	require.NoError(t, err)
+	require.Len(t, *b, 2)
+	require.Equal(t, b2.Id, (*b)[0].Id)
+	require.True(t, (*b)[0].Finished.IsZero())
+	require.Equal(t, b1.Id, (*b)[1].Id)
+	require.True(t, (*b)[1].Success)
+}
+
+func TestDeleteBackups(t *testing.T) {
+	r, err := NewRepository(":memory:")
+	require.NoError(t, err)
+
+	b1 := bp.Backup{UserId: "User", Started: time.Unix(0, 0).UTC()}
+	err = r.AddBackup(&b1)
+	require.NoError(t, err)
+	b2 := bp.Backup{UserId: "User", Started: time.Unix(100, 0).UTC()}
+	err = r.AddBackup(&b2)
+	require.NoError(t, err)
+
+	shared := []bp.Track{{SpotifyId: "S1", Name: "N1", Artist: "Art", Album: "A", Created: time.Unix(0, 0).UTC()}}
+	only := []bp.Track{{SpotifyId: "S2", Name: "N2", Artist: "Art", Album: "A", Created: time.Unix(0, 0).UTC()}}
+	yt := []bp.YoutubeTrack{{YoutubeId: "Y", Name: "N", ChannelTitle: "C", Created: time.Unix(0, 0).UTC()}}
+
+	err = r.AddPlaylist(&b1, &bp.Playlist{SpotifyId: "S", Name: "N", CoverBlob: "shared", Created: time.Unix(0, 0).UTC()}, &shared)
+	require.NoError(t, err)
+	err = r.AddPlaylist(&b1, &bp.Playlist{SpotifyId: "O", Name: "N", CoverBlob: "only", Created: time.Unix(0, 0).UTC()}, &only)
+	require.NoError(t, err)
+	err = r.AddYoutubePlaylist(&b1, &bp.YoutubePlaylist{YoutubeId: "Y", Name: "N", CoverBlob: "youtube", Created: time.Unix(0, 0).UTC()}, &yt)
+	require.NoError(t, err)
+	err = r.AddAlbums(&b1, &[]bp.Album{{SpotifyId: "A", Name: "A", Created: time.Unix(0, 0).UTC()}})
+	require.NoError(t, err)
+
+	shared2 := []bp.Track{{SpotifyId: "S1", Name: "N1", Artist: "Art", Album: "A", Created: time.Unix(0, 0).UTC()}}
+	err = r.AddPlaylist(&b2, &bp.Playlist{SpotifyId: "S", Name: "N", CoverBlob: "shared", Created: time.Unix(0, 0).UTC()}, &shared2)
+	require.NoError(t, err)
+
+	orphaned, err := r.DeleteBackups(&[]bp.Backup{b1})
+	require.NoError(t, err)
+	// cover of the playlist in remaining backup is still used
+	require.ElementsMatch(t, []string{"only", "youtube"}, orphaned)
+
+	err = r.Vacuum()
+	require.NoError(t, err)
+
+	db := r.(*repository).db
+	count := func(table string) (c int) {
+		err := db.QueryRow("SELECT count(*) FROM " + table).Scan(&c)
+		require.NoError(t, err)
+		return
+	}
+
+	require.Equal(t, 1, count("backups"))
+	require.Equal(t, 1, count("playlists"))
+	require.Equal(t, 1, count("playlist_contents"))
+	require.Equal(t, 1, count("tracks"))
+	require.Equal(t, 0, count("youtube_playlists"))
+	require.Equal(t, 0, count("youtube_playlist_contents"))
+	require.Equal(t, 0, count("youtube_tracks"))
+	require.Equal(t, 0, count("albums"))
+
+	p, tr, _, _, _, err := r.GetBackupData(&b2)
+	require.NoError(t, err)
+	require.Len(t, *p, 1)
+	require.Len(t, *tr, 1)
+	require.Equal(t, "S1", (*tr)[0].SpotifyId)
+}
@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+
+	bp "github.com/hoffs/crispy-musicular/pkg/backup"
+)
+
+func (r *repository) GetScheduledRun(name string) (s *bp.ScheduledRun, err error) {
+	s = &bp.ScheduledRun{Name: name}
+	err = r.db.QueryRow("SELECT spec, next_run FROM schedules WHERE name = ?", name).Scan(&s.Spec, &s.Next)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, bp.ErrScheduleNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+func (r *repository) GetScheduledRuns() (s *[]bp.ScheduledRun, err error) {
+	rows, err := r.db.Query("SELECT name, spec, next_run FROM schedules ORDER BY name")
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	runs := []bp.ScheduledRun{}
+	for rows.Next() {
+		var v bp.ScheduledRun
+		err = rows.Scan(&v.Name, &v.Spec, &v.Next)
+		if err != nil {
+			return
+		}
+
+		runs = append(runs, v)
+	}
+
+	return &runs, rows.Err()
+}
+
+func (r *repository) SetScheduledRun(s *bp.ScheduledRun) (err error) {
+	_, err = r.db.Exec(
+		"INSERT INTO schedules (name, spec, next_run) VALUES (?, ?, ?) ON CONFLICT(name) DO UPDATE SET spec = excluded.spec, next_run = excluded.next_run",
+		s.Name, s.Spec, s.Next)
+	return
+}
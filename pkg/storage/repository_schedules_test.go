@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	bp "github.com/hoffs/crispy-musicular/pkg/backup"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduledRuns(t *testing.T) {
+	r, err := NewRepository(":memory:")
+	require.NoError(t, err)
+
+	_, err = r.GetScheduledRun("spotify")
+	require.ErrorIs(t, err, bp.ErrScheduleNotFound)
+
+	err = r.SetScheduledRun(&bp.ScheduledRun{Name: "spotify", Spec: "0 3 * * *", Next: time.Unix(100, 0).UTC()})
+	require.NoError(t, err)
+
+	err = r.SetScheduledRun(&bp.ScheduledRun{Name: "youtube", Spec: "@daily", Next: time.Unix(50, 0).UTC()})
+	require.NoError(t, err)
+
+	// existing schedule is replaced
+	err = r.SetScheduledRun(&bp.ScheduledRun{Name: "spotify", Spec: "0 4 * * *", Next: time.Unix(200, 0).UTC()})
+	require.NoError(t, err)
+
+	s, err := r.GetScheduledRun("spotify")
+	require.NoError(t, err)
+	require.Equal(t, "0 4 * * *", s.Spec)
+	require.Equal(t, time.Unix(200, 0).UTC(), s.Next.UTC())
+
+	runs, err := r.GetScheduledRuns()
+	require.NoError(t, err)
+	require.Len(t, *runs, 2)
+	require.Equal(t, "spotify", (*runs)[0].Name)
+	require.Equal(t, "youtube", (*runs)[1].Name)
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/hoffs/crispy-musicular/pkg/auth"
+	bp "github.com/hoffs/crispy-musicular/pkg/backup"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/stretchr/testify/require"
 )
@@ -54,6 +55,74 @@ func TestMigrateDatabaseDoesntDeleteState(t *testing.T) {
 	require.Equal(t, "b", stAfter.User)
 }
 
+func TestMigrateContentStorage(t *testing.T) {
+	conn, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	conn.SetMaxOpenConns(1)
+
+	err = createDatabase(conn)
+	require.NoError(t, err)
+
+	r := &repository{conn}
+	for ver := 1; ver < 3; ver++ {
+		_, err = r.applyMigration(ver)
+		require.NoError(t, err)
+	}
+
+	// two backups with the same playlist and one with changed tracks
+	for b := int64(1); b <= 3; b++ {
+		_, err = r.db.Exec("INSERT INTO backups (id, user_id, started) VALUES (?, ?, ?)", b, "User", time.Unix(b, 0).UTC())
+		require.NoError(t, err)
+		_, err = r.db.Exec("INSERT INTO playlists (id, spotify_id, name, created, backup_id) VALUES (?, ?, ?, ?, ?)", b, "S", "N", time.Unix(b, 0).UTC(), b)
+		require.NoError(t, err)
+
+		second := "S2"
+		if b == 3 {
+			second = "S3"
+		}
+
+		for _, id := range []string{"S1", second} {
+			_, err = r.db.Exec("INSERT INTO tracks (spotify_id, name, artist, album, added_at_to_playlist, created, playlist_id, backup_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+				id, "N", "Art", "A", "now", time.Unix(b, 0).UTC(), b, b)
+			require.NoError(t, err)
+		}
+
+		_, err = r.db.Exec("INSERT INTO youtube_playlists (id, youtube_id, name, created, backup_id) VALUES (?, ?, ?, ?, ?)", b, "Y", "N", time.Unix(b, 0).UTC(), b)
+		require.NoError(t, err)
+		_, err = r.db.Exec("INSERT INTO youtube_tracks (youtube_id, name, channel_title, added_at_to_playlist, created, playlist_id, backup_id) VALUES (?, ?, ?, ?, ?, ?, ?)",
+			"Y1", "N", "C", "now", time.Unix(b, 0).UTC(), b, b)
+		require.NoError(t, err)
+	}
+
+	err = r.migrate()
+	require.NoError(t, err)
+
+	var count int64
+	err = r.db.QueryRow("SELECT count(*) FROM tracks").Scan(&count)
+	require.NoError(t, err)
+	require.EqualValues(t, 4, count)
+
+	err = r.db.QueryRow("SELECT count(*) FROM youtube_tracks").Scan(&count)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, count)
+
+	// hash is the one computed by version 3, even though live hashes include more fields
+	var hash string
+	err = r.db.QueryRow("SELECT hash FROM youtube_playlist_contents").Scan(&hash)
+	require.NoError(t, err)
+	require.Equal(t, "c5848abe8744a1afc7fc32c7547e2b6bb64a8dbdf4e6c8038aea78c0af29deb0", hash)
+
+	for b := int64(1); b <= 3; b++ {
+		_, st, _, yt, _, err := r.GetBackupData(&bp.Backup{Id: b})
+		require.NoError(t, err)
+		require.EqualValues(t, 2, len(*st))
+		require.Equal(t, "S1", (*st)[0].SpotifyId)
+		require.Equal(t, b, (*st)[0].PlaylistId)
+		require.EqualValues(t, 1, len(*yt))
+		require.Equal(t, b, (*yt)[0].PlaylistId)
+	}
+}
+
 func TestCreateNewRepository(t *testing.T) {
 	temp, err := os.CreateTemp("", "temp_db")
 	require.NoError(t, err)
@@ -83,6 +152,16 @@ func TestCreateNewRepository(t *testing.T) {
 		"tracks":            false,
 		"youtube_playlists": false,
 		"youtube_tracks":    false,
+
+		"playlist_contents":         false,
+		"youtube_playlist_contents": false,
+
+		"albums":  false,
+		"artists": false,
+		"shows":   false,
+
+		"api_tokens": false,
+		"schedules":  false,
 	}
 
 	for rows.Next() {
@@ -0,0 +1,13 @@
+package storage
+
+var addApiTokensSql = `
+CREATE TABLE IF NOT EXISTS api_tokens (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	token_hash TEXT NOT NULL UNIQUE,
+	created TIMESTAMP NOT NULL,
+	last_used TIMESTAMP
+);
+
+PRAGMA user_version=9;
+`
@@ -0,0 +1,19 @@
+package storage
+
+// Youtube replaces titles of removed videos, which
+// is enough to mark already stored tracks.
+var addAvailabilitySql = `
+ALTER TABLE tracks
+	ADD COLUMN availability TEXT NOT NULL DEFAULT 'playable';
+
+ALTER TABLE youtube_tracks
+	ADD COLUMN availability TEXT NOT NULL DEFAULT 'playable';
+
+UPDATE youtube_tracks SET availability = 'deleted' WHERE name = 'Deleted video';
+UPDATE youtube_tracks SET availability = 'private' WHERE name = 'Private video';
+
+CREATE INDEX IF NOT EXISTS tracks_spotify_id ON tracks(spotify_id);
+CREATE INDEX IF NOT EXISTS youtube_tracks_youtube_id ON youtube_tracks(youtube_id);
+
+PRAGMA user_version=4;
+`
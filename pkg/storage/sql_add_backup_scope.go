@@ -0,0 +1,7 @@
+package storage
+
+var addBackupScopeSql = `
+ALTER TABLE backups ADD COLUMN scope TEXT NOT NULL DEFAULT 'all';
+
+PRAGMA user_version=11;
+`
@@ -0,0 +1,73 @@
+package storage
+
+// Tracks are moved from belonging to a playlist of a specific backup
+// to belonging to a content entry identified by hash of the track list.
+// Playlists of different backups reference the same content if unchanged.
+var addContentStorageSql = `
+CREATE TABLE IF NOT EXISTS playlist_contents (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	hash TEXT NOT NULL UNIQUE,
+	created TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS youtube_playlist_contents (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	hash TEXT NOT NULL UNIQUE,
+	created TIMESTAMP NOT NULL
+);
+
+ALTER TABLE playlists
+	ADD COLUMN snapshot_id TEXT;
+
+ALTER TABLE playlists
+	ADD COLUMN content_id INTEGER REFERENCES playlist_contents(id);
+
+ALTER TABLE youtube_playlists
+	ADD COLUMN content_id INTEGER REFERENCES youtube_playlist_contents(id);
+
+CREATE TABLE content_tracks (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	spotify_id TEXT NOT NULL,
+	name TEXT NOT NULL,
+	artist TEXT NOT NULL,
+	album TEXT NOT NULL,
+	added_at_to_playlist TEXT,
+	created TIMESTAMP NOT NULL,
+
+	content_id INTEGER NOT NULL,
+	position INTEGER NOT NULL,
+
+	FOREIGN KEY(content_id) REFERENCES playlist_contents(id)
+);
+
+CREATE TABLE content_youtube_tracks (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	youtube_id TEXT NOT NULL,
+	name TEXT NOT NULL,
+	channel_title TEXT NOT NULL,
+	added_at_to_playlist TEXT,
+	created TIMESTAMP NOT NULL,
+
+	content_id INTEGER NOT NULL,
+	position INTEGER NOT NULL,
+
+	FOREIGN KEY(content_id) REFERENCES youtube_playlist_contents(id)
+);
+`
+
+// Executed after existing tracks are copied over to content tables.
+var finishContentStorageSql = `
+DROP TABLE tracks;
+ALTER TABLE content_tracks RENAME TO tracks;
+
+DROP TABLE youtube_tracks;
+ALTER TABLE content_youtube_tracks RENAME TO youtube_tracks;
+
+CREATE INDEX IF NOT EXISTS tracks_content_id ON tracks(content_id, position);
+CREATE INDEX IF NOT EXISTS youtube_tracks_content_id ON youtube_tracks(content_id, position);
+CREATE INDEX IF NOT EXISTS playlists_backup_id ON playlists(backup_id);
+CREATE INDEX IF NOT EXISTS playlists_snapshot_id ON playlists(spotify_id, snapshot_id);
+CREATE INDEX IF NOT EXISTS youtube_playlists_backup_id ON youtube_playlists(backup_id);
+
+PRAGMA user_version=3;
+`
@@ -0,0 +1,45 @@
+package storage
+
+var addLibrarySql = `
+CREATE TABLE IF NOT EXISTS albums (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	spotify_id TEXT NOT NULL,
+	name TEXT NOT NULL,
+	artist TEXT NOT NULL,
+	release_date TEXT,
+	added_at TEXT,
+	created TIMESTAMP NOT NULL,
+
+	backup_id INTEGER NOT NULL,
+	FOREIGN KEY(backup_id) REFERENCES backups(id)
+);
+
+CREATE TABLE IF NOT EXISTS artists (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	spotify_id TEXT NOT NULL,
+	name TEXT NOT NULL,
+	genres TEXT,
+	created TIMESTAMP NOT NULL,
+
+	backup_id INTEGER NOT NULL,
+	FOREIGN KEY(backup_id) REFERENCES backups(id)
+);
+
+CREATE TABLE IF NOT EXISTS shows (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	spotify_id TEXT NOT NULL,
+	name TEXT NOT NULL,
+	publisher TEXT NOT NULL,
+	added_at TEXT,
+	created TIMESTAMP NOT NULL,
+
+	backup_id INTEGER NOT NULL,
+	FOREIGN KEY(backup_id) REFERENCES backups(id)
+);
+
+CREATE INDEX IF NOT EXISTS albums_backup_id ON albums(backup_id);
+CREATE INDEX IF NOT EXISTS artists_backup_id ON artists(backup_id);
+CREATE INDEX IF NOT EXISTS shows_backup_id ON shows(backup_id);
+
+PRAGMA user_version=5;
+`
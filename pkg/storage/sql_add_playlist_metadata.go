@@ -0,0 +1,22 @@
+package storage
+
+var addPlaylistMetadataSql = `
+ALTER TABLE playlists ADD COLUMN description TEXT NOT NULL DEFAULT '';
+ALTER TABLE playlists ADD COLUMN owner_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE playlists ADD COLUMN owner_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE playlists ADD COLUMN collaborative BOOLEAN NOT NULL DEFAULT 0;
+ALTER TABLE playlists ADD COLUMN public BOOLEAN NOT NULL DEFAULT 0;
+ALTER TABLE playlists ADD COLUMN cover_url TEXT NOT NULL DEFAULT '';
+ALTER TABLE playlists ADD COLUMN cover_blob TEXT NOT NULL DEFAULT '';
+ALTER TABLE playlists ADD COLUMN position INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE youtube_playlists ADD COLUMN description TEXT NOT NULL DEFAULT '';
+ALTER TABLE youtube_playlists ADD COLUMN channel_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE youtube_playlists ADD COLUMN channel_title TEXT NOT NULL DEFAULT '';
+ALTER TABLE youtube_playlists ADD COLUMN privacy_status TEXT NOT NULL DEFAULT '';
+ALTER TABLE youtube_playlists ADD COLUMN cover_url TEXT NOT NULL DEFAULT '';
+ALTER TABLE youtube_playlists ADD COLUMN cover_blob TEXT NOT NULL DEFAULT '';
+ALTER TABLE youtube_playlists ADD COLUMN position INTEGER NOT NULL DEFAULT 0;
+
+PRAGMA user_version=7;
+`
@@ -0,0 +1,11 @@
+package storage
+
+var addSchedulesSql = `
+CREATE TABLE IF NOT EXISTS schedules (
+	name TEXT PRIMARY KEY,
+	spec TEXT NOT NULL,
+	next_run TIMESTAMP NOT NULL
+);
+
+PRAGMA user_version=10;
+`
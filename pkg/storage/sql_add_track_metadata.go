@@ -0,0 +1,24 @@
+package storage
+
+// Artist ids are stored as a comma separated list, Spotify ids
+// never contain commas. Only local files are known to be local
+// for already stored tracks.
+var addTrackMetadataSql = `
+ALTER TABLE tracks ADD COLUMN uri TEXT NOT NULL DEFAULT '';
+ALTER TABLE tracks ADD COLUMN isrc TEXT NOT NULL DEFAULT '';
+ALTER TABLE tracks ADD COLUMN artist_ids TEXT NOT NULL DEFAULT '';
+ALTER TABLE tracks ADD COLUMN album_artist TEXT NOT NULL DEFAULT '';
+ALTER TABLE tracks ADD COLUMN album_release_date TEXT NOT NULL DEFAULT '';
+ALTER TABLE tracks ADD COLUMN duration_ms INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE tracks ADD COLUMN track_number INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE tracks ADD COLUMN disc_number INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE tracks ADD COLUMN explicit BOOLEAN NOT NULL DEFAULT 0;
+ALTER TABLE tracks ADD COLUMN is_local BOOLEAN NOT NULL DEFAULT 0;
+ALTER TABLE tracks ADD COLUMN added_by TEXT NOT NULL DEFAULT '';
+
+UPDATE tracks SET is_local = 1 WHERE availability = 'local';
+
+CREATE INDEX IF NOT EXISTS tracks_isrc ON tracks(isrc);
+
+PRAGMA user_version=6;
+`
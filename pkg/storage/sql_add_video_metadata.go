@@ -0,0 +1,20 @@
+package storage
+
+// Tags and thumbnails are stored as json, since both can contain any characters
+var addVideoMetadataSql = `
+ALTER TABLE youtube_tracks ADD COLUMN channel_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE youtube_tracks ADD COLUMN description TEXT NOT NULL DEFAULT '';
+ALTER TABLE youtube_tracks ADD COLUMN tags TEXT NOT NULL DEFAULT '';
+ALTER TABLE youtube_tracks ADD COLUMN category_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE youtube_tracks ADD COLUMN duration_seconds INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE youtube_tracks ADD COLUMN published_at TEXT NOT NULL DEFAULT '';
+ALTER TABLE youtube_tracks ADD COLUMN privacy_status TEXT NOT NULL DEFAULT '';
+ALTER TABLE youtube_tracks ADD COLUMN thumbnails TEXT NOT NULL DEFAULT '';
+ALTER TABLE youtube_tracks ADD COLUMN music_title TEXT NOT NULL DEFAULT '';
+ALTER TABLE youtube_tracks ADD COLUMN music_artist TEXT NOT NULL DEFAULT '';
+ALTER TABLE youtube_tracks ADD COLUMN music_album TEXT NOT NULL DEFAULT '';
+ALTER TABLE youtube_tracks ADD COLUMN music_label TEXT NOT NULL DEFAULT '';
+ALTER TABLE youtube_tracks ADD COLUMN music_release_date TEXT NOT NULL DEFAULT '';
+
+PRAGMA user_version=8;
+`
@@ -9,8 +9,8 @@ type track struct {
 	Artist            string
 	AddedAtToPlaylist string // This might not exist (in Spotify)
 	Created           time.Time
+	Position          int64
 
 	// References
-	PlaylistId int64
-	BackupId   int64
+	ContentId int64
 }